@@ -0,0 +1,132 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/adobe/k8s-shredder/pkg/handler"
+	"github.com/adobe/k8s-shredder/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+var (
+	batchCmd = &cobra.Command{
+		Use:              "batch",
+		Short:            "Track and abort parking batches",
+		PersistentPreRun: cliPreRun,
+	}
+
+	batchProgressCmd = &cobra.Command{
+		Use:   "progress <batch>",
+		Short: "Print the nodes of a parking batch along with the pods left to evict from them",
+		Args:  cobra.ExactArgs(1),
+		Run:   batchProgress,
+	}
+
+	batchUnparkCmd = &cobra.Command{
+		Use:   "unpark <batch>",
+		Short: "Unpark all the nodes of a parking batch",
+		Args:  cobra.ExactArgs(1),
+		Run:   batchUnpark,
+	}
+
+	abortBatchCmd = &cobra.Command{
+		Use:   "abort-batch <batch>",
+		Short: "Abort a parking batch",
+		Long: `Unparks the nodes of a parking batch which did not expire yet, prevents any other node from being parked
+for that batch and prints a summary of what happened to each node.`,
+		Args:             cobra.ExactArgs(1),
+		PersistentPreRun: cliPreRun,
+		Run:              abortBatch,
+	}
+)
+
+func init() {
+	batchCmd.AddCommand(batchProgressCmd)
+	batchCmd.AddCommand(batchUnparkCmd)
+	rootCmd.AddCommand(batchCmd)
+	rootCmd.AddCommand(abortBatchCmd)
+}
+
+// getBatchNodes returns the nodes labeled with the given parking batch
+func getBatchNodes(batch string) []v1.Node {
+	nodes, err := appContext.ListNodes(appContext.Context, appContext.K8sClient, metav1.ListOptions{
+		LabelSelector: labels.Set{cfg.ParkingBatchLabel: batch}.String(),
+	})
+	if err != nil {
+		log.Fatalf("Failed to list the nodes of batch %s: %s", batch, err)
+	}
+	return nodes
+}
+
+func batchProgress(cmd *cobra.Command, args []string) {
+	h := handler.NewHandler(appContext)
+	out := cmd.OutOrStdout()
+
+	nodes := getBatchNodes(args[0])
+	parked := 0
+	for _, node := range nodes {
+		status := "unparked"
+		if node.Labels[cfg.UpgradeStatusLabel] == cfg.UpgradeStatusParkedValue {
+			status = "parked"
+			parked++
+		}
+
+		pods, err := h.GetPodsForNode(node)
+		if err != nil {
+			log.Fatalf("Failed to list the pods of node %s: %s", node.Name, err)
+		}
+		fmt.Fprintf(out, "%s\t%s\t%d pods left\n", node.Name, status, len(pods))
+	}
+
+	fmt.Fprintf(out, "Batch %s: %d nodes, %d still parked\n", args[0], len(nodes), parked)
+}
+
+func batchUnpark(cmd *cobra.Command, args []string) {
+	failed := false
+	for _, node := range getBatchNodes(args[0]) {
+		nodeInfo := utils.NodeInfo{Name: node.Name, Labels: node.Labels, Batch: args[0]}
+		// nodes are unparked on behalf of whoever parked them
+		_, err := utils.UnparkNodes(appContext, []utils.NodeInfo{nodeInfo}, node.Labels[cfg.ParkingReasonLabel])
+		if err != nil {
+			failed = true
+		}
+	}
+
+	if failed {
+		log.Fatalf("Failed to unpark some of the nodes of batch %s", args[0])
+	}
+}
+
+func abortBatch(cmd *cobra.Command, args []string) {
+	report, err := utils.AbortBatch(appContext, args[0])
+	if err != nil {
+		log.Fatalf("Failed to abort batch %s: %s", args[0], err)
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Batch %s aborted\n", args[0])
+	fmt.Fprintf(out, "  unparked:             %s\n", strings.Join(report.Unparked, ", "))
+	fmt.Fprintf(out, "  left parked, expired: %s\n", strings.Join(report.Expired, ", "))
+	fmt.Fprintf(out, "  not parked anymore:   %s\n", strings.Join(report.NotParked, ", "))
+	fmt.Fprintf(out, "  failed:               %s\n", strings.Join(report.Failed, ", "))
+
+	if len(report.Failed) > 0 {
+		log.Fatalf("Failed to abort batch %s on %d nodes", args[0], len(report.Failed))
+	}
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+// envPrefix prefixes the environment variables overriding the configuration file, e.g. SHREDDER_PARKEDNODETTL
+const envPrefix = "SHREDDER"
+
+// bindEnv makes every configuration key overridable through an environment variable named after the key in upper case,
+// prefixed with SHREDDER_. Only the keys having a default value are looked up when unmarshalling, which is all of them
+func bindEnv() {
+	viper.SetEnvPrefix(envPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
+}
+
+// configDecodeHook extends the default viper decode hooks so that the maps and lists of objects set through environment
+// variables, which are plain strings, can be given as JSON
+func configDecodeHook() viper.DecoderConfigOption {
+	return viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		jsonStringHook,
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	))
+}
+
+// jsonStringHook decodes a JSON string into a map or a list of objects, e.g. SHREDDER_TTLOVERRIDESBYREASON='{"cli": "2h"}'
+func jsonStringHook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() != reflect.String {
+		return data, nil
+	}
+	if to.Kind() != reflect.Map && (to.Kind() != reflect.Slice || to.Elem().Kind() != reflect.Struct) {
+		return data, nil
+	}
+
+	raw := strings.TrimSpace(data.(string))
+	if raw == "" {
+		return nil, nil
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(raw), &decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/adobe/k8s-shredder/pkg/handler"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var explainPodCmd = &cobra.Command{
+	Use:   "explain-pod <namespace>/<pod>",
+	Short: "Explain what the eviction loop would do with a pod and why",
+	Long: `Runs the eviction loop decision logic for a single pod against the live cluster state and prints
+the verdict along with every rule consulted. Nothing is changed in the cluster.`,
+	Args: cobra.ExactArgs(1),
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// explaining a pod must never act on it
+		dryRun = true
+		cliPreRun(cmd, args)
+	},
+	Run: explainPod,
+}
+
+func init() {
+	rootCmd.AddCommand(explainPodCmd)
+}
+
+func explainPod(cmd *cobra.Command, args []string) {
+	namespace, name, found := strings.Cut(args[0], "/")
+	if !found || namespace == "" || name == "" {
+		log.Fatalf("Invalid pod %q, expected <namespace>/<pod>", args[0])
+	}
+
+	explanation, err := handler.NewHandler(appContext).ExplainPod(namespace, name)
+	if err != nil {
+		log.Fatalf("Failed to explain pod %s: %s", args[0], err)
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Pod:     %s\n", explanation.Pod)
+	fmt.Fprintf(out, "Node:    %s\n", explanation.Node)
+	fmt.Fprintf(out, "Verdict: %s\n", explanation.Verdict)
+	fmt.Fprintln(out, "Rules:")
+	for _, rule := range explanation.Rules {
+		fmt.Fprintf(out, "  - %s: %s\n", rule.Rule, rule.Outcome)
+	}
+}
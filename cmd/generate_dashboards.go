@@ -0,0 +1,69 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/adobe/k8s-shredder/pkg/dashboards"
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var dashboardsOutputDir string
+
+var generateDashboardsCmd = &cobra.Command{
+	Use:   "generate-dashboards",
+	Short: "Generate a Grafana dashboard and Prometheus rules for the k8s-shredder metrics",
+	Long: `Writes a Grafana dashboard charting every metric exposed by k8s-shredder to k8s-shredder-dashboard.json,
+along with Prometheus recording and alerting rules to k8s-shredder-rules.yaml. Both are derived from the metrics
+registered by this release, so that they can be regenerated whenever metrics change.`,
+	Args: cobra.NoArgs,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging(logLevel, logFormat)
+	},
+	Run: generateDashboards,
+}
+
+func init() {
+	generateDashboardsCmd.Flags().StringVar(&dashboardsOutputDir, "output-dir", ".", "Directory the dashboard and rules files are written to")
+	rootCmd.AddCommand(generateDashboardsCmd)
+}
+
+func generateDashboards(cmd *cobra.Command, args []string) {
+	infos, err := metrics.Describe()
+	if err != nil {
+		log.Fatalf("Failed to describe metrics: %s", err)
+	}
+
+	dashboard, err := dashboards.Dashboard(infos)
+	if err != nil {
+		log.Fatalf("Failed to generate dashboard: %s", err)
+	}
+	rules, err := dashboards.Rules(infos)
+	if err != nil {
+		log.Fatalf("Failed to generate rules: %s", err)
+	}
+
+	for name, content := range map[string][]byte{
+		"k8s-shredder-dashboard.json": dashboard,
+		"k8s-shredder-rules.yaml":     rules,
+	} {
+		path := filepath.Join(dashboardsOutputDir, name)
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			log.Fatalf("Failed to write %s: %s", path, err)
+		}
+		log.Infof("Wrote %s", path)
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package cmd
+
+import (
+	"github.com/adobe/k8s-shredder/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// parkSource is the parking reason recorded for the nodes parked from the command line
+	parkSource = "cli"
+	// adminAPIParkSource is the parking reason recorded for the nodes parked through the admin API
+	adminAPIParkSource = "admin-api"
+)
+
+var (
+	parkBatch        string
+	parkByProviderID bool
+)
+
+var parkCmd = &cobra.Command{
+	Use:   "park <node>...",
+	Short: "Park the given nodes",
+	Long: `Parks the given nodes the same way detectors do, optionally as part of a parking batch.
+With --provider-id, the nodes are given by provider ID, complete or reduced to the instance ID.`,
+	Args:             cobra.MinimumNArgs(1),
+	PersistentPreRun: cliPreRun,
+	Run:              park,
+}
+
+func init() {
+	parkCmd.Flags().StringVar(&parkBatch, "batch", "", "Identifier of the rollout the nodes are parked for, stored in the ParkingBatchLabel")
+	parkCmd.Flags().BoolVar(&parkByProviderID, "provider-id", false, "Give the nodes by provider ID instead of name, e.g. the instance IDs of cloud maintenance notifications")
+	rootCmd.AddCommand(parkCmd)
+}
+
+func park(cmd *cobra.Command, args []string) {
+	nodes := make([]utils.NodeInfo, 0, len(args))
+	if parkByProviderID {
+		var unresolved []string
+		var err error
+		nodes, unresolved, err = utils.NodesByProviderID(appContext, args, parkBatch)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		for _, providerID := range unresolved {
+			log.Warnf("No node found with provider ID %s", providerID)
+		}
+	} else {
+		for _, name := range args {
+			nodes = append(nodes, utils.NodeInfo{Name: name, Batch: parkBatch})
+		}
+	}
+
+	err := utils.ParkNodes(appContext, nodes, parkSource)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+}
+
+// parkNodesByProviderID parks the nodes of every managed cluster matching the given provider IDs on behalf of the admin
+// API. The provider IDs matching no node in any cluster are returned
+func parkNodesByProviderID(providerIDs []string, batch string) ([]string, []string, error) {
+	var parked []string
+	unresolved := providerIDs
+	for _, ac := range appContexts {
+		if len(unresolved) == 0 {
+			break
+		}
+
+		var nodes []utils.NodeInfo
+		var err error
+		nodes, unresolved, err = utils.NodesByProviderID(ac, unresolved, batch)
+		if err != nil {
+			return parked, unresolved, err
+		}
+		if len(nodes) == 0 {
+			continue
+		}
+
+		if err := utils.ParkNodes(ac, nodes, adminAPIParkSource); err != nil {
+			return parked, unresolved, err
+		}
+		for _, node := range nodes {
+			parked = append(parked, node.Name)
+		}
+	}
+	return parked, unresolved, nil
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/adobe/k8s-shredder/pkg/config"
+	"github.com/adobe/k8s-shredder/pkg/detection"
+	"github.com/adobe/k8s-shredder/pkg/handler"
+	"github.com/adobe/k8s-shredder/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+var rbacRoleName string
+
+var (
+	rbacCmd = &cobra.Command{
+		Use:   "rbac",
+		Short: "Manage the RBAC permissions required by k8s-shredder",
+	}
+
+	rbacGenerateCmd = &cobra.Command{
+		Use:   "generate",
+		Short: "Print the minimal ClusterRole required by the features enabled in the configuration",
+		Args:  cobra.NoArgs,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			setupLogging(logLevel, logFormat)
+			parseRole()
+			readConfig()
+			parseConfig()
+		},
+		Run: rbacGenerate,
+	}
+)
+
+func init() {
+	rbacGenerateCmd.Flags().StringVar(&rbacRoleName, "name", "k8s-shredder", "The name of the generated ClusterRole")
+	rbacCmd.AddCommand(rbacGenerateCmd)
+	rootCmd.AddCommand(rbacCmd)
+}
+
+func rbacGenerate(cmd *cobra.Command, args []string) {
+	role := rbacv1.ClusterRole{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: rbacv1.SchemeGroupVersion.String(),
+			Kind:       "ClusterRole",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: rbacRoleName,
+		},
+		Rules: requiredPolicyRules(cfg, role),
+	}
+
+	out, err := yaml.Marshal(role)
+	if err != nil {
+		log.Fatalf("Failed to generate ClusterRole: %s", err)
+	}
+	fmt.Fprint(cmd.OutOrStdout(), string(out))
+}
+
+// requiredPolicyRules returns the permissions the eviction loop needs with the given configuration and role
+func requiredPolicyRules(cfg config.Config, role handler.Role) []rbacv1.PolicyRule {
+	if !role.Evicts() {
+		return detectorPolicyRules(cfg)
+	}
+
+	// the parked nodes are listed, their state recorded and the pods on them evicted, or deleted once the nodes expire
+	nodeVerbs := []string{"get", "list", "patch"}
+	podVerbs := []string{"get", "list", "delete"}
+	if cfg.PodEvictionDeadlineAnnotation != "" || cfg.EnableParkedPodLabels {
+		podVerbs = append(podVerbs, "patch")
+	}
+	rules := []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: podVerbs},
+		{APIGroups: []string{""}, Resources: []string{"pods/eviction"}, Verbs: []string{"create"}},
+		{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create", "patch"}},
+		// controller objects are looked up from the pod owners and rollout restarted
+		{APIGroups: []string{""}, Resources: []string{"replicationcontrollers"}, Verbs: []string{"get", "patch"}},
+		{APIGroups: []string{"apps"}, Resources: []string{"deployments", "statefulsets", "replicasets"}, Verbs: []string{"get", "patch"}},
+		{APIGroups: []string{"argoproj.io"}, Resources: []string{"rollouts"}, Verbs: []string{"get", "list", "patch"}},
+		{APIGroups: []string{"apps.kruise.io"}, Resources: []string{"clonesets", "statefulsets"}, Verbs: []string{"get", "patch"}},
+		// the simulate command reports the PodDisruptionBudgets which would block the evictions
+		{APIGroups: []string{"policy"}, Resources: []string{"poddisruptionbudgets"}, Verbs: []string{"list"}},
+	}
+
+	if cfg.EnableNodeInformer {
+		nodeVerbs = append(nodeVerbs, "watch")
+	}
+	// the admin API parks the nodes it is given, like the detectors
+	detects := role.Detects() && (len(detection.EnabledDetectors(utils.NewOfflineAppContext(cfg))) > 0 || cfg.EnableCapacityUnpark)
+	if detects || cfg.NoExecuteEscalationThreshold > 0 || usesExpiryAction(cfg, config.ExpiryActionNoExecuteTaint) ||
+		cfg.AdminAPITokenFile != "" || cfg.EvictionSafetyCheck {
+		nodeVerbs = append(nodeVerbs, "update")
+	}
+	rules = append([]rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: nodeVerbs}}, rules...)
+
+	if cfg.EnableKubeVirtLiveMigration {
+		rules = append(rules,
+			rbacv1.PolicyRule{APIGroups: []string{"kubevirt.io"}, Resources: []string{"virtualmachineinstances"}, Verbs: []string{"get"}},
+			rbacv1.PolicyRule{APIGroups: []string{"kubevirt.io"}, Resources: []string{"virtualmachineinstancemigrations"}, Verbs: []string{"create"}},
+		)
+	}
+	if cfg.SkipEvictionNamespaceAnnotation != "" || (cfg.EnableParkedPodLabels && cfg.ParkedPodNamespaceSelector != "") {
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: []string{"get"}})
+	}
+	if cfg.DeferRestartsDuringHPAScaling {
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{"autoscaling"}, Resources: []string{"horizontalpodautoscalers"}, Verbs: []string{"list"}})
+	}
+	if cfg.DeferJobEvictions {
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{"batch"}, Resources: []string{"jobs"}, Verbs: []string{"get"}})
+	}
+	if cfg.DeferRestartsDuringCanary {
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{"flagger.app"}, Resources: []string{"canaries"}, Verbs: []string{"list"}})
+	}
+	// the CA bundle is only injected when using a self-signed certificate
+	if cfg.EnableAdmissionWebhook && cfg.AdmissionWebhookCertDir == "" {
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{"admissionregistration.k8s.io"}, Resources: []string{"validatingwebhookconfigurations"}, Verbs: []string{"get", "update"}})
+	}
+
+	return rules
+}
+
+// detectorPolicyRules returns the permissions needed by the instances running with the detector role, which park nodes
+// without draining them
+func detectorPolicyRules(cfg config.Config) []rbacv1.PolicyRule {
+	nodeVerbs := []string{"get", "list", "patch", "update"}
+	if cfg.EnableNodeInformer {
+		nodeVerbs = append(nodeVerbs, "watch")
+	}
+	// the pods are listed by the parking limits and labeled along with their node
+	podVerbs := []string{"list"}
+	if cfg.EnableParkedPodLabels {
+		podVerbs = append(podVerbs, "patch")
+	}
+	rules := []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: nodeVerbs},
+		{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: podVerbs},
+		{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create", "patch"}},
+	}
+	if cfg.EnableParkedPodLabels && cfg.ParkedPodNamespaceSelector != "" {
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: []string{"get"}})
+	}
+	return rules
+}
+
+// usesExpiryAction checks whether an expiry action is used for any parking reason
+func usesExpiryAction(cfg config.Config, action string) bool {
+	if cfg.ExpiryAction == action {
+		return true
+	}
+	for _, a := range cfg.ExpiryActionsByReason {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
@@ -12,16 +12,26 @@ governing permissions and limitations under the License.
 package cmd
 
 import (
+	"context"
 	"github.com/google/uuid"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/adobe/k8s-shredder/pkg/api"
+	"github.com/adobe/k8s-shredder/pkg/audit"
 	"github.com/adobe/k8s-shredder/pkg/config"
+	"github.com/adobe/k8s-shredder/pkg/detection"
 	"github.com/adobe/k8s-shredder/pkg/handler"
 	"github.com/adobe/k8s-shredder/pkg/metrics"
 	"github.com/adobe/k8s-shredder/pkg/utils"
+	"github.com/adobe/k8s-shredder/pkg/webhook"
 	"github.com/fsnotify/fsnotify"
 	"github.com/go-co-op/gocron/v2"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -31,9 +41,30 @@ var (
 	cfgFile, logLevel, logFormat string
 	dryRun                       bool
 	metricsPort                  int
-	cfg                          config.Config
-	appContext                   *utils.AppContext
-	scheduler                    gocron.Scheduler
+	enablePprof                  bool
+	pprofPort                    int
+	shutdownTimeout              time.Duration
+	runOnce                      bool
+	roleFlag                     string
+	role                         handler.Role
+	// cfg is only accessed by the goroutines loading and applying the configuration, the others read it through
+	// AppContext.Config()
+	cfg        config.Config
+	appContext *utils.AppContext
+	scheduler  gocron.Scheduler
+	// startedScheduler is the running scheduler, read by the API handlers and jobs while a reload replaces scheduler
+	startedScheduler atomic.Pointer[gocron.Scheduler]
+	webhookServer    *webhook.Server
+	// appContexts holds the application context of every managed cluster, appContext being the first one
+	appContexts []*utils.AppContext
+	// currentHandler is the handler running the eviction loops of the first cluster, served by the HTTP API
+	currentHandler atomic.Pointer[handler.Handler]
+	// handlers run the eviction loops of every managed cluster, they are kept across configuration reloads
+	handlers []*handler.Handler
+	// reloadMu serializes the configuration reloads, as a single write to the file may be reported as several events
+	reloadMu sync.Mutex
+	// reloadGeneration is incremented every time a reloaded configuration is applied
+	reloadGeneration int
 
 	rootCmd = &cobra.Command{
 		Use:              "k8s-shredder",
@@ -62,6 +93,11 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", log.DebugLevel.String(), "The verbosity level of the logs, can be [panic|fatal|error|warn|warning|info|debug|trace]")
 	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "The output format of the logs, can be [text|json]")
 	rootCmd.PersistentFlags().IntVar(&metricsPort, "metrics-port", 9999, "The port used by the metrics server")
+	rootCmd.PersistentFlags().BoolVar(&enablePprof, "enable-pprof", false, "Serve the pprof profiling endpoints under /debug/pprof/")
+	rootCmd.PersistentFlags().IntVar(&pprofPort, "pprof-port", 0, "The port used by the pprof endpoints, 0 serves them on the metrics port")
+	rootCmd.PersistentFlags().DurationVar(&shutdownTimeout, "shutdown-timeout", 20*time.Second, "How long to wait for in-flight evictions to finish before exiting")
+	rootCmd.PersistentFlags().StringVar(&roleFlag, "role", string(handler.RoleAll), "The part of the work done by this instance, can be [all|detector|evictor]: detectors park the nodes, evictors drain the parked nodes")
+	rootCmd.Flags().BoolVar(&runOnce, "run-once", false, "Run the detectors and a single eviction loop, then exit with a non-zero code if any error occurred or no loop ran")
 	err := rootCmd.MarkPersistentFlagRequired("config")
 	if err != nil {
 		log.Fatalln("No config flag configured")
@@ -71,11 +107,26 @@ func init() {
 func setupAppContext(cfg config.Config, dryRun bool) {
 	var err error
 
-	appContext, err = utils.NewAppContext(cfg, dryRun)
+	appContexts, err = utils.NewAppContexts(cfg, dryRun)
 
 	if err != nil {
 		log.Fatalln("Failed to setup application context: ", err)
 	}
+	appContext = appContexts[0]
+}
+
+// setupAdmissionWebhook starts the admission webhook server when enabled. Toggling it requires a restart
+func setupAdmissionWebhook() {
+	if !cfg.EnableAdmissionWebhook {
+		return
+	}
+
+	var err error
+	webhookServer, err = webhook.NewServer(appContext)
+	if err != nil {
+		log.Fatalf("Failed to setup admission webhook server: %s", err)
+	}
+	webhookServer.Start()
 }
 
 func setupLogging(logLevel, logFormat string) {
@@ -96,57 +147,485 @@ func setupLogging(logLevel, logFormat string) {
 func setupMetricsServer() {
 	log.Infoln("Initializing metrics server")
 
+	if enablePprof {
+		log.WithField("port", pprofPort).Infoln("Enabling pprof endpoints")
+		metrics.EnablePprof(pprofPort)
+	}
+
 	err := metrics.Init(metricsPort)
 	if err != nil {
 		log.Fatalf("Failed to setup metric server: %s", err)
 	}
 }
 
-func discoverConfig() {
+// readConfig reads the configuration file once, filling in defaults for the omitted values
+func readConfig() {
 	viper.SetConfigFile(cfgFile)
+	bindEnv()
 	// Set default values in case they are omitted in config file
 	viper.SetDefault("EvictionLoopInterval", time.Second*60)
+	viper.SetDefault("MaxEvictionLoopInterval", 0)
 	viper.SetDefault("ParkedNodeTTL", time.Minute*60)
+	viper.SetDefault("TTLOverridesByReason", map[string]time.Duration{})
+	viper.SetDefault("ExpiryAction", config.ExpiryActionForceDelete)
+	viper.SetDefault("ExpiryActionsByReason", map[string]string{})
+	viper.SetDefault("ForceEvictDaemonSetPods", false)
+	viper.SetDefault("ExpiryWebhookURL", "")
 	viper.SetDefault("RollingRestartThreshold", 0.5)
+	viper.SetDefault("NoExecuteEscalationThreshold", 0)
 	viper.SetDefault("UpgradeStatusLabel", "shredder.ethos.adobe.net/upgrade-status")
+	viper.SetDefault("UpgradeStatusParkedValue", "parked")
+	viper.SetDefault("UpgradeStatusUnparkedValue", "")
+	viper.SetDefault("UpgradeStatusSoftParkedValue", "soft-parked")
+	viper.SetDefault("SoftParkingDelay", 0)
 	viper.SetDefault("ExpiresOnLabel", "shredder.ethos.adobe.net/parked-node-expires-on")
+	viper.SetDefault("ParkedAtLabel", "shredder.ethos.adobe.net/parked-at")
+	viper.SetDefault("ParkingReasonLabel", "shredder.ethos.adobe.net/parked-reason")
+	viper.SetDefault("ParkingReasonMessageAnnotation", "shredder.ethos.adobe.net/parked-reason-message")
+	viper.SetDefault("ParkingBatchLabel", "shredder.ethos.adobe.net/parking-batch")
+	viper.SetDefault("ParkingBatchAbortedLabel", "shredder.ethos.adobe.net/parking-batch-aborted")
+	viper.SetDefault("ParkedNodeTaint", "shredder.ethos.adobe.net/upgrade-status=parked:NoSchedule")
+	viper.SetDefault("ParkedNodeFallbackTaint", "")
+	viper.SetDefault("EnableParkedPodLabels", false)
+	viper.SetDefault("ParkedPodNamespaceSelector", "")
+	viper.SetDefault("ParkedPodLabelSelector", "")
+	viper.SetDefault("ParkedPodLabelingConcurrency", 10)
+	viper.SetDefault("EvictionSafetyCheck", false)
+	viper.SetDefault("MaxParkedNodes", 0)
+	viper.SetDefault("MaxParkedNodesPerZone", "")
+	viper.SetDefault("MaxParkedNodesBySource", map[string]string{})
+	viper.SetDefault("MinClusterHeadroomPercent", 0)
+	viper.SetDefault("ParkingWaveSize", 0)
+	viper.SetDefault("ParkingWaveInterval", time.Minute*30)
+	viper.SetDefault("ParkingRetryLimit", 5)
+	viper.SetDefault("ServerSideDryRun", false)
+	viper.SetDefault("ParkingCooldown", 0)
+	viper.SetDefault("UnparkedAtAnnotation", "shredder.ethos.adobe.net/unparked-at")
+	viper.SetDefault("DeferTTLReductions", false)
+	viper.SetDefault("MaxParkedNodesLoweredPolicy", config.MaxParkedNodesLoweredIgnore)
 	viper.SetDefault("NamespacePrefixSkipInitialEviction", "")
 	viper.SetDefault("RestartedAtAnnotation", "shredder.ethos.adobe.net/restartedAt")
 	viper.SetDefault("AllowEvictionLabel", "shredder.ethos.adobe.net/allow-eviction")
+	viper.SetDefault("SkipEvictionNamespaceAnnotation", "shredder.ethos.adobe.net/skip-eviction")
 	viper.SetDefault("ToBeDeletedTaint", "ToBeDeletedByClusterAutoscaler")
 	viper.SetDefault("ArgoRolloutsAPIVersion", "v1alpha1")
+	viper.SetDefault("OpenKruiseAPIVersion", "v1alpha1")
+	viper.SetDefault("PausedRolloutPolicy", config.PausedRolloutPolicyEvict)
+	viper.SetDefault("PausedRolloutPoliciesByNamespace", map[string]string{})
+	viper.SetDefault("PausedRolloutEscalationThreshold", 0.9)
+	viper.SetDefault("PausedRolloutAnnotation", "shredder.ethos.adobe.net/paused-rollout-blocking-until")
+	viper.SetDefault("EnableKubeVirtLiveMigration", false)
+	viper.SetDefault("KubeVirtAPIVersion", "v1")
+	viper.SetDefault("EvictionDeleteFallback", false)
+	viper.SetDefault("EvictionDeleteFallbackRetries", 5)
+	viper.SetDefault("EvictionDeleteFallbackBeforeExpiry", time.Minute*10)
+	viper.SetDefault("ForceEvictionTiers", []config.ForceEvictionTier{})
+	viper.SetDefault("ForceEvictionTierAnnotation", "shredder.ethos.adobe.net/force-eviction-tier")
+	viper.SetDefault("ForceEvictionStaggerWindow", 0)
+	viper.SetDefault("PodEvictionDeadlineAnnotation", "")
+	viper.SetDefault("OrphanedPodsCleanupInterval", time.Minute*10)
+	viper.SetDefault("NodeStateAnnotation", "shredder.ethos.adobe.net/state")
+	viper.SetDefault("OrderedEvictionAnnotation", "shredder.ethos.adobe.net/ordered-eviction")
+	viper.SetDefault("EvictionCostAnnotation", "shredder.ethos.adobe.net/eviction-cost")
+	viper.SetDefault("ProtectedNodeLabels", []string{"shredder.ethos.adobe.net/protected=true"})
+	viper.SetDefault("ExcludedNodeNames", []string{})
+	viper.SetDefault("ExcludedPodOwnerKinds", []string{})
+	viper.SetDefault("ExcludedPodNamespaces", []string{})
+	viper.SetDefault("ExcludedPodLabels", []string{})
+	viper.SetDefault("ExcludedPodPhases", []string{})
+	viper.SetDefault("ActiveDeadlineMargin", 0)
+	viper.SetDefault("EnableAdmissionWebhook", false)
+	viper.SetDefault("AdmissionWebhookPort", 9443)
+	viper.SetDefault("AdmissionWebhookCertDir", "")
+	viper.SetDefault("AdmissionWebhookServiceName", "k8s-shredder")
+	viper.SetDefault("AdmissionWebhookConfigurationName", "k8s-shredder")
+	viper.SetDefault("NodeConditionsToDetect", []config.NodeConditionDetection{})
+	viper.SetDefault("NodeConditionDetectionInterval", 0)
+	viper.SetDefault("AuditLogPath", "")
+	viper.SetDefault("NodeReportWebhookURL", "")
+	viper.SetDefault("AdminAPITokenFile", "")
+	viper.SetDefault("EnableClusterAutoscalerScaleDown", false)
+	viper.SetDefault("ParkingHandshake", false)
+	viper.SetDefault("ParkingHandshakeAnnotation", "shredder.ethos.adobe.net/prepare-for-park")
+	viper.SetDefault("ParkingHandshakeAckAnnotation", "shredder.ethos.adobe.net/prepare-for-park-ack")
+	viper.SetDefault("ParkingHandshakeTimeout", time.Minute*10)
+	viper.SetDefault("PreParkHookURL", "")
+	viper.SetDefault("PostParkHookURL", "")
+	viper.SetDefault("ParkingHookTimeout", time.Second*10)
+	viper.SetDefault("PreParkHookFailurePolicy", config.ParkingHookFailurePolicyIgnore)
+	viper.SetDefault("MaxNodeLifetime", 0)
+	viper.SetDefault("EnableEKSNodegroupUpgradeDetection", false)
+	viper.SetDefault("EKSNodegroupUpgradeTaints", []string{"eks.amazonaws.com/nodegroup-upgrade"})
+	viper.SetDefault("EKSNodegroupUpgradeLabels", []string{})
+	viper.SetDefault("EnableGKENodeUpgradeDetection", false)
+	viper.SetDefault("GKENodeUpgradeTaints", []string{"cloud.google.com/impending-node-termination"})
+	viper.SetDefault("GKENodeUpgradeLabels", []string{})
+	viper.SetDefault("EnableNodeLabelDetection", false)
+	viper.SetDefault("NodeLabelsToDetect", []string{})
+	viper.SetDefault("NodeLabelsMatchMode", config.NodeLabelsMatchAny)
+	viper.SetDefault("EnableSpotInterruptionDetection", false)
+	viper.SetDefault("SpotInterruptionTaints", []string{"aws-node-termination-handler/spot-itn"})
+	viper.SetDefault("SpotInterruptionConditions", []string{})
+	viper.SetDefault("SpotInterruptionDetectionInterval", time.Second*10)
+	viper.SetDefault("SpotInterruptionTTL", time.Minute*2)
+	viper.SetDefault("UnparkRecoveredNodes", false)
+	viper.SetDefault("UnparkStabilizationPeriod", time.Minute*10)
+	viper.SetDefault("EnableCapacityUnpark", false)
+	viper.SetDefault("CapacityUnparkPendingPods", 10)
+	viper.SetDefault("CapacityReparkPendingPods", 0)
+	viper.SetDefault("CapacityUnparkNodesPerLoop", 1)
+	viper.SetDefault("CapacityUnparkMinDuration", time.Minute*15)
+	viper.SetDefault("CapacityUnparkedAnnotation", "shredder.ethos.adobe.net/capacity-unparked")
+	viper.SetDefault("NodeLockAnnotation", "shredder.ethos.adobe.net/lock")
+	viper.SetDefault("NodeLockTTL", time.Minute*5)
+	viper.SetDefault("RolloutRestartQueueSize", 50)
+	viper.SetDefault("MaxConcurrentNodes", 20)
+	viper.SetDefault("RolloutRestartConcurrency", 1)
+	viper.SetDefault("RolloutRestartDedupTTL", 0)
+	viper.SetDefault("RespectDoNotDisruptAnnotation", false)
+	viper.SetDefault("RespectSafeToEvictAnnotation", false)
+	viper.SetDefault("DeferRestartsDuringHPAScaling", false)
+	viper.SetDefault("HPAStabilizationWindow", time.Minute*5)
+	viper.SetDefault("DeferRestartsDuringCanary", false)
+	viper.SetDefault("FlaggerAPIVersion", "v1beta1")
+	viper.SetDefault("DeferJobEvictions", false)
+	viper.SetDefault("JobEvictionMaxWait", time.Hour)
+	viper.SetDefault("JobNearCompletionRatio", 0)
+	viper.SetDefault("CriticalAPIQPS", 20)
+	viper.SetDefault("CriticalAPIBurst", 40)
+	viper.SetDefault("BackgroundAPIQPS", 5)
+	viper.SetDefault("BackgroundAPIBurst", 10)
+	viper.SetDefault("EnableNodeInformer", true)
+	viper.SetDefault("APIListPageSize", 500)
+	viper.SetDefault("APIRetryAttempts", 5)
+	viper.SetDefault("APIRetryInitialBackoff", time.Millisecond*200)
+	viper.SetDefault("Clusters", []config.ClusterConfig{})
 
 	err := viper.ReadInConfig()
 	if err != nil {
 		log.Fatalf("Failed to discover configuration file %s: %s", cfgFile, err)
 	}
+}
+
+func discoverConfig() {
+	readConfig()
 	viper.WatchConfig()
 	viper.OnConfigChange(func(e fsnotify.Event) {
-		log.Infof("Configuration file `%s` changed, attempting to reload", e.Name)
-		reset()
-		parseConfig()
-		appContext.Config = cfg
-		run(&cobra.Command{}, []string{})
+		reloadConfig(e.Name)
 	})
 }
 
+// reloadConfig applies the new content of the configuration file, logging every changed field
+func reloadConfig(name string) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	log.Infof("Configuration file `%s` changed, attempting to reload", name)
+
+	// viper only logs read errors while watching, so read the file again to find out if the new content is valid
+	err := viper.ReadInConfig()
+	if err != nil {
+		configLoadFailed(errors.Wrapf(err, "failed to read configuration file %s", name))
+		return
+	}
+
+	newCfg, err := loadConfig()
+	if err != nil {
+		configLoadFailed(err)
+		return
+	}
+
+	changes := config.Diff(cfg, newCfg)
+	if len(changes) == 0 {
+		log.Info("Configuration unchanged, nothing to reload")
+		configLoadSucceeded(newCfg)
+		return
+	}
+	for _, change := range changes {
+		log.WithFields(log.Fields{"field": change.Field, "old": change.Old, "new": change.New}).Info("Configuration field changed")
+	}
+
+	if newCfg.AuditLogPath != cfg.AuditLogPath {
+		err = audit.Init(newCfg.AuditLogPath)
+		if err != nil {
+			configLoadFailed(err)
+			return
+		}
+	}
+	configLoadSucceeded(newCfg)
+
+	applied, deferred := newCfg, false
+	if newCfg.DeferTTLReductions {
+		applied, deferred = newCfg.KeepTTLReductions(cfg)
+	}
+	applyConfig(applied)
+
+	if deferred {
+		log.Info("Deferring the parked node TTL reductions until the next eviction loop ended")
+		waiters := make([]<-chan struct{}, 0, len(handlers))
+		for _, h := range handlers {
+			waiters = append(waiters, h.NextLoopEnd())
+		}
+		go applyAfterNextLoop(newCfg, reloadGeneration, waiters)
+	}
+}
+
+// applyConfig restarts the scheduler with a new configuration, once the running eviction loops ended
+func applyConfig(newCfg config.Config) {
+	reset()
+	previousCfg := cfg
+	cfg = newCfg
+	reloadGeneration++
+	// the managed clusters are only read at startup, changing them requires a restart
+	for _, ac := range appContexts {
+		ac.SetConfig(cfg)
+	}
+	reconcileMaxParkedNodes(previousCfg)
+	startScheduler()
+}
+
+// applyAfterNextLoop applies a configuration once the given eviction loops ended, unless another configuration was
+// applied in the meantime
+func applyAfterNextLoop(newCfg config.Config, generation int, waiters []<-chan struct{}) {
+	for _, waiter := range waiters {
+		select {
+		case <-waiter:
+		case <-appContext.StopRequested():
+			return
+		}
+	}
+
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	if reloadGeneration != generation {
+		log.Debug("Configuration reloaded again, dropping the deferred parked node TTL reductions")
+		return
+	}
+	log.Info("Applying the deferred parked node TTL reductions")
+	applyConfig(newCfg)
+}
+
+// reconcileMaxParkedNodes applies MaxParkedNodesLoweredPolicy when a configuration reload lowered MaxParkedNodes
+func reconcileMaxParkedNodes(previousCfg config.Config) {
+	if cfg.MaxParkedNodes <= 0 || (previousCfg.MaxParkedNodes > 0 && cfg.MaxParkedNodes >= previousCfg.MaxParkedNodes) ||
+		cfg.MaxParkedNodesLoweredPolicy == config.MaxParkedNodesLoweredIgnore {
+		return
+	}
+
+	for _, ac := range appContexts {
+		logger := log.WithField("cluster", ac.Cluster)
+		if cfg.MaxParkedNodesLoweredPolicy == config.MaxParkedNodesLoweredWarn {
+			parked, err := utils.CountParkedNodes(ac)
+			if err != nil {
+				logger.Errorf("Failed to count parked nodes: %s", err)
+				continue
+			}
+			if parked > cfg.MaxParkedNodes {
+				logger.Warnf("%d nodes parked, %d more than the new MaxParkedNodes=%d", parked, parked-cfg.MaxParkedNodes, cfg.MaxParkedNodes)
+			}
+			continue
+		}
+
+		unparked, err := utils.EnforceMaxParkedNodes(ac)
+		if err != nil {
+			logger.Errorf("Failed to enforce MaxParkedNodes=%d: %s", cfg.MaxParkedNodes, err)
+		}
+		if len(unparked) > 0 {
+			logger.Warnf("Unparked %s to enforce the new MaxParkedNodes=%d", strings.Join(unparked, ", "), cfg.MaxParkedNodes)
+		}
+	}
+}
+
 func parseConfig() {
-	err := viper.Unmarshal(&cfg)
+	var err error
+	cfg, err = loadConfig()
 	if err != nil {
 		log.Fatalf("Failed to parse configuration: %s", err)
 	}
+	configLoadSucceeded(cfg)
+}
+
+func loadConfig() (config.Config, error) {
+	var c config.Config
+
+	err := viper.Unmarshal(&c, configDecodeHook())
+	if err != nil {
+		return c, errors.Wrap(err, "failed to unmarshal configuration")
+	}
+
+	err = c.Validate()
+	if err != nil {
+		return c, errors.Wrap(err, "invalid configuration")
+	}
+
 	log.WithFields(log.Fields{
-		"EvictionLoopInterval":               cfg.EvictionLoopInterval.String(),
-		"ParkedNodeTTL":                      cfg.ParkedNodeTTL.String(),
-		"RollingRestartThreshold":            cfg.RollingRestartThreshold,
-		"UpgradeStatusLabel":                 cfg.UpgradeStatusLabel,
-		"ExpiresOnLabel":                     cfg.ExpiresOnLabel,
-		"NamespacePrefixSkipInitialEviction": cfg.NamespacePrefixSkipInitialEviction,
-		"RestartedAtAnnotation":              cfg.RestartedAtAnnotation,
-		"AllowEvictionLabel":                 cfg.AllowEvictionLabel,
-		"ToBeDeletedTaint":                   cfg.ToBeDeletedTaint,
-		"ArgoRolloutsAPIVersion":             cfg.ArgoRolloutsAPIVersion,
+		"EvictionLoopInterval":               c.EvictionLoopInterval.String(),
+		"MaxEvictionLoopInterval":            c.MaxEvictionLoopInterval.String(),
+		"ParkedNodeTTL":                      c.ParkedNodeTTL.String(),
+		"TTLOverridesByReason":               c.TTLOverridesByReason,
+		"ExpiryAction":                       c.ExpiryAction,
+		"ExpiryActionsByReason":              c.ExpiryActionsByReason,
+		"ForceEvictDaemonSetPods":            c.ForceEvictDaemonSetPods,
+		"ExpiryWebhookURL":                   c.ExpiryWebhookURL,
+		"RollingRestartThreshold":            c.RollingRestartThreshold,
+		"NoExecuteEscalationThreshold":       c.NoExecuteEscalationThreshold,
+		"UpgradeStatusLabel":                 c.UpgradeStatusLabel,
+		"UpgradeStatusParkedValue":           c.UpgradeStatusParkedValue,
+		"UpgradeStatusUnparkedValue":         c.UpgradeStatusUnparkedValue,
+		"UpgradeStatusSoftParkedValue":       c.UpgradeStatusSoftParkedValue,
+		"SoftParkingDelay":                   c.SoftParkingDelay.String(),
+		"ExpiresOnLabel":                     c.ExpiresOnLabel,
+		"ParkedAtLabel":                      c.ParkedAtLabel,
+		"ParkingReasonLabel":                 c.ParkingReasonLabel,
+		"ParkingReasonMessageAnnotation":     c.ParkingReasonMessageAnnotation,
+		"ParkingBatchLabel":                  c.ParkingBatchLabel,
+		"ParkingBatchAbortedLabel":           c.ParkingBatchAbortedLabel,
+		"ParkedNodeTaint":                    c.ParkedNodeTaint,
+		"ParkedNodeFallbackTaint":            c.ParkedNodeFallbackTaint,
+		"EnableParkedPodLabels":              c.EnableParkedPodLabels,
+		"ParkedPodNamespaceSelector":         c.ParkedPodNamespaceSelector,
+		"ParkedPodLabelSelector":             c.ParkedPodLabelSelector,
+		"ParkedPodLabelingConcurrency":       c.ParkedPodLabelingConcurrency,
+		"EvictionSafetyCheck":                c.EvictionSafetyCheck,
+		"MaxParkedNodes":                     c.MaxParkedNodes,
+		"MaxParkedNodesPerZone":              c.MaxParkedNodesPerZone,
+		"MaxParkedNodesBySource":             c.MaxParkedNodesBySource,
+		"MinClusterHeadroomPercent":          c.MinClusterHeadroomPercent,
+		"ParkingWaveSize":                    c.ParkingWaveSize,
+		"ParkingWaveInterval":                c.ParkingWaveInterval.String(),
+		"ParkingRetryLimit":                  c.ParkingRetryLimit,
+		"ServerSideDryRun":                   c.ServerSideDryRun,
+		"ParkingCooldown":                    c.ParkingCooldown.String(),
+		"UnparkedAtAnnotation":               c.UnparkedAtAnnotation,
+		"DeferTTLReductions":                 c.DeferTTLReductions,
+		"MaxParkedNodesLoweredPolicy":        c.MaxParkedNodesLoweredPolicy,
+		"NamespacePrefixSkipInitialEviction": c.NamespacePrefixSkipInitialEviction,
+		"RestartedAtAnnotation":              c.RestartedAtAnnotation,
+		"AllowEvictionLabel":                 c.AllowEvictionLabel,
+		"SkipEvictionNamespaceAnnotation":    c.SkipEvictionNamespaceAnnotation,
+		"ToBeDeletedTaint":                   c.ToBeDeletedTaint,
+		"ArgoRolloutsAPIVersion":             c.ArgoRolloutsAPIVersion,
+		"OpenKruiseAPIVersion":               c.OpenKruiseAPIVersion,
+		"PausedRolloutPolicy":                c.PausedRolloutPolicy,
+		"PausedRolloutPoliciesByNamespace":   c.PausedRolloutPoliciesByNamespace,
+		"PausedRolloutEscalationThreshold":   c.PausedRolloutEscalationThreshold,
+		"PausedRolloutAnnotation":            c.PausedRolloutAnnotation,
+		"EnableKubeVirtLiveMigration":        c.EnableKubeVirtLiveMigration,
+		"KubeVirtAPIVersion":                 c.KubeVirtAPIVersion,
+		"EvictionDeleteFallback":             c.EvictionDeleteFallback,
+		"EvictionDeleteFallbackRetries":      c.EvictionDeleteFallbackRetries,
+		"EvictionDeleteFallbackBeforeExpiry": c.EvictionDeleteFallbackBeforeExpiry.String(),
+		"ForceEvictionTiers":                 c.ForceEvictionTiers,
+		"ForceEvictionTierAnnotation":        c.ForceEvictionTierAnnotation,
+		"ForceEvictionStaggerWindow":         c.ForceEvictionStaggerWindow.String(),
+		"PodEvictionDeadlineAnnotation":      c.PodEvictionDeadlineAnnotation,
+		"OrphanedPodsCleanupInterval":        c.OrphanedPodsCleanupInterval.String(),
+		"NodeStateAnnotation":                c.NodeStateAnnotation,
+		"OrderedEvictionAnnotation":          c.OrderedEvictionAnnotation,
+		"EvictionCostAnnotation":             c.EvictionCostAnnotation,
+		"ProtectedNodeLabels":                c.ProtectedNodeLabels,
+		"ExcludedNodeNames":                  c.ExcludedNodeNames,
+		"ExcludedPodOwnerKinds":              c.ExcludedPodOwnerKinds,
+		"ExcludedPodNamespaces":              c.ExcludedPodNamespaces,
+		"ExcludedPodLabels":                  c.ExcludedPodLabels,
+		"ExcludedPodPhases":                  c.ExcludedPodPhases,
+		"ActiveDeadlineMargin":               c.ActiveDeadlineMargin,
+		"EnableAdmissionWebhook":             c.EnableAdmissionWebhook,
+		"AdmissionWebhookPort":               c.AdmissionWebhookPort,
+		"AdmissionWebhookCertDir":            c.AdmissionWebhookCertDir,
+		"AdmissionWebhookServiceName":        c.AdmissionWebhookServiceName,
+		"AdmissionWebhookConfigurationName":  c.AdmissionWebhookConfigurationName,
+		"NodeConditionsToDetect":             c.NodeConditionsToDetect,
+		"NodeConditionDetectionInterval":     c.NodeConditionDetectionInterval.String(),
+		"AuditLogPath":                       c.AuditLogPath,
+		"NodeReportWebhookURL":               c.NodeReportWebhookURL,
+		"AdminAPITokenFile":                  c.AdminAPITokenFile,
+		"EnableClusterAutoscalerScaleDown":   c.EnableClusterAutoscalerScaleDown,
+		"ParkingHandshake":                   c.ParkingHandshake,
+		"ParkingHandshakeAnnotation":         c.ParkingHandshakeAnnotation,
+		"ParkingHandshakeAckAnnotation":      c.ParkingHandshakeAckAnnotation,
+		"ParkingHandshakeTimeout":            c.ParkingHandshakeTimeout.String(),
+		"PreParkHookURL":                     c.PreParkHookURL,
+		"PostParkHookURL":                    c.PostParkHookURL,
+		"ParkingHookTimeout":                 c.ParkingHookTimeout.String(),
+		"PreParkHookFailurePolicy":           c.PreParkHookFailurePolicy,
+		"MaxNodeLifetime":                    c.MaxNodeLifetime.String(),
+		"EnableEKSNodegroupUpgradeDetection": c.EnableEKSNodegroupUpgradeDetection,
+		"EKSNodegroupUpgradeTaints":          c.EKSNodegroupUpgradeTaints,
+		"EKSNodegroupUpgradeLabels":          c.EKSNodegroupUpgradeLabels,
+		"EnableGKENodeUpgradeDetection":      c.EnableGKENodeUpgradeDetection,
+		"GKENodeUpgradeTaints":               c.GKENodeUpgradeTaints,
+		"GKENodeUpgradeLabels":               c.GKENodeUpgradeLabels,
+		"EnableNodeLabelDetection":           c.EnableNodeLabelDetection,
+		"NodeLabelsToDetect":                 c.NodeLabelsToDetect,
+		"NodeLabelsMatchMode":                c.NodeLabelsMatchMode,
+		"EnableSpotInterruptionDetection":    c.EnableSpotInterruptionDetection,
+		"SpotInterruptionTaints":             c.SpotInterruptionTaints,
+		"SpotInterruptionConditions":         c.SpotInterruptionConditions,
+		"SpotInterruptionDetectionInterval":  c.SpotInterruptionDetectionInterval.String(),
+		"SpotInterruptionTTL":                c.SpotInterruptionTTL.String(),
+		"UnparkRecoveredNodes":               c.UnparkRecoveredNodes,
+		"UnparkStabilizationPeriod":          c.UnparkStabilizationPeriod.String(),
+		"EnableCapacityUnpark":               c.EnableCapacityUnpark,
+		"CapacityUnparkPendingPods":          c.CapacityUnparkPendingPods,
+		"CapacityReparkPendingPods":          c.CapacityReparkPendingPods,
+		"CapacityUnparkNodesPerLoop":         c.CapacityUnparkNodesPerLoop,
+		"CapacityUnparkMinDuration":          c.CapacityUnparkMinDuration.String(),
+		"CapacityUnparkedAnnotation":         c.CapacityUnparkedAnnotation,
+		"NodeLockAnnotation":                 c.NodeLockAnnotation,
+		"NodeLockTTL":                        c.NodeLockTTL.String(),
+		"RolloutRestartQueueSize":            c.RolloutRestartQueueSize,
+		"MaxConcurrentNodes":                 c.MaxConcurrentNodes,
+		"RolloutRestartConcurrency":          c.RolloutRestartConcurrency,
+		"RolloutRestartDedupTTL":             c.RolloutRestartDedupTTL.String(),
+		"RespectDoNotDisruptAnnotation":      c.RespectDoNotDisruptAnnotation,
+		"RespectSafeToEvictAnnotation":       c.RespectSafeToEvictAnnotation,
+		"DeferRestartsDuringHPAScaling":      c.DeferRestartsDuringHPAScaling,
+		"HPAStabilizationWindow":             c.HPAStabilizationWindow.String(),
+		"DeferRestartsDuringCanary":          c.DeferRestartsDuringCanary,
+		"FlaggerAPIVersion":                  c.FlaggerAPIVersion,
+		"DeferJobEvictions":                  c.DeferJobEvictions,
+		"JobEvictionMaxWait":                 c.JobEvictionMaxWait.String(),
+		"JobNearCompletionRatio":             c.JobNearCompletionRatio,
+		"CriticalAPIQPS":                     c.CriticalAPIQPS,
+		"CriticalAPIBurst":                   c.CriticalAPIBurst,
+		"BackgroundAPIQPS":                   c.BackgroundAPIQPS,
+		"BackgroundAPIBurst":                 c.BackgroundAPIBurst,
+		"EnableNodeInformer":                 c.EnableNodeInformer,
+		"APIListPageSize":                    c.APIListPageSize,
+		"APIRetryAttempts":                   c.APIRetryAttempts,
+		"APIRetryInitialBackoff":             c.APIRetryInitialBackoff.String(),
+		"Clusters":                           c.Clusters,
 	}).Info("Loaded configuration")
+
+	return c, nil
+}
+
+// configLoadFailed keeps the application running with the last known good configuration while signaling the failure
+func configLoadFailed(err error) {
+	log.Errorf("Failed to reload configuration, keeping the last known good one: %s", err)
+	metrics.ShredderConfigLoadError.Set(1)
+	metrics.SetReadinessError("config", err)
+}
+
+func configLoadSucceeded(c config.Config) {
+	metrics.ShredderConfigLoadError.Set(0)
+	metrics.SetReadinessError("config", nil)
+
+	detectors := []string{}
+	for _, detector := range detection.EnabledDetectors(utils.NewOfflineAppContext(c)) {
+		detectors = append(detectors, detector.Name())
+	}
+	metrics.ShredderConfigInfo.Reset()
+	metrics.ShredderConfigInfo.WithLabelValues(
+		c.EvictionLoopInterval.String(),
+		c.ParkedNodeTTL.String(),
+		strconv.FormatFloat(c.RollingRestartThreshold, 'f', -1, 64),
+		strconv.Itoa(c.MaxParkedNodes),
+		strings.Join(detectors, ","),
+		strconv.FormatBool(dryRun),
+	).Set(1)
 }
 
 func preRun(cmd *cobra.Command, args []string) {
@@ -158,52 +637,291 @@ func preRun(cmd *cobra.Command, args []string) {
 			"GitSHA":    gitSHA,
 			"BuildTime": buildTime,
 		}).Infoln("K8s-shredder info")
+	metrics.ShredderBuildInfo.WithLabelValues(buildVersion, gitSHA, buildTime).Set(1)
 
+	parseRole()
 	setupMetricsServer()
 	discoverConfig()
 	parseConfig()
+	setupAuditLog()
 	setupAppContext(cfg, dryRun)
+	setupAdmissionWebhook()
+	api.Register(currentHandler.Load)
+	api.RegisterAdmin(func() string { return appContext.Config().AdminAPITokenFile }, triggerEvictionLoops, parkNodesByProviderID)
+}
+
+// parseRole parses the --role flag
+func parseRole() {
+	var err error
+	role, err = handler.ParseRole(roleFlag)
+	if err != nil {
+		log.Fatalf("Invalid --role flag: %s", err)
+	}
+}
+
+// loopJobName returns the name of the job running the eviction loops of a cluster, or only its detection with the
+// detector role
+func loopJobName(suffix string) string {
+	if !role.Evicts() {
+		return "detection-loop" + suffix
+	}
+	return "eviction-loop" + suffix
+}
+
+// isLoopJob checks whether a job runs the eviction loops, or the detection, of a cluster
+func isLoopJob(name string) bool {
+	return strings.HasPrefix(name, "eviction-loop") || strings.HasPrefix(name, "detection-loop")
+}
+
+// triggerEvictionLoops runs the eviction loop of every managed cluster right away, or only its detection with the
+// detector role. Loops already running are not run again
+func triggerEvictionLoops() error {
+	s := startedScheduler.Load()
+	if s == nil {
+		return errors.New("scheduler not started yet")
+	}
+
+	for _, job := range (*s).Jobs() {
+		if !isLoopJob(job.Name()) {
+			continue
+		}
+		if err := job.RunNow(); err != nil {
+			return errors.Wrapf(err, "Failed to trigger job %s", job.Name())
+		}
+	}
+	return nil
+}
+
+// triggerEvictionLoop runs the eviction loop job with the given name right away, unless it is already running
+func triggerEvictionLoop(name string) error {
+	s := startedScheduler.Load()
+	if s == nil {
+		return errors.New("scheduler not started yet")
+	}
+
+	for _, job := range (*s).Jobs() {
+		if job.Name() == name {
+			return errors.Wrapf(job.RunNow(), "Failed to trigger job %s", name)
+		}
+	}
+	return errors.Errorf("job %s not found", name)
+}
+
+func setupAuditLog() {
+	err := audit.Init(cfg.AuditLogPath)
+	if err != nil {
+		log.Fatalf("Failed to setup audit log: %s", err)
+	}
 }
 
 func run(cmd *cobra.Command, args []string) {
+	if cfg.EnableNodeInformer {
+		for _, ac := range appContexts {
+			if err := ac.StartNodeInformer(); err != nil {
+				log.Fatalf("Failed to start the node informer: %s", err)
+			}
+		}
+	}
+	if runOnce {
+		runOnceAndExit()
+	}
+	startScheduler()
+
+	// block until a termination signal is received
+	<-appContext.StopRequested()
+	shutdown()
+}
+
+// runOnceAndExit runs a single eviction loop for every managed cluster, so that k8s-shredder can run as a CronJob
+func runOnceAndExit() {
+	var errorCount int64
+	skipped := false
+	for _, ac := range appContexts {
+		h := handler.NewHandler(ac)
+		h.SetRole(role)
+		count, err := h.RunOnce()
+		if err != nil {
+			log.WithField("cluster", ac.Cluster).Errorf("%s", err.Error())
+			skipped = true
+		}
+		errorCount += count
+	}
+	shutdown()
+
+	if errorCount > 0 {
+		log.Errorf("Eviction loop completed with %d errors", errorCount)
+		os.Exit(1)
+	}
+	if skipped {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func startScheduler() {
 	var err error
-	scheduler, err = gocron.NewScheduler(gocron.WithLocation(time.UTC))
-	defer func() { _ = scheduler.Shutdown() }()
+	scheduler, err = gocron.NewScheduler(
+		gocron.WithLocation(time.UTC),
+		gocron.WithStopTimeout(shutdownTimeout),
+	)
 
 	if err != nil {
 		log.Fatalf("Failed to create scheduler: %s", err)
 	}
 
-	h := handler.NewHandler(appContext)
+	// handlers are created once, so that the state of the eviction loops survives configuration reloads
+	if handlers == nil {
+		for i, ac := range appContexts {
+			h := handler.NewHandler(ac)
+			h.SetRole(role)
+			// the HTTP API serves the first cluster
+			if i == 0 {
+				currentHandler.Store(h)
+			}
+			handlers = append(handlers, h)
+		}
+	}
+
+	for i, ac := range appContexts {
+		h := handlers[i]
+		scheduleClusterJobs(ac, h)
+		registerReadinessChecks(ac, h)
+	}
+	metrics.RegisterReadinessCheck("scheduler", checkScheduler)
+
+	activeJobs := make([]uuid.UUID, 0)
+	for _, j := range scheduler.Jobs() {
+		activeJobs = append(activeJobs, j.ID())
+	}
+	log.Infoln("Active jobs:", activeJobs)
+
+	scheduler.Start()
+	started := scheduler
+	startedScheduler.Store(&started)
+	log.Info("Scheduler started, happy shredding!")
+}
 
+// scheduleClusterJobs adds the eviction loop job of a cluster and the jobs of its detectors running on their own
+// interval to the scheduler. With the detector role, the eviction loop job only runs the detectors, while the evictor
+// role runs none of them
+func scheduleClusterJobs(ac *utils.AppContext, h *handler.Handler) {
+	logger := log.NewEntry(log.StandardLogger())
+	suffix := ""
+	if ac.Cluster != "" {
+		logger = logger.WithField("cluster", ac.Cluster)
+		suffix = "-" + ac.Cluster
+	}
+
+	task := gocron.NewTask(h.Run)
+	if !role.Evicts() {
+		task = gocron.NewTask(h.RunDetection)
+	}
 	job, err := scheduler.NewJob(
 		gocron.DurationJob(
 			cfg.EvictionLoopInterval,
 		),
-		gocron.NewTask(
-			h.Run,
-		),
+		task,
+		gocron.WithName(loopJobName(suffix)),
+		// never run eviction loops concurrently, skip the runs scheduled while the previous loop is still running
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
 	)
 
 	if err != nil {
-		log.Fatalf("Failed to configure scheduler's job: %s", err)
+		logger.Fatalf("Failed to configure scheduler's job: %s", err)
 	}
 
 	// each job has a unique id
-	log.Infof("Configured scheduler job with ID: %s", job.ID())
+	logger.Infof("Configured scheduler job with ID: %s", job.ID())
 
-	activeJobs := make([]uuid.UUID, 0)
-	for _, j := range scheduler.Jobs() {
-		activeJobs = append(activeJobs, j.ID())
+	if cfg.PodEvictionDeadlineAnnotation != "" && role.Evicts() {
+		job, err := scheduler.NewJob(
+			gocron.DurationJob(
+				cfg.OrphanedPodsCleanupInterval,
+			),
+			gocron.NewTask(
+				h.CleanOrphanedPods,
+			),
+			gocron.WithName("orphaned-pods"+suffix),
+			gocron.WithSingletonMode(gocron.LimitModeReschedule),
+		)
+		if err != nil {
+			logger.Fatalf("Failed to configure scheduler's job for orphaned pods: %s", err)
+		}
+		logger.Infof("Configured scheduler job with ID: %s cleaning orphaned pods every %s", job.ID(), cfg.OrphanedPodsCleanupInterval.String())
 	}
-	log.Infoln("Active jobs:", activeJobs)
 
-	scheduler.Start()
-	log.Info("Scheduler started, happy shredding!")
-	select {}
+	if !role.Detects() {
+		return
+	}
+
+	// detectors with their own interval run independently of the eviction loop
+	for _, detector := range detection.EnabledDetectors(ac) {
+		interval := detector.Interval(cfg)
+		if interval <= 0 {
+			continue
+		}
+
+		task := gocron.NewTask(h.RunDetector, detector)
+		if urgent, ok := detector.(detection.Urgent); ok && urgent.Urgent() && role.Evicts() {
+			// drain the urgent nodes right away instead of waiting for the next eviction loop
+			task = gocron.NewTask(func(detector detection.Detector) {
+				if h.RunDetector(detector) > 0 {
+					if err := triggerEvictionLoop("eviction-loop" + suffix); err != nil {
+						logger.Errorf("Failed to trigger an eviction loop after detector %s: %s", detector.Name(), err)
+					}
+				}
+			}, detector)
+		}
+
+		job, err := scheduler.NewJob(
+			gocron.DurationJob(
+				interval,
+			),
+			task,
+			gocron.WithName(detector.Name()+suffix),
+			gocron.WithSingletonMode(gocron.LimitModeReschedule),
+		)
+		if err != nil {
+			logger.Fatalf("Failed to configure scheduler's job for detector %s: %s", detector.Name(), err)
+		}
+		logger.Infof("Configured scheduler job with ID: %s for detector %s running every %s", job.ID(), detector.Name(), interval.String())
+	}
+}
+
+// registerReadinessChecks makes /readyz fail when the eviction loops of a cluster stopped completing or its APIServer
+// can't be reached
+func registerReadinessChecks(ac *utils.AppContext, h *handler.Handler) {
+	suffix := ""
+	if ac.Cluster != "" {
+		suffix = "-" + ac.Cluster
+	}
+
+	metrics.RegisterReadinessCheck(loopJobName(suffix), func() error {
+		return h.CheckLoopFreshness(2 * ac.Config().EvictionLoopInterval)
+	})
+	metrics.RegisterReadinessCheck("apiserver"+suffix, ac.CheckAPIServer)
+}
+
+// checkScheduler makes sure the eviction loop jobs are scheduled
+func checkScheduler() error {
+	s := startedScheduler.Load()
+	if s == nil {
+		return errors.New("scheduler not started yet")
+	}
+	for _, job := range (*s).Jobs() {
+		if isLoopJob(job.Name()) {
+			return nil
+		}
+	}
+	return errors.New("no eviction loop job registered")
 }
 
 func reset() {
+	// nothing was scheduled when running once
+	if scheduler == nil {
+		return
+	}
+
 	// clear all running jobs and stop the scheduler
 	err := scheduler.StopJobs()
 	if err != nil {
@@ -214,3 +932,33 @@ func reset() {
 		log.Errorf("Failed to shutdown scheduler: %s", err)
 	}
 }
+
+func shutdown() {
+	log.Infof("Shutting down, waiting up to %s for in-flight evictions to finish", shutdownTimeout.String())
+
+	// the termination signal only stopped new work from starting, stopping the scheduler waits up to shutdownTimeout for
+	// the running eviction loop, including its node processing goroutines, to complete their API calls
+	reset()
+	// abort whatever is still in flight after the timeout
+	for _, ac := range appContexts {
+		ac.Cancel()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if webhookServer != nil {
+		err := webhookServer.Shutdown(ctx)
+		if err != nil {
+			log.Errorf("Failed to shutdown admission webhook server: %s", err)
+		}
+	}
+
+	// let in-flight scrapes complete so the final metric values are not lost
+	err := metrics.Shutdown(ctx)
+	if err != nil {
+		log.Errorf("Failed to shutdown metrics server: %s", err)
+	}
+
+	log.Info("Shutdown completed, bye!")
+}
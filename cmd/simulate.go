@@ -0,0 +1,73 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/adobe/k8s-shredder/pkg/handler"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var simulateCmd = &cobra.Command{
+	Use:   "simulate",
+	Short: "Simulate parking the nodes matched by the enabled detectors",
+	Long: `Runs the enabled detectors against the live cluster state and reports how many pods per namespace would
+need rescheduling if the nodes they matched were parked, whether the capacity left on the other nodes suffices
+and which PodDisruptionBudgets would block the evictions. Nothing is changed in the cluster.`,
+	Args: cobra.NoArgs,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// simulating must never act on the cluster
+		dryRun = true
+		cliPreRun(cmd, args)
+	},
+	Run: simulate,
+}
+
+func init() {
+	rootCmd.AddCommand(simulateCmd)
+}
+
+func simulate(cmd *cobra.Command, args []string) {
+	s, err := handler.NewHandler(appContext).Simulate()
+	if err != nil {
+		log.Fatalf("Failed to simulate parking: %s", err)
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Nodes (%d):\n", len(s.Nodes))
+	for _, node := range s.Nodes {
+		fmt.Fprintf(out, "  - %s: %d pods, matched by %s\n", node.Name, node.Pods, node.Source)
+	}
+
+	namespaces := make([]string, 0, len(s.PodsByNamespace))
+	for namespace := range s.PodsByNamespace {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	fmt.Fprintln(out, "Pods to reschedule:")
+	for _, namespace := range namespaces {
+		fmt.Fprintf(out, "  - %s: %d\n", namespace, s.PodsByNamespace[namespace])
+	}
+
+	fmt.Fprintf(out, "Requested: cpu %s, memory %s\n", s.Requested.Cpu().String(), s.Requested.Memory().String())
+	fmt.Fprintf(out, "Available: cpu %s, memory %s\n", s.Available.Cpu().String(), s.Available.Memory().String())
+	fmt.Fprintf(out, "Capacity sufficient: %t\n", s.CapacitySufficient)
+
+	fmt.Fprintln(out, "Blocking PodDisruptionBudgets:")
+	for _, pdb := range s.BlockingPDBs {
+		fmt.Fprintf(out, "  - %s/%s: %d pods, %d disruptions allowed\n", pdb.Namespace, pdb.Name, pdb.Pods, pdb.DisruptionsAllowed)
+	}
+}
@@ -130,7 +130,7 @@ func TestNodeIsCleanedUp(t *testing.T) {
 	h := handler.NewHandler(appContext)
 
 	// Wait for node TTL to expire
-	expirationTime, err := utils.GetParkedNodeExpiryTime(*node, appContext.Config.ExpiresOnLabel)
+	expirationTime, err := utils.GetParkedNodeExpiryTime(*node, appContext.Config().ExpiresOnLabel)
 	if err != nil {
 		t.Fatalf("Failed to get expiration time for the parked node %s: %s", parkedWorkerNode, err)
 	}
@@ -203,7 +203,7 @@ func TestArgoRolloutRestartAt(t *testing.T) {
 
 	gvr := schema.GroupVersionResource{
 		Group:    "argoproj.io",
-		Version:  appContext.Config.ArgoRolloutsAPIVersion,
+		Version:  appContext.Config().ArgoRolloutsAPIVersion,
 		Resource: "rollouts",
 	}
 
@@ -0,0 +1,131 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/adobe/k8s-shredder/pkg/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// TokenFileProvider returns the path of the file holding the admin API bearer token, the admin API being disabled when empty
+type TokenFileProvider func() string
+
+// ParkByProviderIDFunc parks the nodes matching the given provider IDs for batch, returning the names of the parked nodes
+// along with the provider IDs matching no node
+type ParkByProviderIDFunc func(providerIDs []string, batch string) ([]string, []string, error)
+
+// parkRequest is the body of the admin API park requests
+type parkRequest struct {
+	ProviderIDs []string `json:"providerIDs"`
+	Batch       string   `json:"batch"`
+}
+
+// parkResponse is the reply to the admin API park requests
+type parkResponse struct {
+	Parked     []string `json:"parked"`
+	Unresolved []string `json:"unresolved"`
+}
+
+// RegisterAdmin adds the admin API endpoints to the default HTTP mux, served by the metrics server. runLoop triggers an
+// immediate eviction loop outside the schedule and park parks nodes given by provider ID. The pause endpoints stop and
+// resume every mutating action
+func RegisterAdmin(tokenFile TokenFileProvider, runLoop func() error, park ParkByProviderIDFunc) {
+	http.HandleFunc("POST /admin/pause", withToken(tokenFile, func(res http.ResponseWriter, req *http.Request) {
+		reason := req.URL.Query().Get("reason")
+		log.WithFields(log.Fields{"remote": req.RemoteAddr, "reason": reason}).Info("Pause requested through the admin API")
+		writeJSON(res, http.StatusOK, utils.Pause(reason))
+	}))
+
+	http.HandleFunc("POST /admin/resume", withToken(tokenFile, func(res http.ResponseWriter, req *http.Request) {
+		log.WithField("remote", req.RemoteAddr).Info("Resume requested through the admin API")
+		writeJSON(res, http.StatusOK, utils.Resume())
+	}))
+
+	http.HandleFunc("GET /admin/pause", withToken(tokenFile, func(res http.ResponseWriter, req *http.Request) {
+		writeJSON(res, http.StatusOK, utils.PauseStatus())
+	}))
+
+	http.HandleFunc("POST /admin/run-loop", withToken(tokenFile, func(res http.ResponseWriter, req *http.Request) {
+		log.WithField("remote", req.RemoteAddr).Info("Eviction loop triggered through the admin API")
+		if err := runLoop(); err != nil {
+			writeError(res, err)
+			return
+		}
+		writeJSON(res, http.StatusAccepted, map[string]string{"status": "eviction loop triggered"})
+	}))
+
+	http.HandleFunc("POST /admin/park", withToken(tokenFile, func(res http.ResponseWriter, req *http.Request) {
+		var body parkRequest
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			writeJSON(res, http.StatusBadRequest, map[string]string{"error": "invalid request body: " + err.Error()})
+			return
+		}
+		if len(body.ProviderIDs) == 0 {
+			writeJSON(res, http.StatusBadRequest, map[string]string{"error": "providerIDs must not be empty"})
+			return
+		}
+
+		log.WithFields(log.Fields{"remote": req.RemoteAddr, "providerIDs": body.ProviderIDs, "batch": body.Batch}).Info("Parking requested through the admin API")
+		parked, unresolved, err := park(body.ProviderIDs, body.Batch)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+		writeJSON(res, http.StatusOK, parkResponse{Parked: parked, Unresolved: unresolved})
+	}))
+}
+
+// withToken rejects the requests missing the admin API bearer token. The token file is read on every request, so that
+// the token can be rotated without restarting k8s-shredder
+func withToken(tokenFile TokenFileProvider, fn http.HandlerFunc) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		path := tokenFile()
+		if path == "" {
+			writeJSON(res, http.StatusForbidden, map[string]string{"error": "admin API disabled, AdminAPITokenFile is not set"})
+			return
+		}
+
+		token, err := readToken(path)
+		if err != nil {
+			log.Errorf("Failed to read the admin API token: %s", err.Error())
+			writeJSON(res, http.StatusInternalServerError, map[string]string{"error": "admin API token unavailable"})
+			return
+		}
+
+		provided, found := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			writeJSON(res, http.StatusUnauthorized, map[string]string{"error": "invalid or missing bearer token"})
+			return
+		}
+		fn(res, req)
+	}
+}
+
+func readToken(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", errors.Errorf("%s is empty", path)
+	}
+	return token, nil
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/adobe/k8s-shredder/pkg/handler"
+	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// HandlerProvider returns the handler running the eviction loops, which is replaced whenever the configuration is reloaded
+type HandlerProvider func() *handler.Handler
+
+// Register adds the read-only API endpoints to the default HTTP mux, served by the metrics server
+func Register(provider HandlerProvider) {
+	http.HandleFunc("GET /api/v1/parked-nodes", withHandler(provider, func(res http.ResponseWriter, req *http.Request, h *handler.Handler) {
+		nodes, err := h.ParkedNodes()
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+		writeJSON(res, http.StatusOK, nodes)
+	}))
+
+	http.HandleFunc("GET /api/v1/nodes/{name}/pods", withHandler(provider, func(res http.ResponseWriter, req *http.Request, h *handler.Handler) {
+		pods, err := h.NodePods(req.PathValue("name"))
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+		writeJSON(res, http.StatusOK, pods)
+	}))
+
+	http.HandleFunc("GET /api/v1/upcoming-evictions", withHandler(provider, func(res http.ResponseWriter, req *http.Request, h *handler.Handler) {
+		writeJSON(res, http.StatusOK, h.UpcomingEvictions())
+	}))
+
+	http.HandleFunc("GET /api/v1/loop-status", withHandler(provider, func(res http.ResponseWriter, req *http.Request, h *handler.Handler) {
+		writeJSON(res, http.StatusOK, h.Status())
+	}))
+}
+
+// withHandler replies with 503 until the handler running the eviction loops is available
+func withHandler(provider HandlerProvider, fn func(http.ResponseWriter, *http.Request, *handler.Handler)) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		h := provider()
+		if h == nil {
+			writeJSON(res, http.StatusServiceUnavailable, map[string]string{"error": "eviction loop not started yet"})
+			return
+		}
+		fn(res, req, h)
+	}
+}
+
+func writeError(res http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if apierrors.IsNotFound(err) {
+		status = http.StatusNotFound
+	}
+	writeJSON(res, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(res http.ResponseWriter, status int, body interface{}) {
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	if err := json.NewEncoder(res).Encode(body); err != nil {
+		log.Errorln("Error while replying to API request:", err)
+	}
+}
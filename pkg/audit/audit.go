@@ -0,0 +1,135 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package audit
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ActionPark is recorded when a node is labeled, cordoned and tainted as parked
+	ActionPark = "park"
+	// ActionSoftPark is recorded when a node is labeled and tainted with PreferNoSchedule as soft parked
+	ActionSoftPark = "soft-park"
+	// ActionRepark is recorded when the TTL of an expired parked node is reset by the eviction safety check
+	ActionRepark = "repark"
+	// ActionEscalateTaint is recorded when the effect of the taint of a parked node is escalated to NoExecute
+	ActionEscalateTaint = "escalate-taint"
+	// ActionUnpark is recorded when the parking of a node is reverted
+	ActionUnpark = "unpark"
+	// ActionEvict is recorded when a pod is evicted
+	ActionEvict = "evict"
+	// ActionDelete is recorded when a pod is deleted
+	ActionDelete = "delete"
+	// ActionLiveMigrate is recorded when a KubeVirt VirtualMachineInstance is live migrated off a parked node
+	ActionLiveMigrate = "live-migrate"
+	// ActionRestart is recorded when a controller object is rollout restarted
+	ActionRestart = "restart"
+	// ActionReport is recorded along with the end-of-life report of a drained parked node
+	ActionReport = "report"
+	// ActionInjectCABundle is recorded when the admission webhook CA bundle is injected into its configuration
+	ActionInjectCABundle = "inject-ca-bundle"
+)
+
+// Entry identifies a mutating API call and the object it targets
+type Entry struct {
+	Action    string
+	Kind      string
+	Namespace string
+	Name      string
+	Node      string
+	DryRun    bool
+	// Details holds additional fields written along with the record
+	Details map[string]interface{}
+}
+
+var (
+	mu     sync.Mutex
+	logger *log.Logger
+	output io.Closer
+)
+
+// Init sets up the audit log, writing one JSON record per line to the file at the given path, or to stdout when the
+// path is "-". An empty path disables the audit log. On success, any previously opened audit log file is closed.
+func Init(path string) error {
+	var newLogger *log.Logger
+	var newOutput io.Closer
+
+	if path != "" {
+		var writer io.Writer = os.Stdout
+		if path != "-" {
+			file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+			if err != nil {
+				return errors.Wrapf(err, "failed to open audit log file %s", path)
+			}
+			writer = file
+			newOutput = file
+		}
+
+		newLogger = log.New()
+		newLogger.SetOutput(writer)
+		newLogger.SetFormatter(&log.JSONFormatter{})
+		newLogger.SetLevel(log.InfoLevel)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if output != nil {
+		_ = output.Close()
+	}
+	logger, output = newLogger, newOutput
+
+	return nil
+}
+
+// Record writes an audit record for a mutating API call started at the given time, err being its result
+func Record(entry Entry, start time.Time, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if logger == nil {
+		return
+	}
+
+	fields := log.Fields{
+		"action":     entry.Action,
+		"kind":       entry.Kind,
+		"name":       entry.Name,
+		"dry_run":    entry.DryRun,
+		"latency_ms": time.Since(start).Milliseconds(),
+		"result":     "success",
+	}
+	if entry.Namespace != "" {
+		fields["namespace"] = entry.Namespace
+	}
+	if entry.Node != "" {
+		fields["node"] = entry.Node
+	}
+	if err != nil {
+		fields["result"] = "error"
+		fields["error"] = err.Error()
+	}
+	for k, v := range entry.Details {
+		if _, found := fields[k]; !found {
+			fields[k] = v
+		}
+	}
+
+	logger.WithFields(fields).Info("audit")
+}
@@ -11,28 +11,756 @@ governing permissions and limitations under the License.
 
 package config
 
-import "time"
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/exp/slices"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// MaxParkedNodesLoweredPolicy values
+const (
+	// MaxParkedNodesLoweredIgnore leaves the parked nodes alone, no other node is parked until enough of them are drained
+	MaxParkedNodesLoweredIgnore = "ignore"
+	// MaxParkedNodesLoweredWarn logs a warning along with the number of extra parked nodes
+	MaxParkedNodesLoweredWarn = "warn"
+	// MaxParkedNodesLoweredEnforce unparks the most recently parked nodes until MaxParkedNodes is honored
+	MaxParkedNodesLoweredEnforce = "enforce"
+)
+
+// ParkingHookFailurePolicy values
+const (
+	// ParkingHookFailurePolicyIgnore parks the node anyway when the pre-park hook can't be reached or fails
+	ParkingHookFailurePolicyIgnore = "ignore"
+	// ParkingHookFailurePolicyFail leaves the node unparked when the pre-park hook can't be reached or fails
+	ParkingHookFailurePolicyFail = "fail"
+)
+
+// SpotInterruptionReason is the parking reason of the nodes parked by the spot interruption detector
+const SpotInterruptionReason = "spot-interruption"
+
+// NodeLabelsMatchMode values
+const (
+	// NodeLabelsMatchAny parks the nodes matching any of the NodeLabelsToDetect selectors
+	NodeLabelsMatchAny = "any"
+	// NodeLabelsMatchAll parks the nodes matching all the NodeLabelsToDetect selectors
+	NodeLabelsMatchAll = "all"
+)
+
+// PausedRolloutPolicy values
+const (
+	// PausedRolloutPolicyEvict evicts the pods of paused Argo Rollouts one by one
+	PausedRolloutPolicyEvict = "evict"
+	// PausedRolloutPolicyResumeRestart resumes paused Argo Rollouts along with their rollout restart
+	PausedRolloutPolicyResumeRestart = "resume-restart"
+	// PausedRolloutPolicyNotify annotates paused Argo Rollouts and records a warning event on them, evicting their pods
+	PausedRolloutPolicyNotify = "notify"
+)
+
+// ExpiryAction values
+const (
+	// ExpiryActionForceDelete deletes the pods left on an expired parked node
+	ExpiryActionForceDelete = "force-delete"
+	// ExpiryActionNoExecuteTaint switches the ParkedNodeTaint effect to NoExecute, leaving the pods to the taint manager
+	ExpiryActionNoExecuteTaint = "no-execute-taint"
+	// ExpiryActionNotify only records a warning event on the expired parked node
+	ExpiryActionNotify = "notify"
+	// ExpiryActionWebhook posts the expired parked node and its pods to ExpiryWebhookURL
+	ExpiryActionWebhook = "webhook"
+)
 
 // Config struct defines application configuration options
 type Config struct {
 	// EvictionLoopInterval defines how often to run the eviction loop process
 	EvictionLoopInterval time.Duration
+	// MaxEvictionLoopInterval caps how much the interval is stretched when an eviction loop takes longer than
+	// EvictionLoopInterval. Stretching is disabled unless it is greater than EvictionLoopInterval, the default 0 included
+	MaxEvictionLoopInterval time.Duration
 	// ParkedNodeTTL is used for defining the time a node can stay parked before starting force eviction process
 	ParkedNodeTTL time.Duration
+	// TTLOverridesByReason overrides ParkedNodeTTL for the nodes parked on behalf of the given sources (detector names, `cli`)
+	TTLOverridesByReason map[string]time.Duration
+	// ExpiryAction is what happens to the pods left on a parked node once its TTL expired: `force-delete`,
+	// `no-execute-taint`, `notify` or `webhook`
+	ExpiryAction string
+	// ExpiryActionsByReason overrides ExpiryAction for the nodes parked on behalf of the given sources (detector names, `cli`)
+	ExpiryActionsByReason map[string]string
+	// ForceEvictDaemonSetPods also deletes the DaemonSet pods of the expired parked nodes with the `force-delete` ExpiryAction
+	ForceEvictDaemonSetPods bool
+	// ExpiryWebhookURL receives the expired parked nodes and their pods as a JSON POST request, with the `webhook` ExpiryAction
+	ExpiryWebhookURL string
 	// RollingRestartThreshold specifies how much time(percentage) should pass from ParkedNodeTTL before starting the rollout restart process
 	RollingRestartThreshold float64
+	// NoExecuteEscalationThreshold specifies how much time(percentage) should pass from ParkedNodeTTL before switching the ParkedNodeTaint effect to NoExecute, 0 disables the escalation
+	NoExecuteEscalationThreshold float64
 	// UpgradeStatusLabel is used for identifying parked nodes
 	UpgradeStatusLabel string
+	// UpgradeStatusParkedValue is the UpgradeStatusLabel value of parked nodes
+	UpgradeStatusParkedValue string
+	// UpgradeStatusUnparkedValue is the UpgradeStatusLabel value set when unparking nodes, empty removes the label
+	UpgradeStatusUnparkedValue string
+	// UpgradeStatusSoftParkedValue is the UpgradeStatusLabel value of soft parked nodes
+	UpgradeStatusSoftParkedValue string
+	// SoftParkingDelay soft parks the nodes for that long before parking them: they are labeled and get the
+	// ParkedNodeTaint with the PreferNoSchedule effect, but are neither cordoned nor drained. 0 parks the nodes right away
+	SoftParkingDelay time.Duration
 	// ExpiresOnLabel is used for identifying the TTL for parked nodes
 	ExpiresOnLabel string
+	// ParkedAtLabel is used for recording when a node got parked
+	ParkedAtLabel string
+	// ParkingReasonLabel is used for recording which detector parked a node
+	ParkingReasonLabel string
+	// ParkingReasonMessageAnnotation is used for recording a human-readable explanation of why a node got parked, an
+	// empty value disables it
+	ParkingReasonMessageAnnotation string
+	// ParkingBatchLabel is used for identifying the rollout a node was parked for
+	ParkingBatchLabel string
+	// ParkingBatchAbortedLabel is used for marking the nodes of an aborted parking batch
+	ParkingBatchAbortedLabel string
+	// ParkedNodeTaint is the taint, in `key=value:Effect` format, applied to the nodes parked by k8s-shredder
+	ParkedNodeTaint string
+	// ParkedNodeFallbackTaint is the taint, in `key=value:Effect` format, applied instead of ParkedNodeTaint when the
+	// APIServer rejects it, e.g. because of an admission policy restricting the taint keys. Empty leaves such nodes
+	// unparked. Its effect is ignored, the one of ParkedNodeTaint being used
+	ParkedNodeFallbackTaint string
+	// EnableParkedPodLabels labels the pods of the nodes parked by k8s-shredder with UpgradeStatusLabel and ExpiresOnLabel,
+	// DaemonSet and static pods excluded
+	EnableParkedPodLabels bool
+	// ParkedPodNamespaceSelector is the label selector of the namespaces whose pods get the parking labels, an empty value
+	// selects all namespaces
+	ParkedPodNamespaceSelector string
+	// ParkedPodLabelSelector is the label selector of the pods getting the parking labels, an empty value selects all pods
+	ParkedPodLabelSelector string
+	// ParkedPodLabelingConcurrency is the number of pods whose parking labels are updated at the same time
+	ParkedPodLabelingConcurrency int
+	// EvictionSafetyCheck parks an expired node again, resetting its TTL, instead of force deleting its pods when some of
+	// the pods getting the parking labels don't have them, so that they are given notice first. It requires
+	// EnableParkedPodLabels
+	EvictionSafetyCheck bool
+	// MaxParkedNodes limits how many nodes can be parked at the same time by k8s-shredder, 0 means no limit
+	MaxParkedNodes int
+	// MaxParkedNodesPerZone limits how many nodes of the same availability zone can be parked at the same time, either as
+	// an absolute number or as a percentage of the zone's nodes (e.g. `10%`). An empty value means no limit
+	MaxParkedNodesPerZone string
+	// MaxParkedNodesBySource limits how many nodes parked on behalf of the given sources (detector names, `cli`) can be
+	// parked at the same time, either as a number or as a percentage of the cluster nodes
+	MaxParkedNodesBySource map[string]string
+	// MinClusterHeadroomPercent is the share of the schedulable CPU and memory which must stay free after parking nodes, 0 disables the check
+	MinClusterHeadroomPercent float64
+	// ParkingWaveSize parks the nodes in waves of at most that many nodes, one wave every ParkingWaveInterval, urgent nodes
+	// excluded. 0 parks all the eligible nodes at once
+	ParkingWaveSize int
+	// ParkingWaveInterval is how long a parking wave lasts before the next one can start
+	ParkingWaveInterval time.Duration
+	// ParkingRetryLimit is how many times parking a node that failed is retried during the next eviction loops, 0 disables retries
+	ParkingRetryLimit int
+	// ServerSideDryRun sends the node and pod updates of the parking operations to the API server as dry-run requests in
+	// dry-run mode, instead of skipping them, so that they go through validation and admission webhooks
+	ServerSideDryRun bool
+	// ParkingCooldown is how long an unparked node can't be parked again, to avoid flapping when detection signals
+	// oscillate, 0 disables the cooldown
+	ParkingCooldown time.Duration
+	// UnparkedAtAnnotation is used for recording when a node got unparked
+	UnparkedAtAnnotation string
+	// DeferTTLReductions applies the parked node TTL reductions of a configuration reload only after the next eviction loop ended
+	DeferTTLReductions bool
+	// MaxParkedNodesLoweredPolicy is what happens when a configuration reload lowers MaxParkedNodes below the number of
+	// parked nodes: `ignore`, `warn` or `enforce` by unparking the most recently parked nodes
+	MaxParkedNodesLoweredPolicy string
 	// NamespacePrefixSkipInitialEviction is used for proceeding directly with a rollout restart without waiting for the RollingRestartThreshold
 	NamespacePrefixSkipInitialEviction string
 	// RestartedAtAnnotation is used to mark a controller object for rollout restart
 	RestartedAtAnnotation string
 	// AllowEvictionLabel is used for skipping evicting pods that have explicitly set this label on false
 	AllowEvictionLabel string
+	// SkipEvictionNamespaceAnnotation is used for exempting all the pods of a namespace having it set on true from eviction
+	// and rollout restart, until their parked node expires. Empty disables it
+	SkipEvictionNamespaceAnnotation string
 	// ToBeDeletedTaint is used for skipping a subset of parked nodes
 	ToBeDeletedTaint string
 	// ArgoRolloutsAPIVersion is used for specifying the API version from `argoproj.io` apigroup to be used while handling Argo Rollouts objects
 	ArgoRolloutsAPIVersion string
+	// OpenKruiseAPIVersion is used for specifying the API version from `apps.kruise.io` apigroup to be used while handling OpenKruise CloneSets and Advanced StatefulSets
+	OpenKruiseAPIVersion string
+	// PausedRolloutPolicy is how the paused Argo Rollouts running pods on parked nodes are handled once
+	// PausedRolloutEscalationThreshold is reached: `evict`, `resume-restart` or `notify`
+	PausedRolloutPolicy string
+	// PausedRolloutPoliciesByNamespace overrides PausedRolloutPolicy for the Argo Rollouts of the given namespaces
+	PausedRolloutPoliciesByNamespace map[string]string
+	// PausedRolloutEscalationThreshold specifies how much time(percentage) should pass from ParkedNodeTTL before applying the PausedRolloutPolicy
+	PausedRolloutEscalationThreshold float64
+	// PausedRolloutAnnotation is set on the paused Argo Rollouts blocking a parked node with the `notify` PausedRolloutPolicy
+	PausedRolloutAnnotation string
+	// EnableKubeVirtLiveMigration live migrates the KubeVirt VirtualMachineInstances of parked nodes instead of evicting their
+	// virt-launcher pods, falling back to eviction for the VMIs that can't be live migrated
+	EnableKubeVirtLiveMigration bool
+	// KubeVirtAPIVersion is used for specifying the API version from `kubevirt.io` apigroup to be used while handling KubeVirt objects
+	KubeVirtAPIVersion string
+	// EvictionDeleteFallback enables deleting pods whose eviction keeps being rejected with 429 Too Many Requests
+	EvictionDeleteFallback bool
+	// EvictionDeleteFallbackRetries is the number of consecutive rejected evictions before falling back to delete
+	EvictionDeleteFallbackRetries int
+	// EvictionDeleteFallbackBeforeExpiry is how long before the parked node TTL expires the delete fallback is allowed
+	EvictionDeleteFallbackBeforeExpiry time.Duration
+	// ForceEvictionTiers gives the pods of expired parked nodes increasingly shorter grace periods, a grace period of 0
+	// being used all along when empty
+	ForceEvictionTiers []ForceEvictionTier
+	// ForceEvictionStaggerWindow spaces out the force eviction of expired parked nodes from different zones, or running
+	// pods of the same owners, by this duration, 0 disables the stagger
+	ForceEvictionStaggerWindow time.Duration
+	// ForceEvictionTierAnnotation is used for recording the force eviction tier an expired parked node reached
+	ForceEvictionTierAnnotation string
+	// PodEvictionDeadlineAnnotation is used for recording on the pods of parked nodes when they get force evicted, empty disables it
+	PodEvictionDeadlineAnnotation string
+	// OrphanedPodsCleanupInterval is how often the PodEvictionDeadlineAnnotation is removed from the pods whose node is not parked anymore
+	OrphanedPodsCleanupInterval time.Duration
+	// NodeStateAnnotation is used for recording the lifecycle state of the nodes handled by k8s-shredder
+	NodeStateAnnotation string
+	// OrderedEvictionAnnotation is used for marking StatefulSets whose pods must be evicted one by one, in reverse ordinal order
+	OrderedEvictionAnnotation string
+	// EvictionCostAnnotation overrides the pod-deletion-cost annotation when ordering the pod evictions on a parked node
+	EvictionCostAnnotation string
+	// ProtectedNodeLabels is a list of node labels (`key` or `key=value`) identifying nodes that must never be drained
+	ProtectedNodeLabels []string
+	// ExcludedNodeNames is a list of node names that must never be drained
+	ExcludedNodeNames []string
+	// ExcludedPodOwnerKinds is a list of owner kinds (e.g. `Job`) whose pods are never evicted, on top of DaemonSets and static pods
+	ExcludedPodOwnerKinds []string
+	// ExcludedPodNamespaces is a list of namespaces whose pods are never evicted
+	ExcludedPodNamespaces []string
+	// ExcludedPodLabels is a list of pod labels (`key` or `key=value`) identifying pods that are never evicted
+	ExcludedPodLabels []string
+	// ExcludedPodPhases is a list of pod phases (e.g. `Succeeded`, `Failed`) whose pods are never evicted
+	ExcludedPodPhases []string
+	// ActiveDeadlineMargin excludes the pods whose activeDeadlineSeconds ends within this margin, 0 disables it
+	ActiveDeadlineMargin time.Duration
+	// EnableAdmissionWebhook starts an admission webhook server rejecting pods scheduled onto parked nodes
+	EnableAdmissionWebhook bool
+	// AdmissionWebhookPort is the port used by the admission webhook server
+	AdmissionWebhookPort int
+	// AdmissionWebhookCertDir is the directory holding the tls.crt and tls.key files, a self-signed certificate is generated when empty
+	AdmissionWebhookCertDir string
+	// AdmissionWebhookServiceName is the name of the Service exposing the admission webhook server
+	AdmissionWebhookServiceName string
+	// AdmissionWebhookConfigurationName is the name of the ValidatingWebhookConfiguration to inject the self-signed CA bundle into
+	AdmissionWebhookConfigurationName string
+	// NodeConditionsToDetect is a list of node conditions that get a node parked once they held for long enough
+	NodeConditionsToDetect []NodeConditionDetection
+	// NodeConditionDetectionInterval is how often the node condition detection runs, 0 meaning at the beginning of every eviction loop
+	NodeConditionDetectionInterval time.Duration
+	// AuditLogPath is the file receiving one JSON record per mutating API call, "-" for stdout. Empty disables the audit log
+	AuditLogPath string
+	// AdminAPITokenFile is the file holding the bearer token required by the admin API endpoints, which are disabled when empty
+	AdminAPITokenFile string
+	// NodeReportWebhookURL receives the end-of-life report of every drained parked node as a JSON POST request, when set
+	NodeReportWebhookURL string
+	// EnableClusterAutoscalerScaleDown sets the cluster-autoscaler scale-down-disabled annotation of the nodes to false when
+	// parking them, so that cluster-autoscaler removes them once drained
+	EnableClusterAutoscalerScaleDown bool
+	// ParkingHandshake makes k8s-shredder wait for node-local agents to get ready before parking a node
+	ParkingHandshake bool
+	// ParkingHandshakeAnnotation is set on a node to ask node-local agents to prepare for parking
+	ParkingHandshakeAnnotation string
+	// ParkingHandshakeAckAnnotation is set on a node by node-local agents once they are ready for parking
+	ParkingHandshakeAckAnnotation string
+	// ParkingHandshakeTimeout is how long to wait for node-local agents before parking a node anyway
+	ParkingHandshakeTimeout time.Duration
+	// PreParkHookURL is called with a JSON POST request before parking a node, a 4xx response vetoing the parking
+	PreParkHookURL string
+	// PostParkHookURL is called with a JSON POST request once a node got parked
+	PostParkHookURL string
+	// ParkingHookTimeout is how long the parking hooks are waited for
+	ParkingHookTimeout time.Duration
+	// PreParkHookFailurePolicy is whether nodes are parked anyway (`ignore`) or not (`fail`) when the pre-park hook fails
+	// without vetoing, e.g. on timeouts or 5xx responses
+	PreParkHookFailurePolicy string
+	// MaxNodeLifetime enables parking the nodes running for longer than this duration, 0 means no limit
+	MaxNodeLifetime time.Duration
+	// EnableEKSNodegroupUpgradeDetection parks the nodes of EKS managed node groups being upgraded
+	EnableEKSNodegroupUpgradeDetection bool
+	// EKSNodegroupUpgradeTaints are the taint keys marking the EKS nodes being upgraded
+	EKSNodegroupUpgradeTaints []string
+	// EKSNodegroupUpgradeLabels are the labels, as `key` or `key=value`, marking the EKS nodes being upgraded
+	EKSNodegroupUpgradeLabels []string
+	// EnableGKENodeUpgradeDetection parks the GKE nodes being upgraded or about to be terminated
+	EnableGKENodeUpgradeDetection bool
+	// GKENodeUpgradeTaints are the taint keys marking the GKE nodes being upgraded or about to be terminated
+	GKENodeUpgradeTaints []string
+	// GKENodeUpgradeLabels are the labels, as `key` or `key=value`, marking the GKE nodes being upgraded
+	GKENodeUpgradeLabels []string
+	// EnableNodeLabelDetection parks the nodes matching NodeLabelsToDetect
+	EnableNodeLabelDetection bool
+	// NodeLabelsToDetect are label selectors, e.g. `key`, `key=value`, `key in (a,b)`, `!key` or `a=b,c!=d`, label value
+	// regular expressions, e.g. `key~=ami-.*`, or label key prefixes, e.g. `example.com/*`, matching the nodes to park
+	NodeLabelsToDetect []string
+	// NodeLabelsMatchMode is whether the nodes must match any or all the NodeLabelsToDetect selectors
+	NodeLabelsMatchMode string
+	// EnableSpotInterruptionDetection parks right away the nodes about to be reclaimed by the cloud provider
+	EnableSpotInterruptionDetection bool
+	// SpotInterruptionTaints are the keys of the taints signaling an upcoming spot interruption, e.g. set by the AWS Node Termination Handler
+	SpotInterruptionTaints []string
+	// SpotInterruptionConditions are the node condition types signaling an upcoming spot interruption or preemption when True
+	SpotInterruptionConditions []string
+	// SpotInterruptionDetectionInterval is how often the spot interruption detection runs, 0 meaning at the beginning of every eviction loop
+	SpotInterruptionDetectionInterval time.Duration
+	// SpotInterruptionTTL is the time the nodes parked because of a spot interruption stay parked before their pods get force evicted
+	SpotInterruptionTTL time.Duration
+	// UnparkRecoveredNodes unparks the nodes parked by a detector once the reason they were parked for went away
+	UnparkRecoveredNodes bool
+	// UnparkStabilizationPeriod is how long a node must have been healthy again before being unparked
+	UnparkStabilizationPeriod time.Duration
+	// EnableCapacityUnpark temporarily unparks the most recently parked nodes while pods can't be scheduled
+	EnableCapacityUnpark bool
+	// CapacityUnparkPendingPods is the number of unschedulable pods from which parked nodes get unparked
+	CapacityUnparkPendingPods int
+	// CapacityReparkPendingPods is the number of unschedulable pods under which the unparked nodes get parked again
+	CapacityReparkPendingPods int
+	// CapacityUnparkNodesPerLoop is the number of nodes unparked during each eviction loop while pods can't be scheduled
+	CapacityUnparkNodesPerLoop int
+	// CapacityUnparkMinDuration is how long a node stays unparked at least before being parked again
+	CapacityUnparkMinDuration time.Duration
+	// NodeLockAnnotation is used for locking a node while k8s-shredder parks or unparks it, empty disables locking
+	NodeLockAnnotation string
+	// NodeLockTTL is how long a node lock is honored, so that locks left behind by a crashed instance don't block a node forever
+	NodeLockTTL time.Duration
+	// CapacityUnparkedAnnotation is used for marking the nodes temporarily unparked to restore capacity
+	CapacityUnparkedAnnotation string
+	// RolloutRestartQueueSize is the number of controller objects that can wait to be rollout restarted
+	RolloutRestartQueueSize int
+	// MaxConcurrentNodes is the number of parked nodes processed at the same time during an eviction loop
+	MaxConcurrentNodes int
+	// RolloutRestartConcurrency is the number of controller objects that can be rollout restarted at the same time
+	RolloutRestartConcurrency int
+	// RolloutRestartDedupTTL is how long a controller object is not rollout restarted again, 0 meaning for the current eviction loop only
+	RolloutRestartDedupTTL time.Duration
+	// RespectDoNotDisruptAnnotation skips evicting and rollout restarting the pods having the Karpenter do-not-disrupt
+	// annotation set on true, until their parked node expires
+	RespectDoNotDisruptAnnotation bool
+	// RespectSafeToEvictAnnotation skips evicting and rollout restarting the pods having the cluster-autoscaler safe-to-evict
+	// annotation set on false, until their parked node expires
+	RespectSafeToEvictAnnotation bool
+	// DeferRestartsDuringHPAScaling defers the rollout restart of controller objects a HorizontalPodAutoscaler is scaling
+	DeferRestartsDuringHPAScaling bool
+	// HPAStabilizationWindow is how long after the last HorizontalPodAutoscaler scaling a rollout restart is still deferred
+	HPAStabilizationWindow time.Duration
+	// DeferRestartsDuringCanary defers the rollout restart of Argo Rollouts and Flagger Canary targets in the middle of a canary
+	DeferRestartsDuringCanary bool
+	// DeferJobEvictions defers the eviction of the pods run by Jobs, CronJobs included, until the Jobs finish
+	DeferJobEvictions bool
+	// JobEvictionMaxWait is how long after parking, or after expiring for near complete Jobs, the Job pods are waited for
+	JobEvictionMaxWait time.Duration
+	// JobNearCompletionRatio is the ratio of completions from which a Job delays the expiry action of its node, 0 disables it
+	JobNearCompletionRatio float64
+	// FlaggerAPIVersion is used for specifying the API version from `flagger.app` apigroup to be used while handling Flagger Canary objects
+	FlaggerAPIVersion string
+	// CriticalAPIQPS is the client-side rate limit of the time-critical API calls, like evictions, deletions and parking
+	CriticalAPIQPS float32
+	// CriticalAPIBurst is the burst allowed on top of CriticalAPIQPS
+	CriticalAPIBurst int
+	// BackgroundAPIQPS is the client-side rate limit of the API calls that can wait, like detection and read-only API queries
+	BackgroundAPIQPS float32
+	// BackgroundAPIBurst is the burst allowed on top of BackgroundAPIQPS
+	BackgroundAPIBurst int
+	// EnableNodeInformer serves the node lists of the eviction loop and detectors from a shared informer cache, instead of
+	// listing the nodes from the APIServer every time. Toggling it requires a restart
+	EnableNodeInformer bool
+	// APIListPageSize is how many objects are fetched per page when listing nodes and pods, 0 disables pagination
+	APIListPageSize int64
+	// APIRetryAttempts is how many times an API mutation failing with a transient error is attempted, 1 disables retries
+	APIRetryAttempts int
+	// APIRetryInitialBackoff is the delay before the first retry of an API mutation, doubled on every following retry
+	APIRetryInitialBackoff time.Duration
+	// Clusters lists the clusters managed by k8s-shredder, each running its own eviction loop. When empty, the cluster
+	// k8s-shredder runs in (or the current kubeconfig context) is managed
+	Clusters []ClusterConfig
+}
+
+// ClusterConfig describes how to reach a cluster managed by k8s-shredder
+type ClusterConfig struct {
+	// Name identifies the cluster in logs and metrics, so it must be a valid label value
+	Name string
+	// Kubeconfig is the path of the kubeconfig file to use, e.g. mounted from a secret. The default loading rules apply
+	// when empty
+	Kubeconfig string
+	// Context is the kubeconfig context to use, the current context is used when empty
+	Context string
+}
+
+// ForceEvictionTier is a step of the force eviction of the pods of an expired parked node
+type ForceEvictionTier struct {
+	// After is how long after the parked node expiry the tier starts
+	After time.Duration
+	// GracePeriod is the termination grace period of the pods deleted during the tier
+	GracePeriod time.Duration
+}
+
+// NodeConditionDetection describes a node condition that gets a node parked once it held for at least MinDuration
+type NodeConditionDetection struct {
+	// Type of the node condition, e.g. Ready, DiskPressure or KernelDeadlock
+	Type string
+	// Status of the node condition marking the node as bad, one of True, False or Unknown
+	Status string
+	// MinDuration is how long the condition must have had that status before the node gets parked
+	MinDuration time.Duration
+}
+
+// String returns the condition as `Type=Status`
+func (n NodeConditionDetection) String() string {
+	return n.Type + "=" + n.Status
+}
+
+// Validate checks the configuration for values that would break the eviction loop
+func (c *Config) Validate() error {
+	if c.EvictionLoopInterval <= 0 {
+		return errors.Errorf("EvictionLoopInterval must be greater than 0, got %s", c.EvictionLoopInterval.String())
+	}
+	if c.MaxEvictionLoopInterval < 0 {
+		return errors.Errorf("MaxEvictionLoopInterval must not be negative, got %s", c.MaxEvictionLoopInterval.String())
+	}
+	if c.ParkedNodeTTL <= 0 {
+		return errors.Errorf("ParkedNodeTTL must be greater than 0, got %s", c.ParkedNodeTTL.String())
+	}
+	expiryActions := []string{ExpiryActionForceDelete, ExpiryActionNoExecuteTaint, ExpiryActionNotify, ExpiryActionWebhook}
+	if !slices.Contains(expiryActions, c.ExpiryAction) {
+		return errors.Errorf("ExpiryAction must be one of %s, got %s", strings.Join(expiryActions, ", "), c.ExpiryAction)
+	}
+	webhookUsed := c.ExpiryAction == ExpiryActionWebhook
+	for reason, action := range c.ExpiryActionsByReason {
+		if !slices.Contains(expiryActions, action) {
+			return errors.Errorf("ExpiryActionsByReason must be one of %s, got %s for %s", strings.Join(expiryActions, ", "), action, reason)
+		}
+		webhookUsed = webhookUsed || action == ExpiryActionWebhook
+	}
+	if webhookUsed && c.ExpiryWebhookURL == "" {
+		return errors.New("ExpiryWebhookURL must be set when using the webhook ExpiryAction")
+	}
+	for reason, ttl := range c.TTLOverridesByReason {
+		if ttl <= 0 {
+			return errors.Errorf("TTLOverridesByReason must be greater than 0, got %s for %s", ttl.String(), reason)
+		}
+	}
+	if c.RollingRestartThreshold < 0 || c.RollingRestartThreshold > 1 {
+		return errors.Errorf("RollingRestartThreshold must be between 0 and 1, got %v", c.RollingRestartThreshold)
+	}
+	pausedRolloutPolicies := []string{PausedRolloutPolicyEvict, PausedRolloutPolicyResumeRestart, PausedRolloutPolicyNotify}
+	if !slices.Contains(pausedRolloutPolicies, c.PausedRolloutPolicy) {
+		return errors.Errorf("PausedRolloutPolicy must be one of %s, got %s", strings.Join(pausedRolloutPolicies, ", "), c.PausedRolloutPolicy)
+	}
+	for namespace, policy := range c.PausedRolloutPoliciesByNamespace {
+		if !slices.Contains(pausedRolloutPolicies, policy) {
+			return errors.Errorf("PausedRolloutPoliciesByNamespace must be one of %s, got %s for %s", strings.Join(pausedRolloutPolicies, ", "), policy, namespace)
+		}
+	}
+	if c.PausedRolloutEscalationThreshold < 0 || c.PausedRolloutEscalationThreshold > 1 {
+		return errors.Errorf("PausedRolloutEscalationThreshold must be between 0 and 1, got %v", c.PausedRolloutEscalationThreshold)
+	}
+	if c.NoExecuteEscalationThreshold < 0 || c.NoExecuteEscalationThreshold > 1 {
+		return errors.Errorf("NoExecuteEscalationThreshold must be between 0 and 1, got %v", c.NoExecuteEscalationThreshold)
+	}
+	if c.EvictionDeleteFallback && c.EvictionDeleteFallbackRetries < 1 {
+		return errors.Errorf("EvictionDeleteFallbackRetries must be at least 1, got %d", c.EvictionDeleteFallbackRetries)
+	}
+	if c.MaxParkedNodes < 0 {
+		return errors.Errorf("MaxParkedNodes must not be negative, got %d", c.MaxParkedNodes)
+	}
+	if !slices.Contains([]string{MaxParkedNodesLoweredIgnore, MaxParkedNodesLoweredWarn, MaxParkedNodesLoweredEnforce}, c.MaxParkedNodesLoweredPolicy) {
+		return errors.Errorf("MaxParkedNodesLoweredPolicy must be one of %s, %s or %s, got %s",
+			MaxParkedNodesLoweredIgnore, MaxParkedNodesLoweredWarn, MaxParkedNodesLoweredEnforce, c.MaxParkedNodesLoweredPolicy)
+	}
+	if c.MaxParkedNodesPerZone != "" {
+		if _, err := c.MaxParkedNodesInZone(100); err != nil {
+			return err
+		}
+	}
+	if c.ParkingCooldown < 0 {
+		return errors.Errorf("ParkingCooldown must not be negative, got %s", c.ParkingCooldown.String())
+	}
+	if c.ParkingCooldown > 0 && c.UnparkedAtAnnotation == "" {
+		return errors.New("UnparkedAtAnnotation must not be empty when ParkingCooldown is set")
+	}
+	if c.ParkingRetryLimit < 0 {
+		return errors.Errorf("ParkingRetryLimit must not be negative, got %d", c.ParkingRetryLimit)
+	}
+	if !slices.Contains([]string{NodeLabelsMatchAny, NodeLabelsMatchAll}, c.NodeLabelsMatchMode) {
+		return errors.Errorf("NodeLabelsMatchMode must be one of %s, %s, got %s", NodeLabelsMatchAny, NodeLabelsMatchAll, c.NodeLabelsMatchMode)
+	}
+	if _, err := c.NodeLabelSelectors(); err != nil {
+		return err
+	}
+	if c.SpotInterruptionDetectionInterval < 0 {
+		return errors.Errorf("SpotInterruptionDetectionInterval must not be negative, got %s", c.SpotInterruptionDetectionInterval.String())
+	}
+	if c.SpotInterruptionTTL <= 0 {
+		return errors.Errorf("SpotInterruptionTTL must be greater than 0, got %s", c.SpotInterruptionTTL.String())
+	}
+	if c.MinClusterHeadroomPercent < 0 || c.MinClusterHeadroomPercent >= 100 {
+		return errors.Errorf("MinClusterHeadroomPercent must be between 0 and 100, got %v", c.MinClusterHeadroomPercent)
+	}
+	if c.ParkingWaveSize < 0 {
+		return errors.Errorf("ParkingWaveSize must not be negative, got %d", c.ParkingWaveSize)
+	}
+	if c.ParkingWaveSize > 0 && c.ParkingWaveInterval <= 0 {
+		return errors.Errorf("ParkingWaveInterval must be greater than 0 when ParkingWaveSize is set, got %s", c.ParkingWaveInterval.String())
+	}
+	for source := range c.MaxParkedNodesBySource {
+		if _, _, err := c.MaxParkedNodesForSource(source, 100); err != nil {
+			return err
+		}
+	}
+	if c.EnableAdmissionWebhook && (c.AdmissionWebhookPort <= 0 || c.AdmissionWebhookPort > 65535) {
+		return errors.Errorf("AdmissionWebhookPort must be a valid port, got %d", c.AdmissionWebhookPort)
+	}
+	if _, err := ParseTaint(c.ParkedNodeTaint); err != nil {
+		return errors.Wrap(err, "ParkedNodeTaint is invalid")
+	}
+	if c.ParkedNodeFallbackTaint != "" {
+		if _, err := ParseTaint(c.ParkedNodeFallbackTaint); err != nil {
+			return errors.Wrap(err, "ParkedNodeFallbackTaint is invalid")
+		}
+	}
+	if _, err := labels.Parse(c.ParkedPodNamespaceSelector); err != nil {
+		return errors.Wrap(err, "ParkedPodNamespaceSelector is invalid")
+	}
+	if _, err := labels.Parse(c.ParkedPodLabelSelector); err != nil {
+		return errors.Wrap(err, "ParkedPodLabelSelector is invalid")
+	}
+	for _, condition := range c.NodeConditionsToDetect {
+		if condition.Type == "" {
+			return errors.New("NodeConditionsToDetect entries must have a Type")
+		}
+		if !slices.Contains([]string{"True", "False", "Unknown"}, condition.Status) {
+			return errors.Errorf("NodeConditionsToDetect status for %s must be one of True, False or Unknown, got %q", condition.Type, condition.Status)
+		}
+		if condition.MinDuration < 0 {
+			return errors.Errorf("NodeConditionsToDetect minimum duration for %s must not be negative, got %s", condition.Type, condition.MinDuration.String())
+		}
+	}
+	if (c.PreParkHookURL != "" || c.PostParkHookURL != "") && c.ParkingHookTimeout <= 0 {
+		return errors.Errorf("ParkingHookTimeout must be greater than 0, got %s", c.ParkingHookTimeout.String())
+	}
+	if !slices.Contains([]string{ParkingHookFailurePolicyIgnore, ParkingHookFailurePolicyFail}, c.PreParkHookFailurePolicy) {
+		return errors.Errorf("PreParkHookFailurePolicy must be one of %s, %s, got %s", ParkingHookFailurePolicyIgnore, ParkingHookFailurePolicyFail, c.PreParkHookFailurePolicy)
+	}
+	if c.ParkingHandshake && (c.ParkingHandshakeAnnotation == "" || c.ParkingHandshakeAckAnnotation == "") {
+		return errors.New("ParkingHandshakeAnnotation and ParkingHandshakeAckAnnotation must not be empty when ParkingHandshake is enabled")
+	}
+	if c.MaxNodeLifetime < 0 {
+		return errors.Errorf("MaxNodeLifetime must not be negative, got %s", c.MaxNodeLifetime.String())
+	}
+	if c.NodeConditionDetectionInterval < 0 {
+		return errors.Errorf("NodeConditionDetectionInterval must not be negative, got %s", c.NodeConditionDetectionInterval.String())
+	}
+	if c.NodeLockAnnotation != "" && c.NodeLockTTL <= 0 {
+		return errors.Errorf("NodeLockTTL must be greater than 0, got %s", c.NodeLockTTL.String())
+	}
+	if c.DeferJobEvictions && c.JobEvictionMaxWait <= 0 {
+		return errors.Errorf("JobEvictionMaxWait must be greater than 0, got %s", c.JobEvictionMaxWait.String())
+	}
+	if c.JobNearCompletionRatio < 0 || c.JobNearCompletionRatio > 1 {
+		return errors.Errorf("JobNearCompletionRatio must be between 0 and 1, got %v", c.JobNearCompletionRatio)
+	}
+	if c.EnableCapacityUnpark {
+		if c.CapacityReparkPendingPods < 0 || c.CapacityUnparkPendingPods <= c.CapacityReparkPendingPods {
+			return errors.Errorf("CapacityUnparkPendingPods must be greater than CapacityReparkPendingPods, which must not be negative, got %d and %d",
+				c.CapacityUnparkPendingPods, c.CapacityReparkPendingPods)
+		}
+		if c.CapacityUnparkNodesPerLoop < 1 {
+			return errors.Errorf("CapacityUnparkNodesPerLoop must be at least 1, got %d", c.CapacityUnparkNodesPerLoop)
+		}
+		if c.CapacityUnparkMinDuration < 0 {
+			return errors.Errorf("CapacityUnparkMinDuration must not be negative, got %s", c.CapacityUnparkMinDuration.String())
+		}
+		if c.CapacityUnparkedAnnotation == "" {
+			return errors.New("CapacityUnparkedAnnotation must not be empty when EnableCapacityUnpark is set")
+		}
+	}
+	if c.UnparkStabilizationPeriod < 0 {
+		return errors.Errorf("UnparkStabilizationPeriod must not be negative, got %s", c.UnparkStabilizationPeriod.String())
+	}
+	if c.RolloutRestartQueueSize < 0 {
+		return errors.Errorf("RolloutRestartQueueSize must not be negative, got %d", c.RolloutRestartQueueSize)
+	}
+	if c.MaxConcurrentNodes < 1 {
+		return errors.Errorf("MaxConcurrentNodes must be at least 1, got %d", c.MaxConcurrentNodes)
+	}
+	if c.EvictionSafetyCheck && !c.EnableParkedPodLabels {
+		return errors.New("EvictionSafetyCheck requires EnableParkedPodLabels")
+	}
+	if c.ParkedPodLabelingConcurrency < 1 {
+		return errors.Errorf("ParkedPodLabelingConcurrency must be at least 1, got %d", c.ParkedPodLabelingConcurrency)
+	}
+	if c.RolloutRestartConcurrency < 1 {
+		return errors.Errorf("RolloutRestartConcurrency must be at least 1, got %d", c.RolloutRestartConcurrency)
+	}
+	if c.RolloutRestartDedupTTL < 0 {
+		return errors.Errorf("RolloutRestartDedupTTL must not be negative, got %s", c.RolloutRestartDedupTTL.String())
+	}
+	if c.HPAStabilizationWindow < 0 {
+		return errors.Errorf("HPAStabilizationWindow must not be negative, got %s", c.HPAStabilizationWindow.String())
+	}
+	if c.CriticalAPIQPS <= 0 || c.CriticalAPIBurst <= 0 || c.BackgroundAPIQPS <= 0 || c.BackgroundAPIBurst <= 0 {
+		return errors.New("CriticalAPIQPS, CriticalAPIBurst, BackgroundAPIQPS and BackgroundAPIBurst must be greater than 0")
+	}
+	clusterNames := map[string]bool{}
+	for _, cluster := range c.Clusters {
+		if errs := validation.IsValidLabelValue(cluster.Name); cluster.Name == "" || len(errs) > 0 {
+			return errors.Errorf("Clusters must have a name that is a valid label value, got %q", cluster.Name)
+		}
+		if clusterNames[cluster.Name] {
+			return errors.Errorf("Clusters must have unique names, got %s more than once", cluster.Name)
+		}
+		clusterNames[cluster.Name] = true
+	}
+	for i, tier := range c.ForceEvictionTiers {
+		if tier.GracePeriod < 0 {
+			return errors.Errorf("ForceEvictionTiers grace periods must not be negative, got %s", tier.GracePeriod.String())
+		}
+		if i == 0 && tier.After != 0 {
+			return errors.Errorf("ForceEvictionTiers must start right after the node expiry, got %s", tier.After.String())
+		}
+		if i > 0 && tier.After <= c.ForceEvictionTiers[i-1].After {
+			return errors.New("ForceEvictionTiers must be sorted by strictly increasing After")
+		}
+	}
+	if c.PodEvictionDeadlineAnnotation != "" && c.OrphanedPodsCleanupInterval <= 0 {
+		return errors.Errorf("OrphanedPodsCleanupInterval must be greater than 0, got %s", c.OrphanedPodsCleanupInterval.String())
+	}
+	if c.ForceEvictionStaggerWindow < 0 {
+		return errors.Errorf("ForceEvictionStaggerWindow must not be negative, got %s", c.ForceEvictionStaggerWindow.String())
+	}
+	if len(c.ForceEvictionTiers) > 0 && c.ForceEvictionTierAnnotation == "" {
+		return errors.New("ForceEvictionTierAnnotation must not be empty when ForceEvictionTiers are set")
+	}
+	for _, phase := range c.ExcludedPodPhases {
+		if !slices.Contains([]string{string(v1.PodPending), string(v1.PodRunning), string(v1.PodSucceeded), string(v1.PodFailed), string(v1.PodUnknown)}, phase) {
+			return errors.Errorf("ExcludedPodPhases must only contain valid pod phases, got %s", phase)
+		}
+	}
+	if c.ActiveDeadlineMargin < 0 {
+		return errors.Errorf("ActiveDeadlineMargin must not be negative, got %s", c.ActiveDeadlineMargin.String())
+	}
+	if c.NodeStateAnnotation == "" {
+		return errors.New("NodeStateAnnotation must not be empty")
+	}
+	if c.APIListPageSize < 0 {
+		return errors.Errorf("APIListPageSize must not be negative, got %d", c.APIListPageSize)
+	}
+	if c.APIRetryAttempts < 1 {
+		return errors.Errorf("APIRetryAttempts must be at least 1, got %d", c.APIRetryAttempts)
+	}
+	if c.APIRetryInitialBackoff < 0 {
+		return errors.Errorf("APIRetryInitialBackoff must not be negative, got %s", c.APIRetryInitialBackoff.String())
+	}
+	if c.UpgradeStatusLabel == "" || c.ExpiresOnLabel == "" || c.ParkedAtLabel == "" {
+		return errors.New("UpgradeStatusLabel, ExpiresOnLabel and ParkedAtLabel must not be empty")
+	}
+	if errs := validation.IsValidLabelValue(c.UpgradeStatusParkedValue); c.UpgradeStatusParkedValue == "" || len(errs) > 0 {
+		return errors.Errorf("UpgradeStatusParkedValue '%s' is not a valid label value: %s", c.UpgradeStatusParkedValue, strings.Join(errs, ", "))
+	}
+	if errs := validation.IsValidLabelValue(c.UpgradeStatusUnparkedValue); len(errs) > 0 {
+		return errors.Errorf("UpgradeStatusUnparkedValue '%s' is not a valid label value: %s", c.UpgradeStatusUnparkedValue, strings.Join(errs, ", "))
+	}
+	if c.UpgradeStatusUnparkedValue == c.UpgradeStatusParkedValue {
+		return errors.New("UpgradeStatusUnparkedValue must differ from UpgradeStatusParkedValue")
+	}
+	if errs := validation.IsValidLabelValue(c.UpgradeStatusSoftParkedValue); c.UpgradeStatusSoftParkedValue == "" || len(errs) > 0 {
+		return errors.Errorf("UpgradeStatusSoftParkedValue '%s' is not a valid label value: %s", c.UpgradeStatusSoftParkedValue, strings.Join(errs, ", "))
+	}
+	if c.UpgradeStatusSoftParkedValue == c.UpgradeStatusParkedValue || c.UpgradeStatusSoftParkedValue == c.UpgradeStatusUnparkedValue {
+		return errors.New("UpgradeStatusSoftParkedValue must differ from UpgradeStatusParkedValue and UpgradeStatusUnparkedValue")
+	}
+	if c.SoftParkingDelay < 0 {
+		return errors.Errorf("SoftParkingDelay must not be negative, got %s", c.SoftParkingDelay.String())
+	}
+	return nil
+}
+
+// MaxParkedNodesInZone returns how many nodes can be parked in a zone holding zoneSize nodes according to
+// MaxParkedNodesPerZone. Percentages are rounded up so that small zones can still be parked one node at a time
+func (c *Config) MaxParkedNodesInZone(zoneSize int) (int, error) {
+	limit := intstr.Parse(c.MaxParkedNodesPerZone)
+	maxInZone, err := intstr.GetScaledValueFromIntOrPercent(&limit, zoneSize, true)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid MaxParkedNodesPerZone %q", c.MaxParkedNodesPerZone)
+	}
+	if maxInZone < 0 {
+		return 0, errors.Errorf("MaxParkedNodesPerZone must not be negative, got %s", c.MaxParkedNodesPerZone)
+	}
+	return maxInZone, nil
+}
+
+// MaxParkedNodesForSource returns how many nodes can be parked on behalf of source in a cluster holding clusterSize
+// nodes according to MaxParkedNodesBySource, and whether a limit is configured for source at all. Percentages are
+// rounded up like for MaxParkedNodesPerZone
+func (c *Config) MaxParkedNodesForSource(source string, clusterSize int) (int, bool, error) {
+	value, found := c.MaxParkedNodesBySource[source]
+	if !found {
+		return 0, false, nil
+	}
+	limit := intstr.Parse(value)
+	maxForSource, err := intstr.GetScaledValueFromIntOrPercent(&limit, clusterSize, true)
+	if err != nil {
+		return 0, true, errors.Wrapf(err, "invalid MaxParkedNodesBySource %q for %s", value, source)
+	}
+	if maxForSource < 0 {
+		return 0, true, errors.Errorf("MaxParkedNodesBySource must not be negative, got %s for %s", value, source)
+	}
+	return maxForSource, true, nil
+}
+
+// ParkedNodeTTLFor returns the time a node parked on behalf of reason can stay parked, taking TTLOverridesByReason
+// and SpotInterruptionTTL into account
+func (c *Config) ParkedNodeTTLFor(reason string) time.Duration {
+	if ttl, found := c.TTLOverridesByReason[reason]; found {
+		return ttl
+	}
+	if reason == SpotInterruptionReason {
+		return c.SpotInterruptionTTL
+	}
+	return c.ParkedNodeTTL
+}
+
+// ExpiryActionFor returns the ExpiryAction of a node parked on behalf of reason, taking ExpiryActionsByReason into account
+func (c *Config) ExpiryActionFor(reason string) string {
+	if action, found := c.ExpiryActionsByReason[reason]; found {
+		return action
+	}
+	return c.ExpiryAction
+}
+
+// PausedRolloutPolicyFor returns the PausedRolloutPolicy of the Argo Rollouts of a namespace, taking
+// PausedRolloutPoliciesByNamespace into account
+func (c *Config) PausedRolloutPolicyFor(namespace string) string {
+	if policy, found := c.PausedRolloutPoliciesByNamespace[namespace]; found {
+		return policy
+	}
+	return c.PausedRolloutPolicy
+}
+
+// MaxParkedNodeTTL returns the longest time a node can stay parked, whatever the reason it was parked for
+func (c *Config) MaxParkedNodeTTL() time.Duration {
+	maxTTL := c.ParkedNodeTTL
+	for _, ttl := range c.TTLOverridesByReason {
+		maxTTL = max(maxTTL, ttl)
+	}
+	return maxTTL
+}
+
+// ForceEvictionTierAt returns the index of the force eviction tier reached by a parked node that expired since
+// expiredFor, along with the grace period of the tier. The index is -1 when no tier is configured
+func (c *Config) ForceEvictionTierAt(expiredFor time.Duration) (int, time.Duration) {
+	tier := -1
+	gracePeriod := time.Duration(0)
+	for i, t := range c.ForceEvictionTiers {
+		if expiredFor < t.After {
+			break
+		}
+		tier, gracePeriod = i, t.GracePeriod
+	}
+	return tier, gracePeriod
 }
@@ -0,0 +1,126 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+// validConfig returns a configuration passing Validate, holding the defaults that matter for it
+func validConfig() Config {
+	return Config{
+		EvictionLoopInterval:         time.Minute,
+		ParkedNodeTTL:                time.Hour,
+		ExpiryAction:                 ExpiryActionForceDelete,
+		RollingRestartThreshold:      0.5,
+		PausedRolloutPolicy:          PausedRolloutPolicyResumeRestart,
+		MaxParkedNodesLoweredPolicy:  MaxParkedNodesLoweredIgnore,
+		NodeLabelsMatchMode:          NodeLabelsMatchAny,
+		SpotInterruptionTTL:          time.Minute,
+		ParkedNodeTaint:              "shredder.ethos.adobe.net/upgrade-status=parked:NoSchedule",
+		PreParkHookFailurePolicy:     ParkingHookFailurePolicyIgnore,
+		MaxConcurrentNodes:           1,
+		RolloutRestartConcurrency:    1,
+		ParkedPodLabelingConcurrency: 1,
+		CriticalAPIQPS:               50,
+		CriticalAPIBurst:             100,
+		BackgroundAPIQPS:             5,
+		BackgroundAPIBurst:           10,
+		NodeStateAnnotation:          "shredder.ethos.adobe.net/node-state",
+		APIRetryAttempts:             1,
+		UpgradeStatusLabel:           "shredder.ethos.adobe.net/upgrade-status",
+		ExpiresOnLabel:               "shredder.ethos.adobe.net/parked-node-expires-on",
+		ParkedAtLabel:                "shredder.ethos.adobe.net/parked-at",
+		UpgradeStatusParkedValue:     "parked",
+		UpgradeStatusSoftParkedValue: "soft-parked",
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr bool
+	}{
+		{name: "defaults", modify: func(c *Config) {}},
+		{name: "NoExecute parked node taint", modify: func(c *Config) { c.ParkedNodeTaint = "parked:NoExecute" }},
+		{name: "parked node taint without effect", modify: func(c *Config) { c.ParkedNodeTaint = "parked=true" }, wantErr: true},
+		{name: "parked node taint with unknown effect", modify: func(c *Config) { c.ParkedNodeTaint = "parked=true:Drain" }, wantErr: true},
+		{name: "empty parked node taint", modify: func(c *Config) { c.ParkedNodeTaint = "" }, wantErr: true},
+		{name: "valid fallback taint", modify: func(c *Config) { c.ParkedNodeFallbackTaint = "parked=true:NoSchedule" }},
+		{name: "invalid fallback taint", modify: func(c *Config) { c.ParkedNodeFallbackTaint = "parked=true" }, wantErr: true},
+		{name: "zero eviction loop interval", modify: func(c *Config) { c.EvictionLoopInterval = 0 }, wantErr: true},
+		{
+			name:   "max eviction loop interval lower than the interval",
+			modify: func(c *Config) { c.EvictionLoopInterval = time.Hour; c.MaxEvictionLoopInterval = 10 * time.Minute },
+		},
+		{name: "negative max eviction loop interval", modify: func(c *Config) { c.MaxEvictionLoopInterval = -time.Minute }, wantErr: true},
+		{name: "rolling restart threshold above 1", modify: func(c *Config) { c.RollingRestartThreshold = 1.5 }, wantErr: true},
+		{name: "unknown expiry action", modify: func(c *Config) { c.ExpiryAction = "drain" }, wantErr: true},
+		{name: "webhook expiry action without URL", modify: func(c *Config) { c.ExpiryAction = ExpiryActionWebhook }, wantErr: true},
+		{name: "zone limit percentage", modify: func(c *Config) { c.MaxParkedNodesPerZone = "10%" }},
+		{name: "invalid zone limit", modify: func(c *Config) { c.MaxParkedNodesPerZone = "ten" }, wantErr: true},
+		{name: "same parked and unparked values", modify: func(c *Config) { c.UpgradeStatusUnparkedValue = "parked" }, wantErr: true},
+		{name: "invalid pod label selector", modify: func(c *Config) { c.ParkedPodLabelSelector = "team in (" }, wantErr: true},
+		{name: "eviction safety check", modify: func(c *Config) { c.EvictionSafetyCheck = true; c.EnableParkedPodLabels = true }},
+		{name: "eviction safety check without pod labels", modify: func(c *Config) { c.EvictionSafetyCheck = true }, wantErr: true},
+		{name: "duplicate cluster names", modify: func(c *Config) { c.Clusters = []ClusterConfig{{Name: "a"}, {Name: "a"}} }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validConfig()
+			tt.modify(&c)
+			err := c.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMaxParkedNodesInZone(t *testing.T) {
+	tests := []struct {
+		name     string
+		limit    string
+		zoneSize int
+		want     int
+		wantErr  bool
+	}{
+		{name: "absolute number", limit: "3", zoneSize: 10, want: 3},
+		{name: "absolute number above the zone size", limit: "30", zoneSize: 10, want: 30},
+		{name: "exact percentage", limit: "10%", zoneSize: 50, want: 5},
+		{name: "percentage rounded up", limit: "10%", zoneSize: 15, want: 2},
+		{name: "small zone still parks one node", limit: "10%", zoneSize: 3, want: 1},
+		{name: "single node zone", limit: "1%", zoneSize: 1, want: 1},
+		{name: "empty zone", limit: "10%", zoneSize: 0, want: 0},
+		{name: "whole zone", limit: "100%", zoneSize: 7, want: 7},
+		{name: "zero percent", limit: "0%", zoneSize: 7, want: 0},
+		{name: "negative number", limit: "-1", zoneSize: 10, wantErr: true},
+		{name: "not a number", limit: "ten", zoneSize: 10, wantErr: true},
+		{name: "malformed percentage", limit: "10.5%", zoneSize: 10, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Config{MaxParkedNodesPerZone: tt.limit}
+			got, err := c.MaxParkedNodesInZone(tt.zoneSize)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MaxParkedNodesInZone(%d) with %q error = %v, wantErr %v", tt.zoneSize, tt.limit, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("MaxParkedNodesInZone(%d) with %q = %d, want %d", tt.zoneSize, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package config
+
+import (
+	"reflect"
+	"time"
+)
+
+// FieldChange is a configuration field whose value changed during a reload
+type FieldChange struct {
+	Field string
+	Old   interface{}
+	New   interface{}
+}
+
+// Diff returns the fields whose value differs between two configurations, in the order they are declared
+func Diff(old, new Config) []FieldChange {
+	var changes []FieldChange
+
+	oldValue, newValue := reflect.ValueOf(old), reflect.ValueOf(new)
+	for i := 0; i < oldValue.NumField(); i++ {
+		o, n := oldValue.Field(i).Interface(), newValue.Field(i).Interface()
+		if reflect.DeepEqual(o, n) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: oldValue.Type().Field(i).Name, Old: o, New: n})
+	}
+
+	return changes
+}
+
+// KeepTTLReductions returns a copy of the configuration where the parked node TTLs lower than in previous, either
+// ParkedNodeTTL or the TTL of any parking reason, are replaced with the previous value. The second value reports whether
+// any TTL was kept
+func (c Config) KeepTTLReductions(previous Config) (Config, bool) {
+	kept := false
+
+	overrides := make(map[string]time.Duration, len(c.TTLOverridesByReason))
+	for reason, ttl := range c.TTLOverridesByReason {
+		overrides[reason] = ttl
+	}
+	// the TTL of the reasons without override follows ParkedNodeTTL, which has to be checked first
+	reasons := make([]string, 0, len(overrides)+len(previous.TTLOverridesByReason))
+	for reason := range overrides {
+		reasons = append(reasons, reason)
+	}
+	for reason := range previous.TTLOverridesByReason {
+		if _, found := overrides[reason]; !found {
+			reasons = append(reasons, reason)
+		}
+	}
+
+	result := c
+	if c.ParkedNodeTTL < previous.ParkedNodeTTL {
+		result.ParkedNodeTTL = previous.ParkedNodeTTL
+		kept = true
+	}
+	for _, reason := range reasons {
+		if c.ParkedNodeTTLFor(reason) < previous.ParkedNodeTTLFor(reason) {
+			overrides[reason] = previous.ParkedNodeTTLFor(reason)
+			kept = true
+		}
+	}
+	if kept {
+		result.TTLOverridesByReason = overrides
+	}
+
+	return result, kept
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	base := Config{
+		EvictionLoopInterval: time.Minute,
+		ParkedNodeTTL:        time.Hour,
+		TTLOverridesByReason: map[string]time.Duration{"cli": time.Hour},
+		MaxParkedNodes:       5,
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   []FieldChange
+	}{
+		{name: "no change", modify: func(c *Config) {}},
+		{
+			name:   "single field",
+			modify: func(c *Config) { c.MaxParkedNodes = 10 },
+			want:   []FieldChange{{Field: "MaxParkedNodes", Old: 5, New: 10}},
+		},
+		{
+			name:   "fields in declaration order",
+			modify: func(c *Config) { c.MaxParkedNodes = 10; c.EvictionLoopInterval = 2 * time.Minute },
+			want: []FieldChange{
+				{Field: "EvictionLoopInterval", Old: time.Minute, New: 2 * time.Minute},
+				{Field: "MaxParkedNodes", Old: 5, New: 10},
+			},
+		},
+		{
+			name:   "map compared by content",
+			modify: func(c *Config) { c.TTLOverridesByReason = map[string]time.Duration{"cli": time.Hour} },
+		},
+		{
+			name:   "map entry changed",
+			modify: func(c *Config) { c.TTLOverridesByReason = map[string]time.Duration{"cli": 2 * time.Hour} },
+			want: []FieldChange{{
+				Field: "TTLOverridesByReason",
+				Old:   map[string]time.Duration{"cli": time.Hour},
+				New:   map[string]time.Duration{"cli": 2 * time.Hour},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := base
+			tt.modify(&changed)
+			got := Diff(base, changed)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeepTTLReductions(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous Config
+		current  Config
+		want     Config
+		wantKept bool
+	}{
+		{
+			name:     "unchanged",
+			previous: Config{ParkedNodeTTL: time.Hour},
+			current:  Config{ParkedNodeTTL: time.Hour},
+			want:     Config{ParkedNodeTTL: time.Hour},
+		},
+		{
+			name:     "increase applied",
+			previous: Config{ParkedNodeTTL: time.Hour},
+			current:  Config{ParkedNodeTTL: 2 * time.Hour},
+			want:     Config{ParkedNodeTTL: 2 * time.Hour},
+		},
+		{
+			name:     "reduction kept",
+			previous: Config{ParkedNodeTTL: 2 * time.Hour},
+			current:  Config{ParkedNodeTTL: time.Hour},
+			want:     Config{ParkedNodeTTL: 2 * time.Hour, TTLOverridesByReason: map[string]time.Duration{}},
+			wantKept: true,
+		},
+		{
+			name:     "override reduction kept",
+			previous: Config{ParkedNodeTTL: time.Hour, TTLOverridesByReason: map[string]time.Duration{"cli": 3 * time.Hour}},
+			current:  Config{ParkedNodeTTL: time.Hour, TTLOverridesByReason: map[string]time.Duration{"cli": 2 * time.Hour}},
+			want:     Config{ParkedNodeTTL: time.Hour, TTLOverridesByReason: map[string]time.Duration{"cli": 3 * time.Hour}},
+			wantKept: true,
+		},
+		{
+			name:     "removed override falling back to a lower TTL kept",
+			previous: Config{ParkedNodeTTL: time.Hour, TTLOverridesByReason: map[string]time.Duration{"cli": 3 * time.Hour}},
+			current:  Config{ParkedNodeTTL: time.Hour},
+			want:     Config{ParkedNodeTTL: time.Hour, TTLOverridesByReason: map[string]time.Duration{"cli": 3 * time.Hour}},
+			wantKept: true,
+		},
+		{
+			name:     "removed override falling back to a higher TTL applied",
+			previous: Config{ParkedNodeTTL: time.Hour, TTLOverridesByReason: map[string]time.Duration{"cli": 30 * time.Minute}},
+			current:  Config{ParkedNodeTTL: time.Hour},
+			want:     Config{ParkedNodeTTL: time.Hour},
+		},
+		{
+			name:     "new lower override kept",
+			previous: Config{ParkedNodeTTL: time.Hour},
+			current:  Config{ParkedNodeTTL: time.Hour, TTLOverridesByReason: map[string]time.Duration{"cli": 10 * time.Minute}},
+			want:     Config{ParkedNodeTTL: time.Hour, TTLOverridesByReason: map[string]time.Duration{"cli": time.Hour}},
+			wantKept: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, kept := tt.current.KeepTTLReductions(tt.previous)
+			if kept != tt.wantKept {
+				t.Errorf("KeepTTLReductions() kept = %v, want %v", kept, tt.wantKept)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("KeepTTLReductions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
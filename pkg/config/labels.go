@@ -0,0 +1,196 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package config
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/exp/slices"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// NodeLabelMatcher matches the labels of a node against one of the NodeLabelsToDetect entries
+type NodeLabelMatcher interface {
+	Matches(labels.Set) bool
+	String() string
+}
+
+// selectorMatcher matches the nodes selected by a label selector
+type selectorMatcher struct {
+	labels.Selector
+}
+
+func (m selectorMatcher) Matches(nodeLabels labels.Set) bool {
+	return m.Selector.Matches(nodeLabels)
+}
+
+// allMatcher matches the nodes matched by all of the comma separated terms of an entry
+type allMatcher struct {
+	matchers []NodeLabelMatcher
+	spec     string
+}
+
+func (m allMatcher) Matches(nodeLabels labels.Set) bool {
+	for _, matcher := range m.matchers {
+		if !matcher.Matches(nodeLabels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m allMatcher) String() string {
+	return m.spec
+}
+
+// labelValueRegexMatcher matches the nodes having a label whose whole value matches a regular expression, from the
+// `key~=regex` form
+type labelValueRegexMatcher struct {
+	key   string
+	value *regexp.Regexp
+	spec  string
+}
+
+func (m labelValueRegexMatcher) Matches(nodeLabels labels.Set) bool {
+	return nodeLabels.Has(m.key) && m.value.MatchString(nodeLabels.Get(m.key))
+}
+
+func (m labelValueRegexMatcher) String() string {
+	return m.spec
+}
+
+// labelKeyPrefixMatcher matches the nodes having a label whose key starts with a prefix, from the `prefix*` form
+type labelKeyPrefixMatcher struct {
+	prefix string
+}
+
+func (m labelKeyPrefixMatcher) Matches(nodeLabels labels.Set) bool {
+	for key := range nodeLabels {
+		if strings.HasPrefix(key, m.prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m labelKeyPrefixMatcher) String() string {
+	return m.prefix + "*"
+}
+
+// ParseNodeLabelMatcher parses a NodeLabelsToDetect entry: comma separated terms which must all match, each of them
+// being either a label selector requirement, a `key~=regex` label value regular expression or a `prefix*` label key prefix
+func ParseNodeLabelMatcher(value string) (NodeLabelMatcher, error) {
+	terms := splitNodeLabelTerms(value)
+	if len(terms) == 1 || !slices.ContainsFunc(terms, isExtendedNodeLabelTerm) {
+		return parseNodeLabelTerm(value)
+	}
+
+	matcher := allMatcher{spec: value}
+	var requirements []string
+	for _, term := range terms {
+		if strings.TrimSpace(term) == "" {
+			return nil, errors.New("empty term")
+		}
+		// plain requirements are parsed together, as a single label selector
+		if !isExtendedNodeLabelTerm(term) {
+			requirements = append(requirements, term)
+			continue
+		}
+		termMatcher, err := parseNodeLabelTerm(term)
+		if err != nil {
+			return nil, err
+		}
+		matcher.matchers = append(matcher.matchers, termMatcher)
+	}
+	if len(requirements) > 0 {
+		selectorMatcher, err := parseNodeLabelTerm(strings.Join(requirements, ","))
+		if err != nil {
+			return nil, err
+		}
+		matcher.matchers = append(matcher.matchers, selectorMatcher)
+	}
+	return matcher, nil
+}
+
+// isExtendedNodeLabelTerm tells the `key~=regex` and `prefix*` terms apart from the label selector requirements
+func isExtendedNodeLabelTerm(term string) bool {
+	return strings.Contains(term, "~=") || strings.HasSuffix(strings.TrimSpace(term), "*")
+}
+
+// splitNodeLabelTerms splits a NodeLabelsToDetect entry on the commas separating its terms, leaving the ones of set-based
+// requirements like `key in (a,b)` and of regular expressions like `key~=v[0-9]{1,3}` alone
+func splitNodeLabelTerms(value string) []string {
+	var terms []string
+	depth, start := 0, 0
+	for i, r := range value {
+		switch r {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, value[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(terms, value[start:])
+}
+
+// parseNodeLabelTerm parses a single term of a NodeLabelsToDetect entry, plain label selectors being parsed as a whole
+func parseNodeLabelTerm(value string) (NodeLabelMatcher, error) {
+	if key, expression, found := strings.Cut(value, "~="); found {
+		key = strings.TrimSpace(key)
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return nil, errors.Errorf("invalid label key %q: %s", key, strings.Join(errs, "; "))
+		}
+		// RE2 expressions run in linear time, whatever the label values
+		re, err := regexp.Compile("^(?:" + strings.TrimSpace(expression) + ")$")
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid label value regular expression")
+		}
+		return labelValueRegexMatcher{key: key, value: re, spec: value}, nil
+	}
+
+	if prefix, found := strings.CutSuffix(strings.TrimSpace(value), "*"); found {
+		if prefix == "" || strings.ContainsAny(prefix, " =!,()*") {
+			return nil, errors.Errorf("invalid label key prefix %q", prefix)
+		}
+		return labelKeyPrefixMatcher{prefix: prefix}, nil
+	}
+
+	selector, err := labels.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	if selector.Empty() {
+		return nil, errors.New("matches every node")
+	}
+	return selectorMatcher{selector}, nil
+}
+
+// NodeLabelSelectors parses the NodeLabelsToDetect entries
+func (c Config) NodeLabelSelectors() ([]NodeLabelMatcher, error) {
+	matchers := make([]NodeLabelMatcher, 0, len(c.NodeLabelsToDetect))
+	for _, value := range c.NodeLabelsToDetect {
+		matcher, err := ParseNodeLabelMatcher(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid NodeLabelsToDetect selector %q", value)
+		}
+		matchers = append(matchers, matcher)
+	}
+	return matchers, nil
+}
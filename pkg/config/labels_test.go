@@ -0,0 +1,77 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestParseNodeLabelMatcher(t *testing.T) {
+	nodeLabels := labels.Set{
+		"pool":                          "blue",
+		"topology.kubernetes.io/zone":   "us-east-1a",
+		"example.com/ami":               "ami-0123",
+		"upgrade.example.com/scheduled": "true",
+	}
+
+	tests := []struct {
+		name      string
+		value     string
+		wantMatch bool
+		wantErr   bool
+	}{
+		{name: "existing key", value: "pool", wantMatch: true},
+		{name: "missing key", value: "team"},
+		{name: "equality", value: "pool=blue", wantMatch: true},
+		{name: "inequality", value: "pool!=blue"},
+		{name: "set based", value: "pool in (green,blue)", wantMatch: true},
+		{name: "set based not matching", value: "pool notin (green,blue)"},
+		{name: "missing key negation", value: "!team", wantMatch: true},
+		{name: "all requirements matching", value: "pool=blue,topology.kubernetes.io/zone in (us-east-1a,us-east-1b)", wantMatch: true},
+		{name: "one requirement not matching", value: "pool=blue,topology.kubernetes.io/zone notin (us-east-1a)"},
+		{name: "regex", value: "example.com/ami~=ami-0(12|34).*", wantMatch: true},
+		{name: "regex matching the whole value only", value: "example.com/ami~=ami-0"},
+		{name: "regex with a quantifier", value: "example.com/ami~=ami-[0-9]{1,4}", wantMatch: true},
+		{name: "regex on a missing key", value: "team~=.*"},
+		{name: "prefix", value: "upgrade.example.com/*", wantMatch: true},
+		{name: "prefix not matching", value: "drain.example.com/*"},
+		{name: "regex combined with a requirement", value: "pool=blue,example.com/ami~=ami-0.*", wantMatch: true},
+		{name: "regex combined with a requirement not matching", value: "pool=green,example.com/ami~=ami-0.*"},
+		{name: "prefix combined with a set based requirement", value: "upgrade.example.com/*, pool in (green,blue)", wantMatch: true},
+		{name: "prefix combined with a regex not matching", value: "upgrade.example.com/*,example.com/ami~=ami-1.*"},
+		{name: "empty", value: "", wantErr: true},
+		{name: "invalid selector", value: "pool in (blue", wantErr: true},
+		{name: "invalid regex", value: "example.com/ami~=ami-(", wantErr: true},
+		{name: "invalid regex key", value: "bad key~=.*", wantErr: true},
+		{name: "empty prefix", value: "*", wantErr: true},
+		{name: "invalid prefix", value: "pool=*", wantErr: true},
+		{name: "empty combined term", value: "pool=blue,,example.com/ami~=.*", wantErr: true},
+		{name: "invalid combined regex", value: "pool=blue,example.com/ami~=(", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matcher, err := ParseNodeLabelMatcher(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseNodeLabelMatcher(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := matcher.Matches(nodeLabels); got != tt.wantMatch {
+				t.Errorf("ParseNodeLabelMatcher(%q).Matches() = %v, want %v", tt.value, got, tt.wantMatch)
+			}
+		})
+	}
+}
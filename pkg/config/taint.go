@@ -0,0 +1,44 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package config
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ParseTaint parses a taint in the `key=value:Effect` or `key:Effect` format
+func ParseTaint(taint string) (v1.Taint, error) {
+	keyValue, effect, found := strings.Cut(taint, ":")
+	if !found {
+		return v1.Taint{}, errors.Errorf("Invalid taint %q, expected format key=value:Effect", taint)
+	}
+
+	key, value, _ := strings.Cut(keyValue, "=")
+	if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+		return v1.Taint{}, errors.Errorf("Invalid taint %q, invalid key: %s", taint, strings.Join(errs, "; "))
+	}
+	if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+		return v1.Taint{}, errors.Errorf("Invalid taint %q, invalid value: %s", taint, strings.Join(errs, "; "))
+	}
+
+	switch v1.TaintEffect(effect) {
+	case v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoExecute:
+	default:
+		return v1.Taint{}, errors.Errorf("Invalid taint %q, unsupported effect %s", taint, effect)
+	}
+
+	return v1.Taint{Key: key, Value: value, Effect: v1.TaintEffect(effect)}, nil
+}
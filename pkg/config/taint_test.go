@@ -0,0 +1,63 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestParseTaint(t *testing.T) {
+	tests := []struct {
+		name    string
+		taint   string
+		want    v1.Taint
+		wantErr bool
+	}{
+		{
+			name:  "key, value and effect",
+			taint: "shredder.ethos.adobe.net/upgrade-status=parked:NoSchedule",
+			want:  v1.Taint{Key: "shredder.ethos.adobe.net/upgrade-status", Value: "parked", Effect: v1.TaintEffectNoSchedule},
+		},
+		{
+			name:  "key and effect",
+			taint: "parked:PreferNoSchedule",
+			want:  v1.Taint{Key: "parked", Effect: v1.TaintEffectPreferNoSchedule},
+		},
+		{
+			name:  "empty value",
+			taint: "parked=:NoExecute",
+			want:  v1.Taint{Key: "parked", Effect: v1.TaintEffectNoExecute},
+		},
+		{name: "missing effect", taint: "parked=true", wantErr: true},
+		{name: "empty effect", taint: "parked=true:", wantErr: true},
+		{name: "unsupported effect", taint: "parked=true:NoEvict", wantErr: true},
+		{name: "lowercase effect", taint: "parked=true:noschedule", wantErr: true},
+		{name: "empty key", taint: "=true:NoSchedule", wantErr: true},
+		{name: "invalid key", taint: "parked status=true:NoSchedule", wantErr: true},
+		{name: "invalid value", taint: "parked=not valid:NoSchedule", wantErr: true},
+		{name: "empty", taint: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTaint(tt.taint)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTaint(%q) error = %v, wantErr %v", tt.taint, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseTaint(%q) = %+v, want %+v", tt.taint, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package dashboards
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/slices"
+	"sigs.k8s.io/yaml"
+)
+
+// highCardinalityLabels are never aggregated by, as they hold node or pod names
+var highCardinalityLabels = []string{"node", "node_name", "pod_name"}
+
+// alert is an alerting rule, only generated when the metrics it is based on are exposed
+type alert struct {
+	name        string
+	metrics     []string
+	expr        string
+	duration    string
+	severity    string
+	summary     string
+	description string
+}
+
+// alerts are the alerting rules shipped along with the dashboard
+var alerts = []alert{
+	{
+		name:        "K8sShredderEvictionLoopsStalled",
+		metrics:     []string{"shredder_loops_total"},
+		expr:        "sum by (cluster) (increase(shredder_loops_total[30m])) == 0",
+		duration:    "15m",
+		severity:    "critical",
+		summary:     "k8s-shredder eviction loops stopped completing",
+		description: "No eviction loop completed for cluster {{ $labels.cluster }} during the last 30 minutes.",
+	},
+	{
+		name:        "K8sShredderErrors",
+		metrics:     []string{"shredder_errors_total"},
+		expr:        "sum by (cluster) (rate(shredder_errors_total[10m])) > 0",
+		duration:    "30m",
+		severity:    "warning",
+		summary:     "k8s-shredder keeps failing",
+		description: "k8s-shredder reported errors for cluster {{ $labels.cluster }} during the last 30 minutes.",
+	},
+	{
+		name:        "K8sShredderDrainStuck",
+		metrics:     []string{"shredder_node_drain_progress", "shredder_node_force_to_evict_time"},
+		expr:        "shredder_node_drain_progress < 0.5 and on (cluster, node_name) (shredder_node_force_to_evict_time - time()) < 900",
+		duration:    "5m",
+		severity:    "warning",
+		summary:     "Parked node drain is stuck",
+		description: "Less than half of the pods of parked node {{ $labels.node_name }} are gone and it gets force evicted within 15 minutes.",
+	},
+	{
+		name:        "K8sShredderAPIServerThrottled",
+		metrics:     []string{"shredder_apiserver_requests_total"},
+		expr:        "sum by (cluster) (rate(shredder_apiserver_requests_total{status=\"429\"}[5m])) > 0",
+		duration:    "15m",
+		severity:    "warning",
+		summary:     "k8s-shredder is throttled by the APIServer",
+		description: "The APIServer of cluster {{ $labels.cluster }} keeps rejecting k8s-shredder requests with 429.",
+	},
+	{
+		name:        "K8sShredderPaused",
+		metrics:     []string{"shredder_paused"},
+		expr:        "shredder_paused == 1",
+		duration:    "2h",
+		severity:    "warning",
+		summary:     "k8s-shredder is paused",
+		description: "k8s-shredder has been paused for more than 2 hours, parked nodes are not drained.",
+	},
+	{
+		name:        "K8sShredderConfigLoadFailed",
+		metrics:     []string{"shredder_config_load_error"},
+		expr:        "shredder_config_load_error == 1",
+		duration:    "10m",
+		severity:    "warning",
+		summary:     "k8s-shredder failed to reload its configuration",
+		description: "The last configuration reload of k8s-shredder failed, it keeps running with the previous configuration.",
+	},
+}
+
+// aggregationLabels returns the labels of a metric worth breaking it down by
+func aggregationLabels(info metrics.MetricInfo) []string {
+	var labels []string
+	for _, label := range info.Labels {
+		if !slices.Contains(highCardinalityLabels, label) {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// query returns the PromQL expression charting a metric, along with its legend
+func query(info metrics.MetricInfo) (string, string) {
+	labels := aggregationLabels(info)
+	legend := ""
+	if len(labels) > 0 {
+		parts := make([]string, 0, len(labels))
+		for _, label := range labels {
+			parts = append(parts, fmt.Sprintf("{{%s}}", label))
+		}
+		legend = strings.Join(parts, " ")
+	}
+	by := fmt.Sprintf("sum by (%s) ", strings.Join(labels, ", "))
+
+	switch info.Type {
+	case "counter":
+		return fmt.Sprintf("%s(rate(%s[5m]))", by, info.Name), legend
+	case "histogram":
+		return fmt.Sprintf("histogram_quantile(0.99, sum by (%s) (rate(%s_bucket[5m])))", strings.Join(append([]string{"le"}, labels...), ", "), info.Name), legend
+	case "summary":
+		return fmt.Sprintf("%s(rate(%s_sum[5m])) / %s(rate(%s_count[5m]))", by, info.Name, by, info.Name), legend
+	default:
+		if len(labels) < len(info.Labels) {
+			// series keyed by node or pod are too many to chart all of them
+			return fmt.Sprintf("topk(10, %s)", info.Name), "{{" + strings.Join(info.Labels, "}} {{") + "}}"
+		}
+		return fmt.Sprintf("%s(%s)", by, info.Name), legend
+	}
+}
+
+// Dashboard returns a Grafana dashboard charting each of the given metrics
+func Dashboard(infos []metrics.MetricInfo) ([]byte, error) {
+	panels := make([]map[string]interface{}, 0, len(infos))
+	for i, info := range infos {
+		expr, legend := query(info)
+		panels = append(panels, map[string]interface{}{
+			"id":          i + 1,
+			"type":        "timeseries",
+			"title":       info.Name,
+			"description": info.Help,
+			"datasource":  map[string]string{"type": "prometheus", "uid": "${datasource}"},
+			"gridPos":     map[string]int{"h": 8, "w": 12, "x": 12 * (i % 2), "y": 8 * (i / 2)},
+			"targets": []map[string]string{
+				{"refId": "A", "expr": expr, "legendFormat": legend},
+			},
+		})
+	}
+
+	dashboard := map[string]interface{}{
+		"uid":           "k8s-shredder",
+		"title":         "k8s-shredder",
+		"tags":          []string{"k8s-shredder"},
+		"schemaVersion": 39,
+		"refresh":       "1m",
+		"time":          map[string]string{"from": "now-6h", "to": "now"},
+		"templating": map[string]interface{}{
+			"list": []map[string]interface{}{
+				{"name": "datasource", "type": "datasource", "query": "prometheus", "label": "Data source"},
+			},
+		},
+		"panels": panels,
+	}
+	return json.MarshalIndent(dashboard, "", "  ")
+}
+
+// Rules returns Prometheus recording rules for the rate of the given counters, along with the alerting rules whose
+// metrics are all exposed
+func Rules(infos []metrics.MetricInfo) ([]byte, error) {
+	exposed := map[string]bool{}
+	var recording []map[string]string
+	for _, info := range infos {
+		exposed[info.Name] = true
+		if info.Type != "counter" {
+			continue
+		}
+		// rule names follow the level:metric:operations convention, the level being the aggregation labels
+		level := strings.Join(aggregationLabels(info), "_")
+		if level == "" {
+			level = "k8s_shredder"
+		}
+		expr, _ := query(info)
+		recording = append(recording, map[string]string{
+			"record": fmt.Sprintf("%s:%s:rate5m", level, info.Name),
+			"expr":   expr,
+		})
+	}
+
+	var alerting []map[string]interface{}
+	for _, a := range alerts {
+		for _, metric := range a.metrics {
+			if !exposed[metric] {
+				return nil, errors.Errorf("alert %s uses metric %s, which is not exposed anymore", a.name, metric)
+			}
+		}
+		alerting = append(alerting, map[string]interface{}{
+			"alert":  a.name,
+			"expr":   a.expr,
+			"for":    a.duration,
+			"labels": map[string]string{"severity": a.severity},
+			"annotations": map[string]string{
+				"summary":     a.summary,
+				"description": a.description,
+			},
+		})
+	}
+
+	return yaml.Marshal(map[string]interface{}{
+		"groups": []map[string]interface{}{
+			{"name": "k8s-shredder.recording", "rules": recording},
+			{"name": "k8s-shredder.alerting", "rules": alerting},
+		},
+	})
+}
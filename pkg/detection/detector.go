@@ -0,0 +1,88 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package detection
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/config"
+	"github.com/adobe/k8s-shredder/pkg/utils"
+)
+
+// Detector finds nodes that should be parked
+type Detector interface {
+	// Name identifies the detector in logs, metrics and the parking reason label, so it must be a valid label value
+	Name() string
+	// Enabled reports whether the detector is turned on in the given configuration
+	Enabled(cfg config.Config) bool
+	// Interval returns how often the detector runs on its own, 0 meaning at the beginning of every eviction loop
+	Interval(cfg config.Config) time.Duration
+	// Detect returns the nodes that should be parked
+	Detect(ctx context.Context) ([]utils.NodeInfo, error)
+}
+
+// Recoverer is implemented by detectors able to tell when the nodes they parked recovered
+type Recoverer interface {
+	// Recovered returns the nodes parked by the detector which no longer need to be
+	Recovered(ctx context.Context) ([]utils.NodeInfo, error)
+}
+
+// Urgent is implemented by detectors whose nodes are about to go away, which must be drained by an eviction loop as
+// soon as they are parked
+type Urgent interface {
+	// Urgent reports whether the nodes parked by the detector must be drained right away
+	Urgent() bool
+}
+
+// Factory creates a Detector for the given application context
+type Factory func(appContext *utils.AppContext) Detector
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes a detector available under the given name. It is meant to be called from the init function of the
+// file implementing the detector
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic("detector already registered: " + name)
+	}
+	registry[name] = factory
+}
+
+// EnabledDetectors returns the registered detectors that are enabled in the application configuration, sorted by name
+func EnabledDetectors(appContext *utils.AppContext) []Detector {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	detectors := make([]Detector, 0, len(names))
+	for _, name := range names {
+		detector := registry[name](appContext)
+		if detector.Enabled(*appContext.Config()) {
+			detectors = append(detectors, detector)
+		}
+	}
+	return detectors
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package detection
+
+import (
+	"context"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/config"
+	"github.com/adobe/k8s-shredder/pkg/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// EKSNodegroupUpgradeDetectorName is the name of the detector parking the nodes of EKS managed node groups being upgraded
+	EKSNodegroupUpgradeDetectorName = "eks-nodegroup-upgrade"
+	// GKENodeUpgradeDetectorName is the name of the detector parking the GKE nodes being upgraded or about to be terminated
+	GKENodeUpgradeDetectorName = "gke-node-upgrade"
+)
+
+func init() {
+	Register(EKSNodegroupUpgradeDetectorName, func(appContext *utils.AppContext) Detector {
+		return newManagedUpgradeDetector(appContext, EKSNodegroupUpgradeDetectorName, func(cfg config.Config) managedUpgradeSignals {
+			return managedUpgradeSignals{
+				enabled: cfg.EnableEKSNodegroupUpgradeDetection,
+				taints:  cfg.EKSNodegroupUpgradeTaints,
+				labels:  cfg.EKSNodegroupUpgradeLabels,
+			}
+		})
+	})
+	Register(GKENodeUpgradeDetectorName, func(appContext *utils.AppContext) Detector {
+		return newManagedUpgradeDetector(appContext, GKENodeUpgradeDetectorName, func(cfg config.Config) managedUpgradeSignals {
+			return managedUpgradeSignals{
+				enabled: cfg.EnableGKENodeUpgradeDetection,
+				taints:  cfg.GKENodeUpgradeTaints,
+				labels:  cfg.GKENodeUpgradeLabels,
+			}
+		})
+	})
+}
+
+// managedUpgradeSignals are the taints and labels a cloud provider sets on the managed nodes it is about to replace
+type managedUpgradeSignals struct {
+	enabled bool
+	taints  []string
+	labels  []string
+}
+
+// managedUpgradeDetector finds the nodes a managed node pool upgrade is about to replace, so that they get drained by
+// k8s-shredder ahead of the provider
+type managedUpgradeDetector struct {
+	appContext *utils.AppContext
+	logger     *log.Entry
+	name       string
+	signals    func(cfg config.Config) managedUpgradeSignals
+}
+
+func newManagedUpgradeDetector(appContext *utils.AppContext, name string, signals func(cfg config.Config) managedUpgradeSignals) Detector {
+	return &managedUpgradeDetector{
+		appContext: appContext,
+		logger:     log.WithField("detector", name),
+		name:       name,
+		signals:    signals,
+	}
+}
+
+// Name returns the name of the detector
+func (d *managedUpgradeDetector) Name() string {
+	return d.name
+}
+
+// Enabled reports whether the detection of the provider is turned on, with at least one signal to look for
+func (d *managedUpgradeDetector) Enabled(cfg config.Config) bool {
+	signals := d.signals(cfg)
+	return signals.enabled && len(signals.taints)+len(signals.labels) > 0
+}
+
+// Interval returns how often the detector runs on its own
+func (d *managedUpgradeDetector) Interval(cfg config.Config) time.Duration {
+	return 0
+}
+
+// Detect returns the nodes which are not parked yet and carry one of the upgrade taints or labels of the provider
+func (d *managedUpgradeDetector) Detect(ctx context.Context) ([]utils.NodeInfo, error) {
+	allNodes, err := d.appContext.ListNodes(ctx, d.appContext.BackgroundK8sClient, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list nodes")
+	}
+
+	signals := d.signals(*d.appContext.Config())
+	var nodes []utils.NodeInfo
+
+	for _, node := range allNodes {
+		if node.Labels[d.appContext.Config().UpgradeStatusLabel] == d.appContext.Config().UpgradeStatusParkedValue {
+			continue
+		}
+
+		if signal := managedUpgradeSignal(node, signals); signal != "" {
+			d.logger.Debugf("Node %s is being upgraded, found %s", node.Name, signal)
+			nodeInfo := utils.NewNodeInfo(node)
+			nodeInfo.ReasonMessage = "managed upgrade signaled by " + signal
+			nodes = append(nodes, nodeInfo)
+		}
+	}
+
+	return nodes, nil
+}
+
+// managedUpgradeSignal returns the first upgrade taint or label found on the node, or an empty string
+func managedUpgradeSignal(node v1.Node, signals managedUpgradeSignals) string {
+	for _, taint := range signals.taints {
+		if utils.NodeHasTaint(node, taint) {
+			return "taint " + taint
+		}
+	}
+	for _, label := range signals.labels {
+		if utils.NodeMatchesLabel(node, label) {
+			return "label " + label
+		}
+	}
+	return ""
+}
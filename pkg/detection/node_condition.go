@@ -0,0 +1,174 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package detection
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/config"
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+	"github.com/adobe/k8s-shredder/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// NodeConditionDetectorName is the name of the detector parking nodes stuck in a bad condition
+const NodeConditionDetectorName = "node-condition"
+
+func init() {
+	Register(NodeConditionDetectorName, newNodeConditionDetector)
+}
+
+// nodeConditionDetector finds nodes that had one of the NodeConditionsToDetect for at least its minimum duration
+type nodeConditionDetector struct {
+	appContext *utils.AppContext
+	logger     *log.Entry
+}
+
+func newNodeConditionDetector(appContext *utils.AppContext) Detector {
+	return &nodeConditionDetector{
+		appContext: appContext,
+		logger:     log.WithField("detector", NodeConditionDetectorName),
+	}
+}
+
+// Name returns the name of the detector
+func (d *nodeConditionDetector) Name() string {
+	return NodeConditionDetectorName
+}
+
+// Enabled reports whether any node condition is configured for detection
+func (d *nodeConditionDetector) Enabled(cfg config.Config) bool {
+	return len(cfg.NodeConditionsToDetect) > 0
+}
+
+// Interval returns how often the detector runs on its own
+func (d *nodeConditionDetector) Interval(cfg config.Config) time.Duration {
+	return cfg.NodeConditionDetectionInterval
+}
+
+// Detect returns the nodes which are not parked yet and had a configured bad condition for long enough
+func (d *nodeConditionDetector) Detect(ctx context.Context) ([]utils.NodeInfo, error) {
+	allNodes, err := d.appContext.ListNodes(ctx, d.appContext.BackgroundK8sClient, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list nodes")
+	}
+
+	now := time.Now()
+	detected := make(map[string]int, len(d.appContext.Config().NodeConditionsToDetect))
+	var nodes []utils.NodeInfo
+
+	for _, node := range allNodes {
+		if node.Labels[d.appContext.Config().UpgradeStatusLabel] == d.appContext.Config().UpgradeStatusParkedValue {
+			continue
+		}
+
+		var matched []string
+		for _, condition := range d.appContext.Config().NodeConditionsToDetect {
+			if !nodeHasCondition(node, condition, now) {
+				continue
+			}
+			d.logger.Debugf("Node %s had condition %s for at least %s", node.Name, condition.String(), condition.MinDuration.String())
+			detected[condition.String()]++
+			matched = append(matched, nodeConditionMessage(node, condition))
+		}
+
+		if len(matched) > 0 {
+			nodeInfo := utils.NewNodeInfo(node)
+			nodeInfo.ReasonMessage = strings.Join(matched, "; ")
+			nodes = append(nodes, nodeInfo)
+		}
+	}
+
+	metrics.ShredderNodeConditionDetectedNodes.DeletePartialMatch(prometheus.Labels{"cluster": d.appContext.Cluster})
+	for _, condition := range d.appContext.Config().NodeConditionsToDetect {
+		metrics.ShredderNodeConditionDetectedNodes.WithLabelValues(d.appContext.Cluster, condition.String()).Set(float64(detected[condition.String()]))
+	}
+
+	return nodes, nil
+}
+
+// Recovered returns the nodes parked by the detector on which none of the configured conditions was seen for at least
+// UnparkStabilizationPeriod
+func (d *nodeConditionDetector) Recovered(ctx context.Context) ([]utils.NodeInfo, error) {
+	cfg := *d.appContext.Config()
+
+	parkedNodes, err := d.appContext.ListNodes(ctx, d.appContext.BackgroundK8sClient, metav1.ListOptions{
+		LabelSelector: labels.Set{
+			cfg.UpgradeStatusLabel: cfg.UpgradeStatusParkedValue,
+			cfg.ParkingReasonLabel: NodeConditionDetectorName,
+		}.String(),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list parked nodes")
+	}
+
+	now := time.Now()
+	var nodes []utils.NodeInfo
+
+	for _, node := range parkedNodes {
+		recovered := true
+		for _, condition := range cfg.NodeConditionsToDetect {
+			if !nodeRecoveredFromCondition(node, condition, cfg.UnparkStabilizationPeriod, now) {
+				recovered = false
+				break
+			}
+		}
+
+		if recovered {
+			d.logger.Debugf("Node %s recovered from all the detected conditions", node.Name)
+			nodes = append(nodes, utils.NewNodeInfo(node))
+		}
+	}
+
+	return nodes, nil
+}
+
+// nodeConditionMessage describes a detected condition of the node, including the message reported along with it
+func nodeConditionMessage(node v1.Node, condition config.NodeConditionDetection) string {
+	for _, c := range node.Status.Conditions {
+		if string(c.Type) == condition.Type && c.Message != "" {
+			return fmt.Sprintf("condition %s (%s)", condition.String(), c.Message)
+		}
+	}
+	return "condition " + condition.String()
+}
+
+// nodeRecoveredFromCondition reports whether the node condition has not had the configured status for at least the
+// stabilization period. Conditions missing from the node status count as recovered
+func nodeRecoveredFromCondition(node v1.Node, condition config.NodeConditionDetection, stabilizationPeriod time.Duration, now time.Time) bool {
+	for _, c := range node.Status.Conditions {
+		if string(c.Type) != condition.Type {
+			continue
+		}
+		return string(c.Status) != condition.Status && now.Sub(c.LastTransitionTime.Time) >= stabilizationPeriod
+	}
+	return true
+}
+
+// nodeHasCondition reports whether the node condition has had the configured status for at least its minimum duration
+func nodeHasCondition(node v1.Node, condition config.NodeConditionDetection, now time.Time) bool {
+	for _, c := range node.Status.Conditions {
+		if string(c.Type) != condition.Type {
+			continue
+		}
+		return string(c.Status) == condition.Status && now.Sub(c.LastTransitionTime.Time) >= condition.MinDuration
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package detection
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/config"
+	"github.com/adobe/k8s-shredder/pkg/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// NodeLabelsDetectorName is the name of the detector parking the nodes matching NodeLabelsToDetect
+const NodeLabelsDetectorName = "node-labels"
+
+func init() {
+	Register(NodeLabelsDetectorName, newNodeLabelsDetector)
+}
+
+// nodeLabelsDetector finds the nodes matching label selectors, letting external tooling request parking by labeling nodes
+type nodeLabelsDetector struct {
+	appContext *utils.AppContext
+	logger     *log.Entry
+}
+
+func newNodeLabelsDetector(appContext *utils.AppContext) Detector {
+	return &nodeLabelsDetector{
+		appContext: appContext,
+		logger:     log.WithField("detector", NodeLabelsDetectorName),
+	}
+}
+
+// Name returns the name of the detector
+func (d *nodeLabelsDetector) Name() string {
+	return NodeLabelsDetectorName
+}
+
+// Enabled reports whether the node label detection is turned on, with at least one selector to match
+func (d *nodeLabelsDetector) Enabled(cfg config.Config) bool {
+	return cfg.EnableNodeLabelDetection && len(cfg.NodeLabelsToDetect) > 0
+}
+
+// Interval returns how often the detector runs on its own
+func (d *nodeLabelsDetector) Interval(cfg config.Config) time.Duration {
+	return 0
+}
+
+// Detect returns the nodes which are not parked yet and match NodeLabelsToDetect
+func (d *nodeLabelsDetector) Detect(ctx context.Context) ([]utils.NodeInfo, error) {
+	cfg := *d.appContext.Config()
+
+	selectors, err := cfg.NodeLabelSelectors()
+	if err != nil {
+		return nil, err
+	}
+
+	allNodes, err := d.appContext.ListNodes(ctx, d.appContext.BackgroundK8sClient, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list nodes")
+	}
+
+	var nodes []utils.NodeInfo
+	for _, node := range allNodes {
+		if node.Labels[cfg.UpgradeStatusLabel] == cfg.UpgradeStatusParkedValue {
+			continue
+		}
+
+		if matched := matchNodeLabels(labels.Set(node.Labels), selectors, cfg.NodeLabelsMatchMode); len(matched) > 0 {
+			d.logger.Debugf("Node %s matches NodeLabelsToDetect", node.Name)
+			nodeInfo := utils.NewNodeInfo(node)
+			nodeInfo.ReasonMessage = "matched label selector " + strings.Join(matched, ", ")
+			nodes = append(nodes, nodeInfo)
+		}
+	}
+
+	return nodes, nil
+}
+
+// matchNodeLabels checks the node labels against the selectors, any or all of them having to match depending on mode.
+// The matching selectors are returned, none when the node doesn't match
+func matchNodeLabels(nodeLabels labels.Set, selectors []config.NodeLabelMatcher, mode string) []string {
+	var matched []string
+	for _, selector := range selectors {
+		matches := selector.Matches(nodeLabels)
+		if mode == config.NodeLabelsMatchAll && !matches {
+			return nil
+		}
+		if matches {
+			matched = append(matched, selector.String())
+			if mode != config.NodeLabelsMatchAll {
+				return matched
+			}
+		}
+	}
+	return matched
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package detection
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/config"
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+	"github.com/adobe/k8s-shredder/pkg/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NodeLifetimeDetectorName is the name of the detector parking nodes older than MaxNodeLifetime
+const NodeLifetimeDetectorName = "node-lifetime"
+
+func init() {
+	Register(NodeLifetimeDetectorName, newNodeLifetimeDetector)
+}
+
+// nodeLifetimeDetector finds nodes that have been running for longer than MaxNodeLifetime, so that nodes get recycled
+// regularly
+type nodeLifetimeDetector struct {
+	appContext *utils.AppContext
+	logger     *log.Entry
+}
+
+func newNodeLifetimeDetector(appContext *utils.AppContext) Detector {
+	return &nodeLifetimeDetector{
+		appContext: appContext,
+		logger:     log.WithField("detector", NodeLifetimeDetectorName),
+	}
+}
+
+// Name returns the name of the detector
+func (d *nodeLifetimeDetector) Name() string {
+	return NodeLifetimeDetectorName
+}
+
+// Enabled reports whether a maximum node lifetime is configured
+func (d *nodeLifetimeDetector) Enabled(cfg config.Config) bool {
+	return cfg.MaxNodeLifetime > 0
+}
+
+// Interval returns how often the detector runs on its own
+func (d *nodeLifetimeDetector) Interval(cfg config.Config) time.Duration {
+	return 0
+}
+
+// Detect returns the nodes which are not parked yet and are older than MaxNodeLifetime. The age of all the nodes is
+// exposed through the shredder_node_age_seconds metric
+func (d *nodeLifetimeDetector) Detect(ctx context.Context) ([]utils.NodeInfo, error) {
+	allNodes, err := d.appContext.ListNodes(ctx, d.appContext.BackgroundK8sClient, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list nodes")
+	}
+
+	var nodes []utils.NodeInfo
+	ages := make(map[string]float64, len(allNodes))
+
+	for _, node := range allNodes {
+		age := time.Since(node.CreationTimestamp.Time)
+		ages[node.Name] = age.Seconds()
+
+		if node.Labels[d.appContext.Config().UpgradeStatusLabel] == d.appContext.Config().UpgradeStatusParkedValue || age < d.appContext.Config().MaxNodeLifetime {
+			continue
+		}
+
+		d.logger.Debugf("Node %s is %s old, more than MaxNodeLifetime", node.Name, age.Round(time.Second).String())
+		nodeInfo := utils.NewNodeInfo(node)
+		nodeInfo.ReasonMessage = fmt.Sprintf("node is %s old, more than the MaxNodeLifetime of %s", age.Round(time.Second).String(), d.appContext.Config().MaxNodeLifetime.String())
+		nodes = append(nodes, nodeInfo)
+	}
+
+	metrics.ObserveNodeAges(d.appContext.Cluster, ages)
+	return nodes, nil
+}
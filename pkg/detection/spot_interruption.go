@@ -0,0 +1,109 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package detection
+
+import (
+	"context"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/config"
+	"github.com/adobe/k8s-shredder/pkg/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SpotInterruptionDetectorName is the name of the detector parking the nodes about to be reclaimed by the cloud provider
+const SpotInterruptionDetectorName = config.SpotInterruptionReason
+
+func init() {
+	Register(SpotInterruptionDetectorName, newSpotInterruptionDetector)
+}
+
+// spotInterruptionDetector finds the spot or preemptible nodes about to be reclaimed, as signaled by a taint, e.g. set by
+// the AWS Node Termination Handler, or by a node condition
+type spotInterruptionDetector struct {
+	appContext *utils.AppContext
+	logger     *log.Entry
+}
+
+func newSpotInterruptionDetector(appContext *utils.AppContext) Detector {
+	return &spotInterruptionDetector{
+		appContext: appContext,
+		logger:     log.WithField("detector", SpotInterruptionDetectorName),
+	}
+}
+
+// Name returns the name of the detector
+func (d *spotInterruptionDetector) Name() string {
+	return SpotInterruptionDetectorName
+}
+
+// Enabled reports whether the spot interruption detection is turned on, with at least one signal to look for
+func (d *spotInterruptionDetector) Enabled(cfg config.Config) bool {
+	return cfg.EnableSpotInterruptionDetection && len(cfg.SpotInterruptionTaints)+len(cfg.SpotInterruptionConditions) > 0
+}
+
+// Interval returns how often the detector runs on its own
+func (d *spotInterruptionDetector) Interval(cfg config.Config) time.Duration {
+	return cfg.SpotInterruptionDetectionInterval
+}
+
+// Urgent reports that the interrupted nodes must be drained right away
+func (d *spotInterruptionDetector) Urgent() bool {
+	return true
+}
+
+// Detect returns the nodes which are not parked yet and got a spot interruption signal
+func (d *spotInterruptionDetector) Detect(ctx context.Context) ([]utils.NodeInfo, error) {
+	cfg := *d.appContext.Config()
+
+	allNodes, err := d.appContext.ListNodes(ctx, d.appContext.BackgroundK8sClient, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list nodes")
+	}
+
+	var nodes []utils.NodeInfo
+	for _, node := range allNodes {
+		if node.Labels[cfg.UpgradeStatusLabel] == cfg.UpgradeStatusParkedValue {
+			continue
+		}
+
+		if signal := spotInterruptionSignal(node, cfg.SpotInterruptionTaints, cfg.SpotInterruptionConditions); signal != "" {
+			d.logger.Infof("Node %s is about to be interrupted, signaled by %s", node.Name, signal)
+			nodeInfo := utils.NewNodeInfo(node)
+			nodeInfo.Urgent = true
+			nodeInfo.ReasonMessage = "spot interruption signaled by " + signal
+			nodes = append(nodes, nodeInfo)
+		}
+	}
+
+	return nodes, nil
+}
+
+// spotInterruptionSignal returns the taint or the True node condition signaling the interruption of a node, or an empty
+// string when there is none
+func spotInterruptionSignal(node v1.Node, taints, conditions []string) string {
+	for _, taint := range node.Spec.Taints {
+		if slices.Contains(taints, taint.Key) {
+			return "taint " + taint.Key
+		}
+	}
+	for _, condition := range node.Status.Conditions {
+		if condition.Status == v1.ConditionTrue && slices.Contains(conditions, string(condition.Type)) {
+			return "condition " + string(condition.Type)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package handler
+
+import (
+	"fmt"
+	"slices"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// flaggerProgressingPhases are the Flagger Canary phases during which an analysis is running or about to be promoted
+var flaggerProgressingPhases = []string{"Waiting", "Progressing", "WaitingPromotion", "Promoting", "Finalising"}
+
+// getProgressingCanary returns a description of the canary the controller object is going through, either as an Argo
+// Rollout in the middle of its canary steps or as the target of a progressing Flagger Canary. An empty string is
+// returned otherwise
+func (h *Handler) getProgressingCanary(co *controllerObject) (string, error) {
+	if co.Kind == "Rollout" {
+		if step, ok := argoRolloutCanaryStep(co.Object.(*unstructured.Unstructured)); ok {
+			return fmt.Sprintf("Argo Rollout %s is at canary step %s", co.Name, step), nil
+		}
+		return "", nil
+	}
+
+	gvr := schema.GroupVersionResource{
+		Group:    "flagger.app",
+		Version:  h.appContext.Config().FlaggerAPIVersion,
+		Resource: "canaries",
+	}
+	canaries, err := h.appContext.DynamicK8SClient.Resource(gvr).Namespace(co.Namespace).List(h.appContext.Context, metav1.ListOptions{})
+	if err != nil {
+		// Flagger is not installed in the cluster
+		if apierrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	for _, canary := range canaries.Items {
+		kind, _, _ := unstructured.NestedString(canary.Object, "spec", "targetRef", "kind")
+		name, _, _ := unstructured.NestedString(canary.Object, "spec", "targetRef", "name")
+		if kind != co.Kind || name != co.Name {
+			continue
+		}
+
+		phase, _, _ := unstructured.NestedString(canary.Object, "status", "phase")
+		if slices.Contains(flaggerProgressingPhases, phase) {
+			return fmt.Sprintf("Flagger Canary %s is %s", canary.GetName(), phase), nil
+		}
+	}
+
+	return "", nil
+}
+
+// argoRolloutCanaryStep returns the canary step an Argo Rollout is at, when it is rolling out a new revision through
+// its canary steps and was not aborted
+func argoRolloutCanaryStep(rollout *unstructured.Unstructured) (string, bool) {
+	steps, found, _ := unstructured.NestedSlice(rollout.Object, "spec", "strategy", "canary", "steps")
+	if !found || len(steps) == 0 {
+		return "", false
+	}
+
+	if aborted, _, _ := unstructured.NestedBool(rollout.Object, "status", "abort"); aborted {
+		return "", false
+	}
+
+	// the stable ReplicaSet is the current one once the rollout is fully promoted
+	currentPodHash, _, _ := unstructured.NestedString(rollout.Object, "status", "currentPodHash")
+	stableRS, _, _ := unstructured.NestedString(rollout.Object, "status", "stableRS")
+	if currentPodHash == "" || currentPodHash == stableRS {
+		return "", false
+	}
+
+	stepIndex, found, _ := unstructured.NestedInt64(rollout.Object, "status", "currentStepIndex")
+	if !found || stepIndex >= int64(len(steps)) {
+		return "", false
+	}
+
+	return fmt.Sprintf("%d/%d", stepIndex+1, len(steps)), true
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package handler
+
+import (
+	"strings"
+
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+	"github.com/adobe/k8s-shredder/pkg/utils"
+)
+
+// balanceCapacity unparks the most recently parked nodes while too many pods can't be scheduled, and parks them again
+// once the unschedulable pods went down under CapacityReparkPendingPods. The gap between both thresholds, along with
+// CapacityUnparkMinDuration, keeps nodes from flapping
+func (h *Handler) balanceCapacity() {
+	cfg := *h.appContext.Config()
+	logger := h.logger.WithField("source", "capacity")
+
+	pending, err := utils.CountUnschedulablePods(h.appContext)
+	if err != nil {
+		logger.Errorf("Failed to count unschedulable pods: %s", err.Error())
+		h.countError()
+		return
+	}
+	metrics.ShredderUnschedulablePods.WithLabelValues(h.appContext.Cluster).Set(float64(pending))
+
+	switch {
+	case pending >= cfg.CapacityUnparkPendingPods:
+		unparked, err := utils.UnparkNodesForCapacity(h.appContext, cfg.CapacityUnparkNodesPerLoop)
+		if err != nil {
+			logger.Errorf("Failed to unpark nodes: %s", err.Error())
+			h.countError()
+		}
+		if len(unparked) > 0 {
+			h.nodesChanged.Store(true)
+			logger.Warnf("%d pods can't be scheduled, temporarily unparked %s", pending, strings.Join(unparked, ", "))
+			metrics.ShredderCapacityUnparksTotal.WithLabelValues(h.appContext.Cluster).Add(float64(len(unparked)))
+		}
+	case pending <= cfg.CapacityReparkPendingPods:
+		reparked, err := utils.ReparkCapacityUnparkedNodes(h.appContext, cfg.CapacityUnparkMinDuration)
+		if err != nil {
+			logger.Errorf("Failed to park nodes again: %s", err.Error())
+			h.countError()
+		}
+		if len(reparked) > 0 {
+			h.nodesChanged.Store(true)
+			logger.Infof("Capacity restored, parking %s again", strings.Join(reparked, ", "))
+			metrics.ShredderCapacityReparksTotal.WithLabelValues(h.appContext.Cluster).Add(float64(len(reparked)))
+		}
+	}
+
+	unparkedNodes, err := utils.CountCapacityUnparkedNodes(h.appContext)
+	if err != nil {
+		logger.Errorf("Failed to count the nodes unparked to restore capacity: %s", err.Error())
+		return
+	}
+	metrics.ShredderCapacityUnparkedNodes.WithLabelValues(h.appContext.Cluster).Set(float64(unparkedNodes))
+}
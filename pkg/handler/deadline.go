@@ -0,0 +1,129 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package handler
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+	"github.com/adobe/k8s-shredder/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// annotatePodDeadlines records in the PodEvictionDeadlineAnnotation of the pods of a parked node when they get force
+// evicted, updating the pods whose annotation is missing or outdated, e.g. after the TTL of the node changed
+func (h *Handler) annotatePodDeadlines(pods []v1.Pod, expiresOn time.Time) {
+	annotation := h.appContext.Config().PodEvictionDeadlineAnnotation
+	if annotation == "" || (h.appContext.IsDryRun() && !h.appContext.ServerSideDryRun()) {
+		return
+	}
+
+	deadline := expiresOn.UTC().Format(time.RFC3339)
+	patchData, _ := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				annotation: deadline,
+			},
+		},
+	})
+
+	for _, pod := range pods {
+		if pod.Annotations[annotation] == deadline || pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		err := utils.RetryAPICall(h.appContext, func() error {
+			_, err := h.appContext.K8sClient.CoreV1().Pods(pod.Namespace).Patch(h.appContext.Context, pod.Name, types.MergePatchType, patchData,
+				metav1.PatchOptions{FieldManager: "k8s-shredder", DryRun: h.appContext.DryRunOption()})
+			return err
+		})
+		if err != nil {
+			// the annotation is informative only, the pod keeps being processed
+			h.logger.WithFields(log.Fields{
+				"namespace": pod.Namespace,
+				"pod":       pod.Name,
+			}).Warnf("Failed to annotate pod with %s: %s", annotation, err.Error())
+			h.countError()
+		}
+	}
+}
+
+// CleanOrphanedPods removes the PodEvictionDeadlineAnnotation from the pods whose node is not parked anymore, e.g. after
+// it got unparked, so that application teams are not told about evictions that won't happen
+func (h *Handler) CleanOrphanedPods() {
+	annotation := h.appContext.Config().PodEvictionDeadlineAnnotation
+	if annotation == "" || utils.PauseStatus().Paused {
+		return
+	}
+
+	parkedNodes, err := h.getParkedNodes()
+	if err != nil {
+		h.logger.Errorf("Failed to list parked nodes while looking for orphaned pods: %s", err.Error())
+		h.countError()
+		return
+	}
+	parked := make(map[string]bool, len(parkedNodes.Items))
+	for _, node := range parkedNodes.Items {
+		parked[node.Name] = true
+	}
+
+	pods, err := utils.ListPods(h.appContext.Context, h.appContext.BackgroundK8sClient, "", h.appContext.Config().APIListPageSize, metav1.ListOptions{})
+	if err != nil {
+		h.logger.Errorf("Failed to list pods while looking for orphaned pods: %s", err.Error())
+		h.countError()
+		return
+	}
+
+	patchData, _ := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				annotation: nil,
+			},
+		},
+	})
+
+	cleaned := 0
+	for _, pod := range pods {
+		if _, found := pod.Annotations[annotation]; !found || parked[pod.Spec.NodeName] {
+			continue
+		}
+
+		logger := h.logger.WithFields(log.Fields{
+			"namespace": pod.Namespace,
+			"pod":       pod.Name,
+		})
+		if h.appContext.IsDryRun() {
+			logger.Infof("Would have removed %s from orphaned pod", annotation)
+			continue
+		}
+
+		err := utils.RetryAPICall(h.appContext, func() error {
+			_, err := h.appContext.K8sClient.CoreV1().Pods(pod.Namespace).Patch(h.appContext.Context, pod.Name, types.MergePatchType, patchData, metav1.PatchOptions{FieldManager: "k8s-shredder"})
+			return err
+		})
+		if err != nil {
+			logger.Warnf("Failed to remove %s from orphaned pod: %s", annotation, err.Error())
+			h.countError()
+			continue
+		}
+		metrics.ShredderOrphanedParkedPodsCleanedTotal.WithLabelValues(h.appContext.Cluster).Inc()
+		cleaned++
+	}
+
+	if cleaned > 0 {
+		h.logger.Infof("Removed %s from %d pods whose node is not parked anymore", annotation, cleaned)
+	}
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package handler
+
+import (
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// evictedPod holds a pod evicted by k8s-shredder whose deletion was not observed yet
+type evictedPod struct {
+	namespace string
+	name      string
+	evictedAt time.Time
+}
+
+// trackEvictedPod starts tracking an evicted pod, so that the time it takes to be deleted is measured
+func (h *Handler) trackEvictedPod(pod v1.Pod) {
+	if h.appContext.IsDryRun() {
+		return
+	}
+	h.evictedPods.LoadOrStore(pod.UID, evictedPod{namespace: pod.Namespace, name: pod.Name, evictedAt: time.Now()})
+}
+
+// observeEvictedPods checks whether the tracked evicted pods are gone and records how long they took to be deleted.
+// The measure is as precise as the eviction loop interval. Pods still around after the longest parked node TTL are
+// forgotten, as they are force deleted by then
+func (h *Handler) observeEvictedPods() {
+	h.evictedPods.Range(func(key, value any) bool {
+		evicted := value.(evictedPod)
+
+		pod, err := h.appContext.BackgroundK8sClient.CoreV1().Pods(evicted.namespace).Get(h.appContext.Context, evicted.name, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err) || (err == nil && pod.UID != key.(types.UID)):
+			metrics.ShredderPodEvictionDurationSeconds.WithLabelValues(h.appContext.Cluster, evicted.namespace).Observe(time.Since(evicted.evictedAt).Seconds())
+			h.evictedPods.Delete(key)
+		case err != nil:
+			h.logger.Debugf("Failed to check whether evicted pod %s/%s is gone: %s", evicted.namespace, evicted.name, err.Error())
+		case time.Since(evicted.evictedAt) > h.appContext.Config().MaxParkedNodeTTL():
+			h.evictedPods.Delete(key)
+		}
+		return true
+	})
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package handler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/config"
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+	"github.com/adobe/k8s-shredder/pkg/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+// ExpiryAction is what the eviction loop does with the pods left on a parked node once its TTL expired. It runs on
+// every eviction loop until the node is drained
+type ExpiryAction interface {
+	// Name identifies the action in the ExpiryAction configuration
+	Name() string
+	// Expire acts on the pods left on an expired parked node, gracePeriod being the one of the force eviction tier reached
+	Expire(node v1.Node, pods []v1.Pod, gracePeriod time.Duration) error
+}
+
+// newExpiryActions returns the available expiry actions, by name
+func newExpiryActions(h *Handler) map[string]ExpiryAction {
+	actions := map[string]ExpiryAction{}
+	for _, action := range []ExpiryAction{
+		&forceDeleteAction{h: h},
+		&noExecuteTaintAction{h: h},
+		&notifyAction{h: h},
+		&webhookAction{h: h},
+	} {
+		actions[action.Name()] = action
+	}
+	return actions
+}
+
+// expiryActionFor returns the expiry action of a parked node, based on the reason it was parked for
+func (h *Handler) expiryActionFor(node v1.Node) ExpiryAction {
+	return h.expiryActions[h.appContext.Config().ExpiryActionFor(node.Labels[h.appContext.Config().ParkingReasonLabel])]
+}
+
+// forceDeleteAction deletes the pods left on the expired parked node
+type forceDeleteAction struct {
+	h *Handler
+}
+
+func (a *forceDeleteAction) Name() string {
+	return config.ExpiryActionForceDelete
+}
+
+func (a *forceDeleteAction) Expire(node v1.Node, pods []v1.Pod, gracePeriod time.Duration) error {
+	h := a.h
+	h.logger.Infof("Force evicting pods from expired parked node %s", node.Name)
+	h.transitionNodeState(&node, utils.NodeStateForceEvicting)
+
+	deletePropagationBackground := metav1.DeletePropagationBackground
+	deleteOptions := &metav1.DeleteOptions{
+		PropagationPolicy:  &deletePropagationBackground,
+		GracePeriodSeconds: ptr.To(int64(gracePeriod / time.Second)),
+	}
+	if h.appContext.IsDryRun() {
+		deleteOptions.DryRun = []string{metav1.DryRunAll}
+	}
+
+	for _, pod := range pods {
+		if h.appContext.Stopping() {
+			return errors.Errorf("Stopped force evicting pods from node %s, shutting down", node.Name)
+		}
+
+		err := h.deletePod(pod, deleteOptions)
+		if err != nil {
+			h.logger.WithFields(log.Fields{
+				"namespace": pod.Namespace,
+				"pod":       pod.Name,
+			}).Warnf("Failed to delete pod: %s", err.Error())
+			continue
+		}
+		metrics.ShredderProcessedPodsTotal.WithLabelValues(h.appContext.Cluster).Inc()
+	}
+
+	return nil
+}
+
+// noExecuteTaintAction switches the ParkedNodeTaint effect to NoExecute, leaving the pods not tolerating it to the
+// taint manager
+type noExecuteTaintAction struct {
+	h *Handler
+}
+
+func (a *noExecuteTaintAction) Name() string {
+	return config.ExpiryActionNoExecuteTaint
+}
+
+func (a *noExecuteTaintAction) Expire(node v1.Node, _ []v1.Pod, _ time.Duration) error {
+	h := a.h
+	if utils.ParkedNodeTaintEscalated(node, *h.appContext.Config()) {
+		return nil
+	}
+
+	return utils.RetryAPICall(h.appContext, func() error {
+		return utils.EscalateParkedNodeTaint(h.appContext, node.Name, h.logger.WithField("node", node.Name))
+	})
+}
+
+// notifyAction records a warning event on the expired parked node, once, leaving its pods alone
+type notifyAction struct {
+	h *Handler
+}
+
+func (a *notifyAction) Name() string {
+	return config.ExpiryActionNotify
+}
+
+func (a *notifyAction) Expire(node v1.Node, pods []v1.Pod, _ time.Duration) error {
+	h := a.h
+	if h.expiryNotified(node.Name) {
+		return nil
+	}
+
+	message := fmt.Sprintf("Parked node expired with %d pods left, they are not deleted", len(pods))
+	h.logger.WithField("node", node.Name).Warn(message)
+	h.appContext.RecordEvent(&node, v1.EventTypeWarning, "ParkedNodeExpired", message)
+	h.recordLifecycle(node.Name, func(l *nodeLifecycle) { l.expiryNotified = true })
+	return nil
+}
+
+// ExpiryNotification is posted to ExpiryWebhookURL, once, for every parked node expiring with pods left
+type ExpiryNotification struct {
+	Node      string    `json:"node"`
+	Cluster   string    `json:"cluster,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	ExpiredAt time.Time `json:"expiredAt"`
+	Pods      []string  `json:"pods"`
+	DryRun    bool      `json:"dryRun"`
+}
+
+// webhookAction posts the expired parked node and its pods to ExpiryWebhookURL, leaving the pods to the receiver
+type webhookAction struct {
+	h *Handler
+}
+
+func (a *webhookAction) Name() string {
+	return config.ExpiryActionWebhook
+}
+
+func (a *webhookAction) Expire(node v1.Node, pods []v1.Pod, _ time.Duration) error {
+	h := a.h
+	if h.expiryNotified(node.Name) {
+		return nil
+	}
+
+	// the expiry time was already validated by processNode
+	expiresOn, _ := utils.GetParkedNodeExpiryTime(node, h.appContext.Config().ExpiresOnLabel)
+	notification := ExpiryNotification{
+		Node:      node.Name,
+		Cluster:   h.appContext.Cluster,
+		Reason:    node.Labels[h.appContext.Config().ParkingReasonLabel],
+		ExpiredAt: expiresOn,
+		Pods:      make([]string, 0, len(pods)),
+		DryRun:    h.appContext.IsDryRun(),
+	}
+	for _, pod := range pods {
+		notification.Pods = append(notification.Pods, fmt.Sprintf("%s/%s", pod.Namespace, pod.Name))
+	}
+
+	// the notification is sent again during the next eviction loop when it failed
+	if err := h.postJSON(h.appContext.Config().ExpiryWebhookURL, notification); err != nil {
+		return errors.Wrapf(err, "Failed to send the expiry notification of node %s", node.Name)
+	}
+
+	h.logger.WithField("node", node.Name).Infof("Sent the expiry notification for %d pods left", len(pods))
+	h.recordLifecycle(node.Name, func(l *nodeLifecycle) { l.expiryNotified = true })
+	return nil
+}
+
+// expiryNotified checks whether the expiry of a parked node was already notified
+func (h *Handler) expiryNotified(nodeName string) bool {
+	l := h.lifecycle(nodeName)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.expiryNotified
+}
@@ -0,0 +1,412 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package handler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/config"
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+	"github.com/adobe/k8s-shredder/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// podAction is the action taken by the eviction loop for a pod running on a parked node
+type podAction string
+
+const (
+	// podActionNone means the pod is counted as processed, but nothing is done to it
+	podActionNone podAction = "none"
+	// podActionSkip means the pod is left alone during this loop
+	podActionSkip podAction = "skip"
+	// podActionEvict means the pod is evicted using the eviction API
+	podActionEvict podAction = "evict"
+	// podActionLiveMigrate means the KubeVirt VirtualMachineInstance run by the pod is live migrated
+	podActionLiveMigrate podAction = "live-migrate"
+	// podActionRolloutRestart means the pod controller object is rollout restarted
+	podActionRolloutRestart podAction = "rollout-restart"
+	// podActionForceDelete means the pod is deleted without a grace period as its node expired
+	podActionForceDelete podAction = "force-delete"
+	// podActionRepark means the expired node of the pod is parked again, as the pod was not given notice with the
+	// parking labels, see EvictionSafetyCheck
+	podActionRepark podAction = "repark"
+)
+
+// tracer records a rule consulted while deciding what to do with a pod, along with its outcome
+type tracer func(rule, outcome string)
+
+// noTrace is used by the eviction loop, which does not need to keep track of the rules consulted
+func noTrace(string, string) {}
+
+// decidePodAction returns the action the eviction loop takes for a pod running on a parked node expiring at expiresOn
+// and parked for ttl. The controller object of the pod is returned as well when it was looked up. With explain, the
+// decision leaves no trace: no metric nor error is recorded and the owners of paused Argo Rollouts are not notified
+func (h *Handler) decidePodAction(pod v1.Pod, expiresOn time.Time, ttl time.Duration, trace tracer, explain bool) (podAction, *controllerObject) {
+	cfg := *h.appContext.Config()
+
+	countError := h.countError
+	inc := func(c prometheus.Counter) { c.Inc() }
+	if explain {
+		countError = func() {}
+		inc = func(prometheus.Counter) {}
+	}
+
+	if time.Now().UTC().After(expiresOn) {
+		trace("parked node expired", fmt.Sprintf("yes, it expired on %s", expiresOn.Format(time.RFC3339)))
+		if cfg.EvictionSafetyCheck {
+			missing, err := utils.PodMissingParkingLabels(h.appContext, pod)
+			if err != nil {
+				h.logger.WithFields(log.Fields{
+					"namespace": pod.Namespace,
+					"pod":       pod.Name,
+				}).Warnf("Failed to check the parking labels of pod: %s", err.Error())
+				countError()
+				trace("parking labels set", fmt.Sprintf("unknown, %s", err.Error()))
+				return podActionSkip, nil
+			}
+			if missing {
+				trace("parking labels set", "no, the pod was not given notice, the node gets parked again")
+				return podActionRepark, nil
+			}
+			trace("parking labels set", "yes")
+		}
+		return podActionForceDelete, nil
+	}
+	trace("parked node expired", fmt.Sprintf("no, it expires on %s", expiresOn.Format(time.RFC3339)))
+
+	if !utils.PodEvictionAllowed(pod, cfg.AllowEvictionLabel) {
+		h.logger.Debugf("Skipping %s as it has '%s=false' label set", pod.Name, cfg.AllowEvictionLabel)
+		trace("eviction allowed", fmt.Sprintf("no, the pod has the '%s=false' label set", cfg.AllowEvictionLabel))
+		return podActionSkip, nil
+	}
+	trace("eviction allowed", "yes")
+
+	if cfg.RespectDoNotDisruptAnnotation {
+		if pod.Annotations[utils.DoNotDisruptAnnotation] == "true" {
+			h.logger.Debugf("Skipping %s as it has the '%s=true' annotation set", pod.Name, utils.DoNotDisruptAnnotation)
+			inc(metrics.ShredderDoNotDisruptPodsSkippedTotal.WithLabelValues(h.appContext.Cluster))
+			trace("disruption allowed", fmt.Sprintf("no, the pod has the '%s=true' annotation set", utils.DoNotDisruptAnnotation))
+			return podActionSkip, nil
+		}
+		trace("disruption allowed", "yes")
+	}
+
+	if cfg.RespectSafeToEvictAnnotation {
+		if pod.Annotations[utils.SafeToEvictAnnotation] == "false" {
+			h.logger.Debugf("Skipping %s as it has the '%s=false' annotation set", pod.Name, utils.SafeToEvictAnnotation)
+			inc(metrics.ShredderSafeToEvictPodsSkippedTotal.WithLabelValues(h.appContext.Cluster))
+			trace("safe to evict", fmt.Sprintf("no, the pod has the '%s=false' annotation set", utils.SafeToEvictAnnotation))
+			return podActionSkip, nil
+		}
+		trace("safe to evict", "yes")
+	}
+
+	if cfg.SkipEvictionNamespaceAnnotation != "" {
+		skipped, err := h.namespaceSkipsEviction(pod.Namespace)
+		if err != nil {
+			h.logger.WithField("namespace", pod.Namespace).Warnf("Failed to get namespace: %s", err.Error())
+			countError()
+			trace("namespace opted out of eviction", fmt.Sprintf("unknown, %s", err.Error()))
+			return podActionSkip, nil
+		}
+		if skipped {
+			h.logger.Debugf("Skipping %s as its namespace has the '%s=true' annotation set", pod.Name, cfg.SkipEvictionNamespaceAnnotation)
+			trace("namespace opted out of eviction", fmt.Sprintf("yes, the namespace has the '%s=true' annotation set", cfg.SkipEvictionNamespaceAnnotation))
+			return podActionSkip, nil
+		}
+		trace("namespace opted out of eviction", "no")
+	}
+
+	if cfg.DeferJobEvictions {
+		reason, err := h.deferJobEviction(pod, expiresOn, ttl)
+		if err != nil {
+			h.logger.WithFields(log.Fields{
+				"namespace": pod.Namespace,
+				"pod":       pod.Name,
+			}).Warnf("Failed to get pod Job: %s", err.Error())
+			countError()
+			trace("Job running", fmt.Sprintf("unknown, %s", err.Error()))
+			return podActionSkip, nil
+		}
+		if reason != "" {
+			h.logger.Debugf("Skipping %s as its %s", pod.Name, reason)
+			inc(metrics.ShredderJobEvictionsDeferredTotal.WithLabelValues(h.appContext.Cluster, "eviction"))
+			trace("Job running", fmt.Sprintf("yes, %s", reason))
+			return podActionSkip, nil
+		}
+		trace("Job running", "no")
+	}
+
+	if cfg.EnableKubeVirtLiveMigration {
+		if vmi := virtLauncherVMI(pod); vmi != "" {
+			trace("KubeVirt VirtualMachineInstance", fmt.Sprintf("yes, the pod runs %s, live migrating it unless it is not live migratable", vmi))
+			return podActionLiveMigrate, nil
+		}
+		trace("KubeVirt VirtualMachineInstance", "no")
+	}
+
+	if cfg.NamespacePrefixSkipInitialEviction == "" || !strings.HasPrefix(pod.Namespace, cfg.NamespacePrefixSkipInitialEviction) {
+		rrThresholdTime := ttl * time.Duration(100-cfg.RollingRestartThreshold*100) / 100
+		rrStartTime := expiresOn.Add(-rrThresholdTime)
+		if time.Now().UTC().Before(rrStartTime) {
+			trace("rolling restart threshold reached", fmt.Sprintf("no, rollout restarts start on %s", rrStartTime.Format(time.RFC3339)))
+			return podActionEvict, nil
+		}
+		trace("rolling restart threshold reached", "yes")
+	} else {
+		trace("initial eviction skipped", fmt.Sprintf("yes, the namespace has the '%s' prefix", cfg.NamespacePrefixSkipInitialEviction))
+	}
+
+	co, err := h.getControllerObject(pod)
+	if err != nil {
+		h.logger.WithFields(log.Fields{
+			"namespace": pod.Namespace,
+			"pod":       pod.Name,
+		}).Warnf("Failed to get pod controller object: %s. Proceeding directly with pod eviction", err.Error())
+		trace("controller object found", fmt.Sprintf("no, %s", err.Error()))
+		return podActionEvict, nil
+	}
+	trace("controller object found", fmt.Sprintf("yes, %s", co.Fingerprint()))
+
+	// For pods handled by a deployment, statefulset, replicaset or argo rollouts controller, try to rollout restart those objects
+	if !slices.Contains([]string{"Deployment", "StatefulSet", "ReplicaSet", "ReplicationController", "Rollout", "CloneSet", "AdvancedStatefulSet"}, co.Kind) {
+		trace("controller object supports rollout restart", fmt.Sprintf("no, kind %s", co.Kind))
+		return podActionNone, co
+	}
+	trace("controller object supports rollout restart", "yes")
+
+	if h.isRolloutRestartReverted(co, !explain) {
+		trace("rollout restart reverted", "yes, a previous restart was undone, most likely by a GitOps tool")
+		return podActionEvict, co
+	}
+	trace("rollout restart reverted", "no")
+
+	// paused Argo Rollouts don't act on rollout restarts until resumed
+	if isArgoRolloutPaused(co) {
+		policy := cfg.PausedRolloutPolicyFor(co.Namespace)
+		escalationStart := h.pausedRolloutEscalationStart(expiresOn, ttl)
+		if time.Now().UTC().Before(escalationStart) || policy == config.PausedRolloutPolicyEvict {
+			trace("Argo Rollout paused", fmt.Sprintf("yes, evicting the pod, the %s policy applies on %s", policy, escalationStart.Format(time.RFC3339)))
+			return podActionEvict, co
+		}
+		if policy == config.PausedRolloutPolicyNotify && explain {
+			trace("Argo Rollout paused", "yes, the eviction loop notifies its owners and evicts the pod")
+			return podActionEvict, co
+		}
+		if policy == config.PausedRolloutPolicyNotify {
+			if err := h.notifyPausedRollout(co, expiresOn); err != nil {
+				h.logger.WithField("key", co.Fingerprint()).Warnf("Failed to notify paused Argo Rollout: %s", err.Error())
+				countError()
+			}
+			trace("Argo Rollout paused", "yes, notifying its owners and evicting the pod")
+			return podActionEvict, co
+		}
+		trace("Argo Rollout paused", "yes, resuming it along with the rollout restart")
+	}
+
+	rolloutRestartInProgress, err := h.isRolloutRestartInProgress(co)
+	if err != nil {
+		h.logger.WithField("key", co.Fingerprint()).Warnf("Failed to get rollout status: %s", err.Error())
+		countError()
+		trace("rollout restart in progress", fmt.Sprintf("unknown, %s", err.Error()))
+		return podActionSkip, co
+	}
+
+	// if the rollout restart process is in progress, evict the pod instead of trying to do another rollout restart
+	if rolloutRestartInProgress {
+		trace("rollout restart in progress", "yes")
+		return podActionEvict, co
+	}
+	trace("rollout restart in progress", "no")
+
+	if h.appContext.Config().DeferRestartsDuringHPAScaling {
+		hpa, err := h.getScalingHPA(co)
+		if err != nil {
+			h.logger.WithField("key", co.Fingerprint()).Warnf("Failed to check HorizontalPodAutoscalers: %s", err.Error())
+			countError()
+			trace("HorizontalPodAutoscaler scaling", fmt.Sprintf("unknown, %s", err.Error()))
+			return podActionSkip, co
+		}
+		if hpa != "" {
+			h.logger.WithField("key", co.Fingerprint()).Debugf("Deferring rollout restart while HorizontalPodAutoscaler %s is scaling", hpa)
+			inc(metrics.ShredderRolloutRestartsDeferredByHPATotal.WithLabelValues(h.appContext.Cluster))
+			trace("HorizontalPodAutoscaler scaling", fmt.Sprintf("yes, %s is scaling, deferring the rollout restart", hpa))
+			return podActionSkip, co
+		}
+		trace("HorizontalPodAutoscaler scaling", "no")
+	}
+
+	// restarting a workload in the middle of a canary would abort its analysis
+	if h.appContext.Config().DeferRestartsDuringCanary {
+		canary, err := h.getProgressingCanary(co)
+		if err != nil {
+			h.logger.WithField("key", co.Fingerprint()).Warnf("Failed to check canaries: %s", err.Error())
+			countError()
+			trace("canary in progress", fmt.Sprintf("unknown, %s", err.Error()))
+			return podActionSkip, co
+		}
+		if canary != "" {
+			h.logger.WithField("key", co.Fingerprint()).Debugf("Deferring rollout restart while %s", canary)
+			inc(metrics.ShredderRolloutRestartsDeferredByCanaryTotal.WithLabelValues(h.appContext.Cluster))
+			trace("canary in progress", fmt.Sprintf("yes, %s, deferring the rollout restart", canary))
+			return podActionSkip, co
+		}
+		trace("canary in progress", "no")
+	}
+
+	return podActionRolloutRestart, co
+}
+
+// namespaceSkipsEviction checks whether a namespace has the SkipEvictionNamespaceAnnotation set on true, looking each
+// namespace up once per eviction loop
+func (h *Handler) namespaceSkipsEviction(namespace string) (bool, error) {
+	if skipped, found := h.skippedNamespaces.Load(namespace); found {
+		return skipped.(bool), nil
+	}
+
+	ns, err := h.appContext.K8sClient.CoreV1().Namespaces().Get(h.appContext.Context, namespace, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	skipped := ns.Annotations[h.appContext.Config().SkipEvictionNamespaceAnnotation] == "true"
+	h.skippedNamespaces.Store(namespace, skipped)
+	return skipped, nil
+}
+
+// ExplanationRule is a rule consulted while deciding what to do with a pod
+type ExplanationRule struct {
+	Rule    string
+	Outcome string
+}
+
+// Explanation describes what the eviction loop would do with a pod and why
+type Explanation struct {
+	Pod     string
+	Node    string
+	Verdict string
+	Rules   []ExplanationRule
+}
+
+func (e *Explanation) trace(rule, outcome string) {
+	e.Rules = append(e.Rules, ExplanationRule{Rule: rule, Outcome: outcome})
+}
+
+// ExplainPod runs the eviction loop decision logic for a single pod against the live cluster state, without acting on it
+func (h *Handler) ExplainPod(namespace, name string) (*Explanation, error) {
+	cfg := *h.appContext.Config()
+
+	pod, err := h.appContext.K8sClient.CoreV1().Pods(namespace).Get(h.appContext.Context, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to get pod %s/%s", namespace, name)
+	}
+
+	e := &Explanation{
+		Pod:     fmt.Sprintf("%s/%s", namespace, name),
+		Node:    pod.Spec.NodeName,
+		Verdict: string(podActionSkip),
+	}
+
+	if pod.Spec.NodeName == "" {
+		e.trace("pod scheduled", "no")
+		return e, nil
+	}
+	e.trace("pod scheduled", fmt.Sprintf("yes, on node %s", pod.Spec.NodeName))
+
+	node, err := h.appContext.K8sClient.CoreV1().Nodes().Get(h.appContext.Context, pod.Spec.NodeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to get node %s", pod.Spec.NodeName)
+	}
+
+	if node.Labels[cfg.UpgradeStatusLabel] != cfg.UpgradeStatusParkedValue {
+		e.trace("node parked", fmt.Sprintf("no, the node is missing the '%s=%s' label", cfg.UpgradeStatusLabel, cfg.UpgradeStatusParkedValue))
+		return e, nil
+	}
+	e.trace("node parked", "yes")
+
+	if utils.NodeHasTaint(*node, cfg.ToBeDeletedTaint) {
+		e.trace("node being deleted", fmt.Sprintf("yes, the node has the %s taint", cfg.ToBeDeletedTaint))
+		return e, nil
+	}
+	e.trace("node being deleted", "no")
+
+	if utils.NodeIsProtected(*node, cfg) {
+		e.trace("node protected", "yes")
+		return e, nil
+	}
+	e.trace("node protected", "no")
+
+	if !utils.NodeHasLabel(*node, cfg.ExpiresOnLabel) {
+		e.trace("node expiry known", fmt.Sprintf("no, the node is missing the %s label", cfg.ExpiresOnLabel))
+		return e, nil
+	}
+
+	expiresOn, err := utils.GetParkedNodeExpiryTime(*node, cfg.ExpiresOnLabel)
+	if err != nil {
+		e.trace("node expiry known", fmt.Sprintf("no, %s", err.Error()))
+		return e, nil
+	}
+	e.trace("node expiry known", "yes")
+
+	if pod.DeletionTimestamp != nil {
+		e.trace("pod terminating", "yes")
+		return e, nil
+	}
+	e.trace("pod terminating", "no")
+
+	if reason := utils.PodExclusionReason(*pod, cfg); reason != "" {
+		e.trace("pod excluded", fmt.Sprintf("yes, %s", reason))
+		return e, nil
+	}
+	e.trace("pod excluded", "no")
+
+	action, _ := h.decidePodAction(*pod, expiresOn, cfg.ParkedNodeTTLFor(node.Labels[cfg.ParkingReasonLabel]), e.trace, true)
+	e.Verdict = string(action)
+
+	if action == podActionRepark {
+		if expiryAction := h.expiryActionFor(*node); expiryAction.Name() != config.ExpiryActionForceDelete {
+			e.trace("expiry action", expiryAction.Name())
+			e.Verdict = expiryAction.Name()
+		}
+		return e, nil
+	}
+
+	if action == podActionForceDelete && cfg.DeferJobEvictions {
+		reason, err := h.deferJobExpiry([]v1.Pod{*pod}, expiresOn)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to get pod Job")
+		}
+		if reason != "" {
+			e.trace("Job near completion", fmt.Sprintf("yes, %s", reason))
+			e.Verdict = string(podActionSkip)
+			return e, nil
+		}
+		e.trace("Job near completion", "no")
+	}
+
+	// pods of expired parked nodes are only deleted by the force-delete expiry action
+	if action == podActionForceDelete {
+		if expiryAction := h.expiryActionFor(*node); expiryAction.Name() != config.ExpiryActionForceDelete {
+			e.trace("expiry action", expiryAction.Name())
+			e.Verdict = expiryAction.Name()
+		}
+	}
+
+	return e, nil
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/config"
+	"github.com/adobe/k8s-shredder/pkg/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestHandler(cfg config.Config, objs ...runtime.Object) *Handler {
+	client := fake.NewSimpleClientset(objs...)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		{Group: "argoproj.io", Version: cfg.ArgoRolloutsAPIVersion, Resource: "rollouts"}: "RolloutList",
+	})
+	appContext := &utils.AppContext{Context: context.Background(), K8sClient: client, BackgroundK8sClient: client, DynamicK8SClient: dynamicClient}
+	appContext.SetConfig(cfg)
+	return NewHandler(appContext)
+}
+
+func controlledBy(kind, name string) []metav1.OwnerReference {
+	controller := true
+	return []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: kind, Name: name, Controller: &controller}}
+}
+
+func TestDecidePodAction(t *testing.T) {
+	ttl := time.Hour
+	baseConfig := config.Config{
+		AllowEvictionLabel:       "shredder.ethos.adobe.net/allow-eviction",
+		UpgradeStatusLabel:       "shredder.ethos.adobe.net/upgrade-status",
+		UpgradeStatusParkedValue: "parked",
+		RollingRestartThreshold:  0.5,
+		ArgoRolloutsAPIVersion:   "v1alpha1",
+	}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+	}
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-1234", Namespace: "default", OwnerReferences: controlledBy("Deployment", "app")},
+	}
+
+	tests := []struct {
+		name       string
+		modify     func(c *config.Config)
+		objs       []runtime.Object
+		pod        v1.Pod
+		expiresIn  time.Duration
+		want       podAction
+		wantErrors int64
+	}{
+		{
+			name:      "expired node",
+			pod:       v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default"}},
+			expiresIn: -time.Minute,
+			want:      podActionForceDelete,
+		},
+		{
+			name:   "expired node with pod missing the parking labels",
+			modify: func(c *config.Config) { c.EvictionSafetyCheck = true },
+			pod:    v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default"}},
+			// the node expired before the pod got labeled
+			expiresIn: -time.Minute,
+			want:      podActionRepark,
+		},
+		{
+			name:   "expired node with labeled pod",
+			modify: func(c *config.Config) { c.EvictionSafetyCheck = true },
+			pod: v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default", Labels: map[string]string{
+				"shredder.ethos.adobe.net/upgrade-status": "parked",
+			}}},
+			expiresIn: -time.Minute,
+			want:      podActionForceDelete,
+		},
+		{
+			name: "eviction not allowed",
+			pod: v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default", Labels: map[string]string{
+				"shredder.ethos.adobe.net/allow-eviction": "false",
+			}}},
+			expiresIn: 50 * time.Minute,
+			want:      podActionSkip,
+		},
+		{
+			name:   "do not disrupt annotation",
+			modify: func(c *config.Config) { c.RespectDoNotDisruptAnnotation = true },
+			pod: v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default", Annotations: map[string]string{
+				utils.DoNotDisruptAnnotation: "true",
+			}}},
+			expiresIn: 50 * time.Minute,
+			want:      podActionSkip,
+		},
+		{
+			name:   "namespace opted out of eviction",
+			modify: func(c *config.Config) { c.SkipEvictionNamespaceAnnotation = "shredder.ethos.adobe.net/skip-eviction" },
+			objs: []runtime.Object{&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default", Annotations: map[string]string{
+				"shredder.ethos.adobe.net/skip-eviction": "true",
+			}}}},
+			pod:       v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default"}},
+			expiresIn: 50 * time.Minute,
+			want:      podActionSkip,
+		},
+		{
+			name:       "namespace lookup failure",
+			modify:     func(c *config.Config) { c.SkipEvictionNamespaceAnnotation = "shredder.ethos.adobe.net/skip-eviction" },
+			pod:        v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "missing"}},
+			expiresIn:  50 * time.Minute,
+			want:       podActionSkip,
+			wantErrors: 1,
+		},
+		{
+			name:      "rolling restart threshold not reached",
+			pod:       v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default", OwnerReferences: controlledBy("ReplicaSet", "app-1234")}},
+			expiresIn: 50 * time.Minute,
+			want:      podActionEvict,
+		},
+		{
+			name:      "pod without controller",
+			pod:       v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default"}},
+			expiresIn: 10 * time.Minute,
+			want:      podActionNone,
+		},
+		{
+			name:      "deployment pod",
+			objs:      []runtime.Object{deployment, replicaSet},
+			pod:       v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default", OwnerReferences: controlledBy("ReplicaSet", "app-1234")}},
+			expiresIn: 10 * time.Minute,
+			want:      podActionRolloutRestart,
+		},
+	}
+
+	for _, tt := range tests {
+		for _, explain := range []bool{true, false} {
+			t.Run(tt.name, func(t *testing.T) {
+				cfg := baseConfig
+				if tt.modify != nil {
+					tt.modify(&cfg)
+				}
+				h := newTestHandler(cfg, tt.objs...)
+
+				var rules []string
+				trace := func(rule, _ string) { rules = append(rules, rule) }
+				got, _ := h.decidePodAction(tt.pod, time.Now().UTC().Add(tt.expiresIn), ttl, trace, explain)
+				if got != tt.want {
+					t.Errorf("decidePodAction(explain=%t) = %v, want %v", explain, got, tt.want)
+				}
+				if len(rules) == 0 {
+					t.Errorf("decidePodAction(explain=%t) traced no rules", explain)
+				}
+
+				// explaining a pod must not count as an error of the eviction loop
+				wantErrors := tt.wantErrors
+				if explain {
+					wantErrors = 0
+				}
+				if errors := h.summary.errors.Load(); errors != wantErrors {
+					t.Errorf("decidePodAction(explain=%t) counted %d errors, want %d", explain, errors, wantErrors)
+				}
+			})
+		}
+	}
+}
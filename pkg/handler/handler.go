@@ -14,13 +14,17 @@ package handler
 import (
 	"encoding/json"
 	"fmt"
-	"golang.org/x/exp/slices"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/adobe/k8s-shredder/pkg/audit"
+	"github.com/adobe/k8s-shredder/pkg/config"
+	"github.com/adobe/k8s-shredder/pkg/detection"
 	"github.com/adobe/k8s-shredder/pkg/metrics"
 	"github.com/adobe/k8s-shredder/pkg/utils"
 	"github.com/pkg/errors"
@@ -29,20 +33,80 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	policy "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	deploymentutil "k8s.io/kubectl/pkg/util/deployment"
-	"k8s.io/utils/ptr"
 )
 
 // Handler encapsulates the logic of the eviction loop
 type Handler struct {
 	appContext *utils.AppContext
 	logger     *log.Entry
+	// parkedNodes holds the names of the nodes found parked during the current eviction loop
+	parkedNodes map[string]bool
+	// nodesChanged is set when nodes were parked or unparked since the parked nodes were last listed, which the node
+	// informer cache may not reflect yet
+	nodesChanged atomic.Bool
+	// orderedEvictions holds the StatefulSets that already had a pod evicted during the current eviction loop
+	orderedEvictions *sync.Map
+	// skippedNamespaces caches, during the current eviction loop, whether each namespace opted out of eviction
+	skippedNamespaces *sync.Map
+	// workloadRefRollouts caches, during the current eviction loop, the Argo Rollouts of each namespace referencing a
+	// Deployment through their workloadRef, by Deployment name
+	workloadRefRollouts *sync.Map
+	// nextLoopAt is set when an eviction loop took longer than EvictionLoopInterval, delaying the next one
+	nextLoopAt time.Time
+	// blockedEvictions tracks, by pod UID, the pods whose eviction keeps being rejected with 429 Too Many Requests
+	blockedEvictions *sync.Map
+	// loopStart is the start time of the current eviction loop
+	loopStart time.Time
+	// runOnce makes the eviction loop run the detectors running on their own interval as well
+	runOnce bool
+	// role tells whether the handler parks the nodes found by the detectors, drains the parked nodes, or both
+	role Role
+	// rolloutRestarts tracks, by controller object fingerprint, when k8s-shredder performed a rollout restart, so that a
+	// GitOps tool reverting it can be detected during the next eviction loops
+	rolloutRestarts *sync.Map
+	// queuedRestarts tracks, by controller object fingerprint, when a controller object was processed by the rollout
+	// restart goroutines, so that it is not processed again within RolloutRestartDedupTTL
+	queuedRestarts *sync.Map
+	// revertedRestarts holds the fingerprints of the controller objects whose rollout restart was reverted
+	revertedRestarts *sync.Map
+	// lifecycles tracks, by node name, what happened to the parked nodes until they got drained
+	lifecycles *sync.Map
+	// evictedPods tracks, by pod UID, the pods evicted by k8s-shredder until their deletion is observed
+	evictedPods *sync.Map
+	// failedParkings tracks, by node name, the nodes that could not be parked until parking them again succeeds
+	failedParkings *sync.Map
+	// forceEvictions tracks, by node name, the expired parked nodes force evicted within ForceEvictionStaggerWindow
+	forceEvictions *sync.Map
+	// forceEvictionsMu makes checking and recording force evictions atomic across the node goroutines
+	forceEvictionsMu sync.Mutex
+	// status holds the state of the eviction loops, exposed through the HTTP API
+	status loopStatus
+	// summary tallies what happened during the current eviction loop
+	summary loopSummary
+	// upcoming tracks how many pods the parked nodes will force evict once they expire
+	upcoming upcomingEvictions
+	// createdAt is when the handler was created, before any eviction loop ran
+	createdAt time.Time
+	// expiryActions holds the available expiry actions, by name
+	expiryActions map[string]ExpiryAction
 }
 
+// blockedEviction holds the consecutive eviction attempts of a pod rejected with 429 Too Many Requests, usually
+// because of a PodDisruptionBudget not allowing any disruption
+type blockedEviction struct {
+	attempts int
+	lastSeen time.Time
+}
+
+// openKruiseGroup is the API group of the OpenKruise workloads
+const openKruiseGroup = "apps.kruise.io"
+
 type controllerObject struct {
 	Kind      string
 	Name      string
@@ -63,96 +127,384 @@ func (co *controllerObject) Fingerprint() string {
 	return fmt.Sprintf("%s/%s/%s", co.Kind, co.Namespace, co.Name)
 }
 
+// ScaleTargetKind returns the kind HorizontalPodAutoscalers use to target the controller object
+func (co *controllerObject) ScaleTargetKind() string {
+	if co.Kind == "AdvancedStatefulSet" {
+		return "StatefulSet"
+	}
+	return co.Kind
+}
+
 // NewHandler returns a new Handler for the given application context
 func NewHandler(appContext *utils.AppContext) *Handler {
 	logger := log.WithField("dryRun", appContext.IsDryRun())
-	return &Handler{appContext: appContext, logger: logger}
+	if appContext.Cluster != "" {
+		logger = logger.WithField("cluster", appContext.Cluster)
+	}
+	h := &Handler{
+		appContext:          appContext,
+		logger:              logger,
+		parkedNodes:         map[string]bool{},
+		orderedEvictions:    &sync.Map{},
+		skippedNamespaces:   &sync.Map{},
+		workloadRefRollouts: &sync.Map{},
+		blockedEvictions:    &sync.Map{},
+		rolloutRestarts:     &sync.Map{},
+		queuedRestarts:      &sync.Map{},
+		lifecycles:          &sync.Map{},
+		revertedRestarts:    &sync.Map{},
+		evictedPods:         &sync.Map{},
+		failedParkings:      &sync.Map{},
+		forceEvictions:      &sync.Map{},
+		createdAt:           time.Now(),
+	}
+	h.expiryActions = newExpiryActions(h)
+	h.role = RoleAll
+	h.upcoming.current = &sync.Map{}
+	return h
 }
 
 // Run starts an eviction loop
-func (h *Handler) Run() error {
+func (h *Handler) Run() (err error) {
+	if time.Now().Before(h.nextLoopAt) {
+		h.logger.Debugf("Skipping eviction loop, next one is delayed until %s", h.nextLoopAt.Format(time.RFC3339))
+		return nil
+	}
+	if pause := utils.PauseStatus(); pause.Paused {
+		h.logger.WithField("reason", pause.Reason).Warnf("=== k8s-shredder PAUSED since %s, skipping eviction loop ===", pause.Since.Format(time.RFC3339))
+		h.loopPaused()
+		return nil
+	}
+	loopStart := time.Now()
+	h.loopStart = loopStart
+	h.loopStarted(loopStart)
+	h.summary.reset()
+
 	// start measuring the loop duration
 	loopTimer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
-		metrics.ShredderLoopsDurationSeconds.Observe(v * 10e6)
+		metrics.ShredderLoopsDurationSeconds.WithLabelValues(h.appContext.Cluster).Observe(v * 10e6)
 	}))
 
-	// reset gauge metrics
-	metrics.ShredderNodeForceToEvictTime.Reset()
-	metrics.ShredderPodForceToEvictTime.Reset()
-	metrics.ShredderPodErrorsTotal.Reset()
+	// reset gauge metrics, series keyed by node are garbage collected at the end of the loop instead
+	metrics.ResetPodSeries(h.appContext.Cluster)
 
 	h.logger.Infof("Starting eviction loop")
 
 	// sync all nodes goroutines
 	wg := sync.WaitGroup{}
-	// rr channel is used to pass controller objects to be restarted by the rollout restart goroutine
-	rr := make(chan *controllerObject, 50)
-	// done and doneBack are used to signal rollout restart goroutine to finish its execution
-	done := make(chan bool)
-	doneBack := make(chan bool)
+	// rr channel is used to pass controller objects to be restarted by the rollout restart goroutines
+	rr := make(chan *controllerObject, h.appContext.Config().RolloutRestartQueueSize)
+	// rrWg is used to wait for the rollout restart goroutines to drain the rr channel
+	rrWg := sync.WaitGroup{}
+
+	// only expire node series when the parked nodes were successfully listed, otherwise all of them would look absent
+	nodesListed := false
 
 	defer func() {
 		wg.Wait()
-		done <- true
-		<-doneBack
+		h.adjustLoopInterval(time.Since(loopStart))
+		h.pruneBlockedEvictions(loopStart)
+		h.pruneRolloutRestarts()
+		h.pruneQueuedRestarts()
+		h.observeEvictedPods()
+		if nodesListed {
+			h.publishUpcomingEvictions()
+			h.pruneLifecycles()
+			expired := metrics.ExpireNodeSeries(h.appContext.Cluster)
+			h.logger.Debugf("Expired metrics for %d nodes no longer parked", expired)
+		}
 		close(rr)
-		close(done)
-		close(doneBack)
+		rrWg.Wait()
+		h.reportLoopSummary(time.Since(loopStart))
+		h.loopEnded(err)
+		h.logger.Debugf("See you next time!")
 	}()
 
-	// first start the rollout restart goroutine so that it is ready to receive controller objects to be restarted
-	go h.rolloutRestart(rr, done, doneBack)
+	// first start the rollout restart goroutines so that they are ready to receive controller objects to be restarted
+	for i := 0; i < h.appContext.Config().RolloutRestartConcurrency; i++ {
+		rrWg.Add(1)
+		go func() {
+			defer rrWg.Done()
+			h.rolloutRestart(rr)
+		}()
+	}
+
+	// park the nodes found by the enabled detectors first, so that they are processed during this loop as well
+	if h.role.Detects() {
+		h.runDetection()
+	}
 
 	nodeList, err := h.getParkedNodes()
 	if err != nil {
 		h.logger.Errorf("%s", err.Error())
-		metrics.ShredderErrorsTotal.Inc()
+		h.countError()
 		loopTimer.ObserveDuration()
 		return err
 	}
 
 	h.logger.Debugf("Found %d matching nodes (parked)", len(nodeList.Items))
+	nodesListed = true
+	h.observeBatches(nodeList.Items)
+	h.observeNodeStates(nodeList.Items)
+	h.observeParkedCapacity(nodeList.Items)
 
+	h.parkedNodes = make(map[string]bool, len(nodeList.Items))
 	for _, node := range nodeList.Items {
-		if utils.NodeHasTaint(node, h.appContext.Config.ToBeDeletedTaint) {
-			// skip nodes with "ToBeDeletedByClusterAutoscaler" taint
-			h.logger.Debugf("Skipping node %s with taint %s", node.Name, h.appContext.Config.ToBeDeletedTaint)
-			continue
-		}
+		h.parkedNodes[node.Name] = true
+		// the series of the parked nodes survive loops interrupted before processing them
+		metrics.ObserveNode(h.appContext.Cluster, node.Name)
+	}
+	h.orderedEvictions = &sync.Map{}
+	h.skippedNamespaces = &sync.Map{}
+	h.workloadRefRollouts = &sync.Map{}
 
-		// start a new goroutine for every parked node
+	// the parked nodes are processed by a pool of at most MaxConcurrentNodes goroutines
+	nodes := make(chan v1.Node)
+	for i := 0; i < min(h.appContext.Config().MaxConcurrentNodes, len(nodeList.Items)); i++ {
 		wg.Add(1)
-		go func(node v1.Node, wg *sync.WaitGroup) {
+		go func() {
 			defer wg.Done()
-			err := h.processNode(node, rr)
-			if err != nil {
-				h.logger.Errorf("%s", err.Error())
-				metrics.ShredderErrorsTotal.Inc()
+			for node := range nodes {
+				err := h.processNode(node, rr)
+				if err != nil {
+					h.logger.Errorf("%s", err.Error())
+					h.countError()
+				}
 			}
-		}(node, &wg)
-		metrics.ShredderProcessedNodesTotal.Inc()
+		}()
+	}
+
+	for _, node := range nodeList.Items {
+		if h.appContext.Stopping() {
+			// the application is shutting down, don't start processing any other node
+			h.logger.Warnf("Eviction loop interrupted, skipping remaining parked nodes")
+			break
+		}
+		if utils.PauseStatus().Paused {
+			h.logger.Warnf("k8s-shredder paused, skipping remaining parked nodes")
+			break
+		}
+
+		if utils.NodeHasTaint(node, h.appContext.Config().ToBeDeletedTaint) {
+			// skip nodes with "ToBeDeletedByClusterAutoscaler" taint
+			h.logger.Debugf("Skipping node %s with taint %s", node.Name, h.appContext.Config().ToBeDeletedTaint)
+			continue
+		}
+
+		if utils.NodeIsProtected(node, *h.appContext.Config()) {
+			h.logger.Warnf("Skipping protected node %s, it is parked but must never be drained", node.Name)
+			metrics.ShredderProtectedNodesSkippedTotal.WithLabelValues(h.appContext.Cluster).Inc()
+			continue
+		}
+
+		// wait for a free goroutine of the pool
+		nodes <- node
+		metrics.ShredderProcessedNodesTotal.WithLabelValues(h.appContext.Cluster).Inc()
+		h.summary.nodesProcessed.Add(1)
 	}
+	close(nodes)
 
-	metrics.ShredderLoopsTotal.Inc()
+	metrics.ShredderLoopsTotal.WithLabelValues(h.appContext.Cluster).Inc()
 	loopTimer.ObserveDuration()
 	return nil
 }
 
+// RunOnce runs every enabled detector, including the ones running on their own interval, along with a single eviction
+// loop. The number of errors which occurred is returned, along with an error when the eviction loop was skipped because
+// k8s-shredder is paused or the loop is delayed
+func (h *Handler) RunOnce() (int64, error) {
+	h.runOnce = true
+	if !h.role.Evicts() {
+		h.RunDetection()
+		if h.Status().LastEnd.IsZero() {
+			return 0, errors.New("no detection ran, k8s-shredder is paused")
+		}
+		return h.summary.errors.Load(), nil
+	}
+	if err := h.Run(); err != nil {
+		h.logger.Errorf("Eviction loop failed: %s", err.Error())
+	}
+	if h.loopStart.IsZero() {
+		if pause := utils.PauseStatus(); pause.Paused {
+			return 0, errors.Errorf("no eviction loop ran, k8s-shredder is paused since %s", pause.Since.Format(time.RFC3339))
+		}
+		return 0, errors.Errorf("no eviction loop ran, the next one is delayed until %s", h.nextLoopAt.Format(time.RFC3339))
+	}
+	return h.summary.errors.Load(), nil
+}
+
+// runDetectors runs all the enabled detectors and parks the nodes they find
+// Detectors running on their own interval are left to their scheduler job, unless running once
+func (h *Handler) runDetectors() {
+	for _, detector := range detection.EnabledDetectors(h.appContext) {
+		if detector.Interval(*h.appContext.Config()) > 0 && !h.runOnce {
+			continue
+		}
+		h.RunDetector(detector)
+	}
+}
+
+// RunDetector runs a detector and parks the nodes it finds, returning how many nodes were found
+func (h *Handler) RunDetector(detector detection.Detector) int {
+	logger := h.logger.WithField("detector", detector.Name())
+
+	if utils.PauseStatus().Paused {
+		logger.Debug("k8s-shredder paused, not running detector")
+		return 0
+	}
+
+	nodes, err := detector.Detect(h.appContext.Context)
+	if err != nil {
+		logger.Errorf("Failed to detect nodes to park: %s", err.Error())
+		metrics.ShredderDetectorRunsTotal.WithLabelValues(h.appContext.Cluster, detector.Name(), "error").Inc()
+		h.countError()
+		return 0
+	}
+
+	logger.Debugf("Detected %d nodes to park", len(nodes))
+	metrics.ShredderDetectedNodes.WithLabelValues(h.appContext.Cluster, detector.Name()).Set(float64(len(nodes)))
+
+	err = h.parkNodes(nodes, detector.Name())
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		h.queueFailedParkings(err, detector.Name())
+		metrics.ShredderDetectorRunsTotal.WithLabelValues(h.appContext.Cluster, detector.Name(), "error").Inc()
+		return len(nodes)
+	}
+	metrics.ShredderDetectorRunsTotal.WithLabelValues(h.appContext.Cluster, detector.Name(), "success").Inc()
+
+	if recoverer, ok := detector.(detection.Recoverer); ok && h.appContext.Config().UnparkRecoveredNodes {
+		h.unparkRecoveredNodes(recoverer, detector.Name(), logger)
+	}
+	return len(nodes)
+}
+
+// observeBatches counts the parked nodes of every parking batch
+func (h *Handler) observeBatches(nodes []v1.Node) {
+	batches := map[string]int{}
+	for _, node := range nodes {
+		if batch := node.Labels[h.appContext.Config().ParkingBatchLabel]; batch != "" {
+			batches[batch]++
+		}
+	}
+
+	metrics.ShredderBatchParkedNodes.DeletePartialMatch(prometheus.Labels{"cluster": h.appContext.Cluster})
+	for batch, count := range batches {
+		metrics.ShredderBatchParkedNodes.WithLabelValues(h.appContext.Cluster, batch).Set(float64(count))
+	}
+}
+
+// observeNodeStates counts the parked nodes in every state
+func (h *Handler) observeNodeStates(nodes []v1.Node) {
+	states := map[utils.NodeState]int{}
+	for _, node := range nodes {
+		states[utils.GetNodeState(node, *h.appContext.Config())]++
+	}
+
+	for _, state := range utils.NodeStates {
+		metrics.ShredderParkedNodesByState.WithLabelValues(h.appContext.Cluster, string(state)).Set(float64(states[state]))
+	}
+}
+
+// observeParkedCapacity sums the CPU and memory capacity and allocatable of the parked nodes
+func (h *Handler) observeParkedCapacity(nodes []v1.Node) {
+	var cpuCapacity, cpuAllocatable, memoryCapacity, memoryAllocatable float64
+	for _, node := range nodes {
+		cpuCapacity += node.Status.Capacity.Cpu().AsApproximateFloat64()
+		cpuAllocatable += node.Status.Allocatable.Cpu().AsApproximateFloat64()
+		memoryCapacity += node.Status.Capacity.Memory().AsApproximateFloat64()
+		memoryAllocatable += node.Status.Allocatable.Memory().AsApproximateFloat64()
+	}
+
+	cluster := h.appContext.Cluster
+	metrics.ShredderParkedCapacityCPUCores.WithLabelValues(cluster, "capacity").Set(cpuCapacity)
+	metrics.ShredderParkedCapacityCPUCores.WithLabelValues(cluster, "allocatable").Set(cpuAllocatable)
+	metrics.ShredderParkedCapacityMemoryBytes.WithLabelValues(cluster, "capacity").Set(memoryCapacity)
+	metrics.ShredderParkedCapacityMemoryBytes.WithLabelValues(cluster, "allocatable").Set(memoryAllocatable)
+}
+
+// unparkRecoveredNodes unparks the nodes parked by a detector which recovered since
+func (h *Handler) unparkRecoveredNodes(recoverer detection.Recoverer, source string, logger *log.Entry) {
+	nodes, err := recoverer.Recovered(h.appContext.Context)
+	if err != nil {
+		logger.Errorf("Failed to detect recovered nodes: %s", err.Error())
+		h.countError()
+		return
+	}
+
+	logger.Debugf("Detected %d recovered nodes to unpark", len(nodes))
+
+	unparked, err := utils.UnparkNodes(h.appContext, nodes, source)
+	if len(unparked) > 0 {
+		h.nodesChanged.Store(true)
+	}
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+	}
+}
+
+// adjustLoopInterval stretches the time until the next eviction loop, up to MaxEvictionLoopInterval, when the current one
+// took longer than EvictionLoopInterval, so that loops don't run back-to-back on overloaded clusters. A
+// MaxEvictionLoopInterval not greater than EvictionLoopInterval, like the default 0, disables the stretching
+func (h *Handler) adjustLoopInterval(loopDuration time.Duration) {
+	interval := h.appContext.Config().EvictionLoopInterval
+	maxInterval := h.appContext.Config().MaxEvictionLoopInterval
+
+	if loopDuration <= interval || maxInterval <= interval {
+		if !h.nextLoopAt.IsZero() {
+			h.logger.Infof("Eviction loop took %s, restoring the %s interval", loopDuration.String(), interval.String())
+			h.nextLoopAt = time.Time{}
+		}
+		metrics.ShredderLoopIntervalSeconds.WithLabelValues(h.appContext.Cluster).Set(interval.Seconds())
+		return
+	}
+
+	stretched := min(loopDuration, maxInterval)
+	h.nextLoopAt = time.Now().Add(stretched)
+	h.logger.Warnf("Eviction loop took %s, longer than the %s interval, delaying the next one by %s",
+		loopDuration.String(), interval.String(), stretched.String())
+	metrics.ShredderLoopIntervalSeconds.WithLabelValues(h.appContext.Cluster).Set(stretched.Seconds())
+	metrics.ShredderLoopIntervalAdjustmentsTotal.WithLabelValues(h.appContext.Cluster).Inc()
+}
+
 // processNode performs the eviction logic for a single node
 func (h *Handler) processNode(node v1.Node, rr chan *controllerObject) error {
 	h.logger.Infof("Processing node %s", node.Name)
 
-	if !utils.NodeHasLabel(node, h.appContext.Config.ExpiresOnLabel) {
-		return errors.Errorf("Node %s missing required label %s", node.Name, h.appContext.Config.ExpiresOnLabel)
+	if !utils.NodeHasLabel(node, h.appContext.Config().ExpiresOnLabel) {
+		return errors.Errorf("Node %s missing required label %s", node.Name, h.appContext.Config().ExpiresOnLabel)
 	}
 
-	expiresOn, err := utils.GetParkedNodeExpiryTime(node, h.appContext.Config.ExpiresOnLabel)
+	expiresOn, err := utils.GetParkedNodeExpiryTime(node, h.appContext.Config().ExpiresOnLabel)
 	if err != nil {
 		return err
 	}
 
+	ttl := h.appContext.Config().ParkedNodeTTLFor(node.Labels[h.appContext.Config().ParkingReasonLabel])
+
+	// nodes parked by older releases have no recorded state yet
+	if utils.GetNodeState(node, *h.appContext.Config()) == "" {
+		h.transitionNodeState(&node, utils.NodeStateParked)
+	}
+
+	if threshold := h.appContext.Config().NoExecuteEscalationThreshold; threshold > 0 {
+		// the TTL the node was parked with, which may differ from the configured one after a reload
+		parkedAt := h.parkedAt(node, expiresOn, ttl)
+		escalateAt := parkedAt.Add(time.Duration(float64(expiresOn.Sub(parkedAt)) * threshold))
+		if time.Now().UTC().After(escalateAt) && !utils.ParkedNodeTaintEscalated(node, *h.appContext.Config()) {
+			err := utils.RetryAPICall(h.appContext, func() error {
+				return utils.EscalateParkedNodeTaint(h.appContext, node.Name, h.logger.WithField("node", node.Name))
+			})
+			if err != nil {
+				h.logger.WithField("node", node.Name).Errorf("Failed to escalate the parked node taint: %s", err.Error())
+				h.countError()
+			}
+		}
+	}
+
 	h.logger.Debugf("Parked node %s expires on %s", node.Name, expiresOn.String())
-	metrics.ShredderNodeForceToEvictTime.WithLabelValues(node.Name).Set(float64(expiresOn.Unix()))
+	metrics.ShredderNodeForceToEvictTime.WithLabelValues(h.appContext.Cluster, node.Name).Set(float64(expiresOn.Unix()))
+	metrics.ShredderNodeParkedDurationSeconds.WithLabelValues(h.appContext.Cluster, node.Name).Set(time.Since(h.parkedAt(node, expiresOn, ttl)).Seconds())
 
 	deletePropagationBackground := metav1.DeletePropagationBackground
 	deleteOptions := &metav1.DeleteOptions{
@@ -163,62 +515,123 @@ func (h *Handler) processNode(node v1.Node, rr chan *controllerObject) error {
 		deleteOptions.DryRun = []string{metav1.DryRunAll}
 	}
 
-	podList, err := h.GetPodsForNode(node)
+	// the pods of the node are listed once, each of the helpers below filtering what it needs
+	nodePods, err := h.listNodePods(node)
 	if err != nil {
 		return err
 	}
 
+	if h.appContext.Config().EnableParkedPodLabels {
+		labeled, err := utils.ReconcileParkedPodLabels(h.appContext, nodePods, expiresOn, h.logger.WithField("node", node.Name))
+		if err != nil {
+			h.logger.WithField("node", node.Name).Warnf("Failed to reconcile the parking labels of the pods: %s", err.Error())
+			h.countError()
+		} else if labeled > 0 {
+			h.logger.WithField("node", node.Name).Infof("Updated the parking labels of %d pods missing them", labeled)
+		}
+	}
+
+	podList := h.eligiblePods(nodePods)
+
 	h.logger.Debugf("Found %d eligible for evict pods on parked node %s", len(podList), node.Name)
+	h.recordUpcomingEviction(node.Name, expiresOn, len(podList))
+	h.recordDrainProgress(node.Name, len(podList))
 
-	if time.Now().UTC().After(expiresOn) {
-		h.logger.Infof("Force evicting pods from expired parked node %s", node.Name)
+	expired := time.Now().UTC().After(expiresOn)
+	// drained nodes stay cleared when expiring
+	if state := utils.GetNodeState(node, *h.appContext.Config()); expired && (state == utils.NodeStateParked || state == utils.NodeStateDraining) {
+		h.transitionNodeState(&node, utils.NodeStateExpired)
+	}
 
-		deleteOptions.GracePeriodSeconds = ptr.To[int64](0)
+	var expiryAction ExpiryAction
+	if expired {
+		expiryAction = h.expiryActionFor(node)
+	}
 
-		for _, pod := range podList {
-			err = h.deletePod(pod, deleteOptions)
-			if err != nil {
-				h.logger.WithFields(log.Fields{
-					"namespace": pod.Namespace,
-					"pod":       pod.Name,
-				}).Warnf("Failed to delete pod: %s", err.Error())
-				continue
-			}
-			metrics.ShredderProcessedPodsTotal.Inc()
-		}
+	gracePeriod := time.Duration(0)
+	if _, forceDelete := expiryAction.(*forceDeleteAction); forceDelete && len(h.appContext.Config().ForceEvictionTiers) > 0 {
+		gracePeriod = h.reachForceEvictionTier(node, expiresOn)
 
-		return nil
+		// pods terminating with a longer grace period are deleted again, shortening it
+		podList = append(podList, h.getLingeringPods(nodePods, gracePeriod)...)
 	}
 
-	for _, pod := range podList {
-		metrics.ShredderPodForceToEvictTime.WithLabelValues(pod.Name, pod.Namespace).Set(float64(expiresOn.Unix()))
+	if _, forceDelete := expiryAction.(*forceDeleteAction); forceDelete && h.appContext.Config().ForceEvictDaemonSetPods {
+		podList = append(podList, h.getExpiredDaemonSetPods(nodePods, expiresOn)...)
+	}
 
-		if !utils.PodEvictionAllowed(pod, h.appContext.Config.AllowEvictionLabel) {
-			h.logger.Debugf("Skipping %s as it has '%s=false' label set", pod.Name, h.appContext.Config.AllowEvictionLabel)
-			continue
+	if len(podList) == 0 {
+		// the node was already reported, possibly before a restart of k8s-shredder
+		if utils.GetNodeState(node, *h.appContext.Config()) == utils.NodeStateCleared {
+			return nil
 		}
+		h.transitionNodeState(&node, utils.NodeStateCleared)
+		h.reportDrainedNode(node, expiresOn, ttl)
+		return nil
+	}
 
-		if h.appContext.Config.NamespacePrefixSkipInitialEviction == "" || !strings.HasPrefix(pod.Namespace, h.appContext.Config.NamespacePrefixSkipInitialEviction) {
-			rrThresholdTime := h.appContext.Config.ParkedNodeTTL * time.Duration(100-h.appContext.Config.RollingRestartThreshold*100) / 100
-			if time.Now().UTC().Before(expiresOn.Add(-rrThresholdTime)) {
-				err := h.evictPod(pod, deleteOptions)
-				if err != nil {
-					h.logger.WithFields(log.Fields{
-						"namespace": pod.Namespace,
-						"pod":       pod.Name,
-					}).Warnf("Failed to evict pod: %s", err.Error())
+	// pods with a lower eviction cost go first
+	utils.SortPodsByEvictionCost(podList, h.appContext.Config().EvictionCostAnnotation)
+
+	if expired {
+		if utils.PauseStatus().Paused {
+			h.logger.WithField("node", node.Name).Warn("k8s-shredder paused, not expiring node")
+			return nil
+		}
+		if h.appContext.Config().DeferJobEvictions {
+			reason, err := h.deferJobExpiry(podList, expiresOn)
+			if err != nil {
+				return errors.Wrap(err, "Failed to check the Jobs of the node")
+			}
+			if reason != "" {
+				h.logger.WithField("node", node.Name).Infof("Not expiring node yet, %s", reason)
+				metrics.ShredderJobEvictionsDeferredTotal.WithLabelValues(h.appContext.Cluster, "expiry").Inc()
+				return nil
+			}
+		}
+		if _, forceDelete := expiryAction.(*forceDeleteAction); forceDelete && h.appContext.Config().EvictionSafetyCheck {
+			for _, pod := range podList {
+				action, _ := h.decidePodAction(pod, expiresOn, ttl, noTrace, false)
+				if action == podActionSkip {
+					// the labels of the pod could not be checked, try again during the next loop
+					return nil
 				}
-				continue
+				if action == podActionRepark {
+					return h.reparkNode(node, pod, ttl)
+				}
+			}
+		}
+		// only force deletions take the pods out, the other expiry actions don't need to be staggered
+		if _, forceDelete := expiryAction.(*forceDeleteAction); forceDelete {
+			if blocker, ok := h.claimForceEviction(node, podList); !ok {
+				h.logger.WithField("node", node.Name).Infof("Not expiring node yet, staggering it after node %s within ForceEvictionStaggerWindow=%s",
+					blocker, h.appContext.Config().ForceEvictionStaggerWindow.String())
+				return nil
 			}
 		}
+		return expiryAction.Expire(node, podList, gracePeriod)
+	}
 
-		co, err := h.getControllerObject(pod)
-		if err != nil {
-			h.logger.WithFields(log.Fields{
-				"namespace": pod.Namespace,
-				"pod":       pod.Name,
-			}).Warnf("Failed to get pod controller object: %s. Proceeding directly with pod eviction", err.Error())
-			err := h.evictPod(pod, deleteOptions)
+	h.transitionNodeState(&node, utils.NodeStateDraining)
+	h.annotatePodDeadlines(podList, expiresOn)
+
+	for _, pod := range podList {
+		if h.appContext.Stopping() {
+			return errors.Errorf("Stopped processing node %s, shutting down", node.Name)
+		}
+		if utils.PauseStatus().Paused {
+			h.logger.WithField("node", node.Name).Warn("k8s-shredder paused, stopped processing node")
+			return nil
+		}
+
+		metrics.ShredderPodForceToEvictTime.WithLabelValues(h.appContext.Cluster, pod.Name, pod.Namespace).Set(float64(expiresOn.Unix()))
+
+		action, co := h.decidePodAction(pod, expiresOn, ttl, noTrace, false)
+		switch action {
+		case podActionSkip:
+			continue
+		case podActionEvict:
+			err := h.evictPodInOrder(pod, expiresOn, deleteOptions)
 			if err != nil {
 				h.logger.WithFields(log.Fields{
 					"namespace": pod.Namespace,
@@ -226,19 +639,17 @@ func (h *Handler) processNode(node v1.Node, rr chan *controllerObject) error {
 				}).Warnf("Failed to evict pod: %s", err.Error())
 			}
 			continue
-		}
-
-		// For pods handled by a deployment, statefulset or argo rollouts controller, try to rollout restart those objects
-		if slices.Contains([]string{"Deployment", "StatefulSet", "Rollout"}, co.Kind) {
-			rolloutRestartInProgress, err := h.isRolloutRestartInProgress(co)
+		case podActionLiveMigrate:
+			migrated, err := h.liveMigrateVMI(pod, virtLauncherVMI(pod))
 			if err != nil {
-				h.logger.WithField("key", co.Fingerprint()).Warnf("Failed to get rollout status: %s", err.Error())
-				metrics.ShredderErrorsTotal.Inc()
+				h.logger.WithFields(log.Fields{
+					"namespace": pod.Namespace,
+					"pod":       pod.Name,
+				}).Warnf("Failed to live migrate VirtualMachineInstance: %s", err.Error())
 				continue
 			}
-			// if the rollout restart process is in progress, evict the pod instead of trying to do another rollout restart
-			if rolloutRestartInProgress {
-				err := h.evictPod(pod, deleteOptions)
+			if !migrated {
+				err := h.evictPodInOrder(pod, expiresOn, deleteOptions)
 				if err != nil {
 					h.logger.WithFields(log.Fields{
 						"namespace": pod.Namespace,
@@ -247,102 +658,402 @@ func (h *Handler) processNode(node v1.Node, rr chan *controllerObject) error {
 				}
 				continue
 			}
-
-			// If there isn't any rollout in progress, send the controller object into the rollout restart channel in order to
-			// be processed by the rolloutRestart goroutine
+		case podActionRolloutRestart:
+			// Send the controller object into the rollout restart channel in order to be processed by the rolloutRestart goroutines
+			h.recordLifecycle(node.Name, func(l *nodeLifecycle) { l.restartedControllers[co.Fingerprint()] = true })
+			metrics.ShredderPendingRolloutRestarts.WithLabelValues(h.appContext.Cluster).Inc()
 			rr <- co
 		}
-		metrics.ShredderProcessedPodsTotal.Inc()
+		metrics.ShredderProcessedPodsTotal.WithLabelValues(h.appContext.Cluster).Inc()
 	}
 
 	return nil
 }
 
-// getParkedNodes queries the APIServer for a list of nodes that have the parked label set
+// reparkNode parks an expired node again, resetting its TTL, because pod was not given notice of its eviction with the
+// parking labels
+func (h *Handler) reparkNode(node v1.Node, pod v1.Pod, ttl time.Duration) error {
+	logger := h.logger.WithField("node", node.Name)
+
+	expiresOn, err := utils.ReparkNode(h.appContext, node.Name, ttl, logger)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to park node %s again", node.Name)
+	}
+
+	message := fmt.Sprintf("Pod %s/%s is missing the parking labels, not force deleting the pods of the node, parked again until %s",
+		pod.Namespace, pod.Name, expiresOn.Format(time.RFC3339))
+	logger.Warn(message)
+	h.appContext.RecordEvent(&node, v1.EventTypeWarning, "ParkedNodeReparked", message)
+	return nil
+}
+
+// transitionNodeState moves a parked node to a new state, keeping the node object in sync for the next transitions
+func (h *Handler) transitionNodeState(node *v1.Node, to utils.NodeState) {
+	logger := h.logger.WithField("node", node.Name)
+	if err := utils.TransitionNodeState(h.appContext, *node, to, logger); err != nil {
+		// the state is only recorded, the node keeps being processed
+		logger.Warnf("%s", err.Error())
+		return
+	}
+
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+	node.Annotations[h.appContext.Config().NodeStateAnnotation] = string(to)
+}
+
+// reachForceEvictionTier returns the grace period of the force eviction tier reached by an expired parked node,
+// recording the tier in the ForceEvictionTierAnnotation of the node when it changed
+func (h *Handler) reachForceEvictionTier(node v1.Node, expiresOn time.Time) time.Duration {
+	cfg := *h.appContext.Config()
+	tier, gracePeriod := cfg.ForceEvictionTierAt(time.Since(expiresOn))
+
+	value := strconv.Itoa(tier)
+	if node.Annotations[cfg.ForceEvictionTierAnnotation] == value {
+		return gracePeriod
+	}
+
+	message := fmt.Sprintf("Force eviction reached tier %d, pods get a %s grace period", tier, gracePeriod.String())
+	h.logger.WithField("node", node.Name).Info(message)
+	h.appContext.RecordEvent(&node, v1.EventTypeNormal, "ForceEvictionTier", message)
+
+	if h.appContext.IsDryRun() {
+		return gracePeriod
+	}
+
+	patchData, _ := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				cfg.ForceEvictionTierAnnotation: value,
+			},
+		},
+	})
+	err := utils.RetryAPICall(h.appContext, func() error {
+		_, err := h.appContext.K8sClient.CoreV1().Nodes().Patch(h.appContext.Context, node.Name, types.MergePatchType, patchData, metav1.PatchOptions{FieldManager: "k8s-shredder"})
+		return err
+	})
+	if err != nil {
+		// the annotation only records the tier, the grace period still applies
+		h.logger.WithField("node", node.Name).Warnf("Failed to annotate node with %s: %s", cfg.ForceEvictionTierAnnotation, err.Error())
+	}
+
+	return gracePeriod
+}
+
+// getLingeringPods returns the pods of a node terminating with a grace period longer than gracePeriod
+func (h *Handler) getLingeringPods(pods []v1.Pod, gracePeriod time.Duration) []v1.Pod {
+	var lingeringPods []v1.Pod
+	for _, pod := range pods {
+		if pod.DeletionTimestamp == nil || utils.PodExclusionReason(pod, *h.appContext.Config()) != "" {
+			continue
+		}
+		if pod.DeletionGracePeriodSeconds != nil && *pod.DeletionGracePeriodSeconds > int64(gracePeriod/time.Second) {
+			lingeringPods = append(lingeringPods, pod)
+		}
+	}
+
+	return lingeringPods
+}
+
+// getExpiredDaemonSetPods returns the DaemonSet pods of an expired parked node which were created before it expired. The
+// pods recreated by their DaemonSet afterward are left alone, so that they are deleted once
+func (h *Handler) getExpiredDaemonSetPods(pods []v1.Pod, expiresOn time.Time) []v1.Pod {
+	var daemonSetPods []v1.Pod
+	for _, pod := range pods {
+		if !utils.PodIsDaemonSet(pod) || pod.DeletionTimestamp != nil || !pod.CreationTimestamp.Time.Before(expiresOn) {
+			continue
+		}
+		if reason := utils.PodConfiguredExclusionReason(pod, *h.appContext.Config()); reason != "" {
+			h.logger.Debugf("Skipping DaemonSet pod %s as %s", pod.Name, reason)
+			continue
+		}
+		daemonSetPods = append(daemonSetPods, pod)
+	}
+
+	return daemonSetPods
+}
+
+// parkNodes parks nodes on behalf of source, making sure the next eviction loop sees them
+func (h *Handler) parkNodes(nodes []utils.NodeInfo, source string) error {
+	if len(nodes) > 0 {
+		h.nodesChanged.Store(true)
+	}
+	return utils.ParkNodes(h.appContext, nodes, source)
+}
+
+// getParkedNodes queries the APIServer for a list of nodes that have the parked label set. The node informer cache is
+// bypassed when nodes were parked or unparked since the last listing, so that the eviction loop parking them processes
+// them as well
 func (h *Handler) getParkedNodes() (*v1.NodeList, error) {
 	labelSelector := metav1.LabelSelector{
 		MatchLabels: map[string]string{
-			h.appContext.Config.UpgradeStatusLabel: "parked",
+			h.appContext.Config().UpgradeStatusLabel: h.appContext.Config().UpgradeStatusParkedValue,
 		},
 	}
 
-	nodeList, err := h.appContext.K8sClient.CoreV1().Nodes().List(h.appContext.Context, metav1.ListOptions{
+	opts := metav1.ListOptions{
 		LabelSelector: labels.Set(labelSelector.MatchLabels).String(),
-	})
+	}
+	var nodes []v1.Node
+	var err error
+	if h.nodesChanged.Swap(false) {
+		nodes, err = utils.ListNodes(h.appContext.Context, h.appContext.K8sClient, h.appContext.Config().APIListPageSize, opts)
+	} else {
+		nodes, err = h.appContext.ListNodes(h.appContext.Context, h.appContext.K8sClient, opts)
+	}
 
 	if err != nil {
 		return nil, err
 	}
 
-	return nodeList, nil
+	return &v1.NodeList{Items: nodes}, nil
 }
 
 // GetPodsForNode returns all eligible for evict pods from a specific node
 func (h *Handler) GetPodsForNode(node v1.Node) ([]v1.Pod, error) {
-	podList, err := h.appContext.K8sClient.CoreV1().Pods("").List(h.appContext.Context, metav1.ListOptions{
-		FieldSelector: fmt.Sprintf("spec.nodeName=%s", node.Name),
-	})
-
+	pods, err := h.listNodePods(node)
 	if err != nil {
 		return nil, err
 	}
+	return h.eligiblePods(pods), nil
+}
+
+// listNodePods lists all the pods of a node
+func (h *Handler) listNodePods(node v1.Node) ([]v1.Pod, error) {
+	return utils.ListPods(h.appContext.Context, h.appContext.K8sClient, "", h.appContext.Config().APIListPageSize, metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("spec.nodeName=%s", node.Name),
+	})
+}
 
+// eligiblePods filters the pods of a node down to the ones eligible for eviction
+func (h *Handler) eligiblePods(pods []v1.Pod) []v1.Pod {
 	var podListCleaned []v1.Pod
 
 	// we need to remove any non-eligible pods
-	for _, pod := range podList.Items {
+	for _, pod := range pods {
 		// skip pods in terminating state
 		if pod.DeletionTimestamp != nil {
 			h.logger.Debugf("Skipping %s as it is in terminating state", pod.Name)
 			continue
 		}
 
-		// skip pods with DaemonSet controller object or static pods
-		if len(pod.OwnerReferences) > 0 && slices.Contains([]string{"DaemonSet", "Node"}, pod.ObjectMeta.OwnerReferences[0].Kind) {
-			h.logger.Debugf("Skipping %s as it is part of a DaemonSet or is a static pod", pod.Name)
+		// skip DaemonSet and static pods, as well as the pods excluded by the configuration
+		if reason := utils.PodExclusionReason(pod, *h.appContext.Config()); reason != "" {
+			h.logger.Debugf("Skipping %s as %s", pod.Name, reason)
 			continue
 		}
 
 		podListCleaned = append(podListCleaned, pod)
 	}
 
-	return podListCleaned, nil
+	return podListCleaned
 }
 
 // evictPod evict a pod using the eviction API
 func (h *Handler) evictPod(pod v1.Pod, deleteOptions *metav1.DeleteOptions) error {
 	h.logger.Infof("Evicting pod %s from %s namespace", pod.Name, pod.Namespace)
-	err := h.appContext.K8sClient.PolicyV1().Evictions(pod.Namespace).Evict(h.appContext.Context, &policy.Eviction{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      pod.Name,
-			Namespace: pod.Namespace,
-		},
-		DeleteOptions: deleteOptions,
+	start := time.Now()
+	// a 429 means the eviction is blocked by a PodDisruptionBudget, which is handled by the following eviction loops
+	err := utils.RetryAPICallOn(h.appContext, func(err error) bool {
+		return !apierrors.IsTooManyRequests(err) && utils.IsTransientAPIError(err)
+	}, func() error {
+		return h.appContext.K8sClient.PolicyV1().Evictions(pod.Namespace).Evict(h.appContext.Context, &policy.Eviction{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      pod.Name,
+				Namespace: pod.Namespace,
+			},
+			DeleteOptions: deleteOptions,
+		})
 	})
+	h.auditPod(audit.ActionEvict, pod, start, err)
+
+	if err == nil {
+		h.recordLifecycle(pod.Spec.NodeName, func(l *nodeLifecycle) { l.evictedPods++ })
+		h.trackEvictedPod(pod)
+		h.summary.podsEvicted.Add(1)
+	}
 
 	if err != nil {
-		metrics.ShredderPodErrorsTotal.WithLabelValues(pod.Name, pod.Namespace, err.Error(), "evict")
+		metrics.ShredderPodErrorsTotal.WithLabelValues(h.appContext.Cluster, pod.Name, pod.Namespace, err.Error(), "evict")
 		return err
 	}
 
 	return nil
 }
 
+// evictPodInOrder evicts a pod, unless it belongs to a StatefulSet that opted in for ordered eviction and it is not
+// its turn yet. Pods of such StatefulSets are evicted one per eviction loop, in reverse ordinal order, and only after
+// all the StatefulSet replicas are ready again
+func (h *Handler) evictPodInOrder(pod v1.Pod, expiresOn time.Time, deleteOptions *metav1.DeleteOptions) error {
+	if len(pod.OwnerReferences) == 0 || pod.OwnerReferences[0].Kind != "StatefulSet" {
+		return h.evictPodOrDelete(pod, expiresOn, deleteOptions)
+	}
+
+	sts, err := h.appContext.K8sClient.AppsV1().StatefulSets(pod.Namespace).Get(h.appContext.Context, pod.OwnerReferences[0].Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if sts.Annotations[h.appContext.Config().OrderedEvictionAnnotation] != "true" {
+		return h.evictPodOrDelete(pod, expiresOn, deleteOptions)
+	}
+
+	next, reason, err := h.isNextOrderedEviction(sts, pod)
+	if err != nil {
+		return err
+	}
+	if !next {
+		h.logger.WithFields(log.Fields{
+			"namespace":   pod.Namespace,
+			"pod":         pod.Name,
+			"statefulset": sts.Name,
+		}).Debugf("Deferring ordered eviction: %s", reason)
+		return nil
+	}
+
+	return h.evictPodOrDelete(pod, expiresOn, deleteOptions)
+}
+
+// isNextOrderedEviction checks whether a pod is the next one to be evicted from its StatefulSet
+func (h *Handler) isNextOrderedEviction(sts *appsv1.StatefulSet, pod v1.Pod) (bool, string, error) {
+	if sts.Spec.Replicas != nil && sts.Status.ReadyReplicas < *sts.Spec.Replicas {
+		return false, fmt.Sprintf("waiting for replicas to become ready (%d/%d)", sts.Status.ReadyReplicas, *sts.Spec.Replicas), nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(sts.Spec.Selector)
+	if err != nil {
+		return false, "", err
+	}
+
+	pods, err := utils.ListPods(h.appContext.Context, h.appContext.K8sClient, sts.Namespace, h.appContext.Config().APIListPageSize, metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		return false, "", err
+	}
+
+	// the next pod to evict is the one with the highest ordinal among the pods still running on parked nodes
+	next, nextOrdinal := "", -1
+	for _, p := range pods {
+		if p.DeletionTimestamp != nil {
+			return false, fmt.Sprintf("pod %s is terminating", p.Name), nil
+		}
+		if !h.parkedNodes[p.Spec.NodeName] {
+			continue
+		}
+		ordinal, err := utils.GetStatefulSetPodOrdinal(p, sts.Name)
+		if err != nil {
+			h.logger.Warnf("%s", err.Error())
+			continue
+		}
+		if ordinal > nextOrdinal {
+			next, nextOrdinal = p.Name, ordinal
+		}
+	}
+
+	if next != pod.Name {
+		return false, fmt.Sprintf("pod %s has to be evicted first", next), nil
+	}
+
+	if _, evicted := h.orderedEvictions.LoadOrStore(fmt.Sprintf("%s/%s", sts.Namespace, sts.Name), true); evicted {
+		return false, "another pod was already evicted during this eviction loop", nil
+	}
+
+	return true, "", nil
+}
+
+// evictPodOrDelete evicts a pod and, when enabled, falls back to deleting it with its own grace period once its
+// eviction was rejected with 429 Too Many Requests EvictionDeleteFallbackRetries times in a row and the parked node
+// TTL expires within EvictionDeleteFallbackBeforeExpiry
+func (h *Handler) evictPodOrDelete(pod v1.Pod, expiresOn time.Time, deleteOptions *metav1.DeleteOptions) error {
+	err := h.evictPod(pod, deleteOptions)
+	if err == nil || !apierrors.IsTooManyRequests(err) {
+		h.blockedEvictions.Delete(pod.UID)
+		return err
+	}
+
+	h.recordLifecycle(pod.Spec.NodeName, func(l *nodeLifecycle) { l.pdbConflicts++ })
+
+	value, _ := h.blockedEvictions.LoadOrStore(pod.UID, &blockedEviction{})
+	blocked := value.(*blockedEviction)
+	blocked.attempts++
+	blocked.lastSeen = time.Now()
+	metrics.ShredderPodBlockedEvictions.WithLabelValues(h.appContext.Cluster, pod.Name, pod.Namespace).Set(float64(blocked.attempts))
+
+	cfg := *h.appContext.Config()
+	if !cfg.EvictionDeleteFallback || blocked.attempts < cfg.EvictionDeleteFallbackRetries ||
+		time.Now().UTC().Before(expiresOn.Add(-cfg.EvictionDeleteFallbackBeforeExpiry)) {
+		return err
+	}
+
+	message := fmt.Sprintf("Eviction was rejected %d times in a row, deleting the pod before its parked node TTL expires on %s",
+		blocked.attempts, expiresOn.Format(time.RFC3339))
+	h.logger.WithFields(log.Fields{
+		"namespace": pod.Namespace,
+		"pod":       pod.Name,
+	}).Warn(message)
+	h.appContext.RecordEvent(&pod, v1.EventTypeWarning, "EvictionEscalatedToDelete", message)
+
+	// unlike force eviction, the pod keeps its own termination grace period
+	err = h.deletePod(pod, &metav1.DeleteOptions{
+		PropagationPolicy: deleteOptions.PropagationPolicy,
+		DryRun:            deleteOptions.DryRun,
+	})
+	if err != nil {
+		return err
+	}
+
+	h.blockedEvictions.Delete(pod.UID)
+	metrics.ShredderEvictionDeleteFallbacksTotal.WithLabelValues(h.appContext.Cluster).Inc()
+	return nil
+}
+
+// pruneBlockedEvictions forgets the pods that were not evicted during the last loop, as their attempts are no longer consecutive
+func (h *Handler) pruneBlockedEvictions(loopStart time.Time) {
+	h.blockedEvictions.Range(func(key, value any) bool {
+		if value.(*blockedEviction).lastSeen.Before(loopStart) {
+			h.blockedEvictions.Delete(key)
+		}
+		return true
+	})
+}
+
 // deletePod deletes a pod using the delete options
 func (h *Handler) deletePod(pod v1.Pod, deleteOptions *metav1.DeleteOptions) error {
 	coreClient := h.appContext.K8sClient.CoreV1()
 
 	h.logger.Infof("Deleting pod %s from %s namespace", pod.Name, pod.Namespace)
-	err := coreClient.Pods(pod.Namespace).Delete(h.appContext.Context, pod.Name, *deleteOptions)
+	start := time.Now()
+	err := utils.RetryAPICall(h.appContext, func() error {
+		return coreClient.Pods(pod.Namespace).Delete(h.appContext.Context, pod.Name, *deleteOptions)
+	})
+	h.auditPod(audit.ActionDelete, pod, start, err)
+
+	if err == nil {
+		h.recordLifecycle(pod.Spec.NodeName, func(l *nodeLifecycle) { l.deletedPods++ })
+		h.summary.podsForceDeleted.Add(1)
+	}
 
 	if err != nil {
-		metrics.ShredderPodErrorsTotal.WithLabelValues(pod.Name, pod.Namespace, err.Error(), "delete")
+		metrics.ShredderPodErrorsTotal.WithLabelValues(h.appContext.Cluster, pod.Name, pod.Namespace, err.Error(), "delete")
 		return err
 	}
 
 	return nil
 }
 
+// auditPod records a mutating API call targeting a pod in the audit log
+func (h *Handler) auditPod(action string, pod v1.Pod, start time.Time, err error) {
+	audit.Record(audit.Entry{
+		Action:    action,
+		Kind:      "Pod",
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+		Node:      pod.Spec.NodeName,
+		DryRun:    h.appContext.IsDryRun(),
+	}, start, err)
+}
+
 func (h *Handler) getControllerObject(pod v1.Pod) (*controllerObject, error) {
 	co := newControllerObject("Unknown", "", "", nil)
 
@@ -359,7 +1070,7 @@ func (h *Handler) getControllerObject(pod v1.Pod) (*controllerObject, error) {
 		}
 		co = newControllerObject("ReplicaSet", replicaSet.Name, replicaSet.Namespace, replicaSet)
 		if len(replicaSet.OwnerReferences) == 0 {
-			h.logger.Warnf("Pod %s is controlled by an isolated ReplicaSet", pod.Name)
+			h.logger.Debugf("Pod %s is controlled by an isolated ReplicaSet", pod.Name)
 			return co, nil
 		}
 
@@ -370,18 +1081,21 @@ func (h *Handler) getControllerObject(pod v1.Pod) (*controllerObject, error) {
 			if err != nil {
 				return co, err
 			}
+
+			// an Argo Rollout referencing the Deployment through its workloadRef manages the pods, restarting the
+			// Deployment would be reverted by the Rollout
+			rollout, err := h.getWorkloadRefRollout(deployment.Namespace, deployment.Name)
+			if err != nil {
+				return co, err
+			}
+			if rollout != nil {
+				return newControllerObject("Rollout", rollout.GetName(), rollout.GetNamespace(), rollout), nil
+			}
 			return newControllerObject("Deployment", deployment.Name, deployment.Namespace, deployment), nil
 		case "Rollout":
 			// Make sure we are dealing with an Argo Rollout
-			if replicaSet.OwnerReferences[0].APIVersion == fmt.Sprintf("argoproj.io/%s", h.appContext.Config.ArgoRolloutsAPIVersion) {
-
-				gvr := schema.GroupVersionResource{
-					Group:    "argoproj.io",
-					Version:  h.appContext.Config.ArgoRolloutsAPIVersion,
-					Resource: "rollouts",
-				}
-
-				rollout, err := h.appContext.DynamicK8SClient.Resource(gvr).Namespace(pod.Namespace).Get(h.appContext.Context, replicaSet.OwnerReferences[0].Name, metav1.GetOptions{})
+			if replicaSet.OwnerReferences[0].APIVersion == fmt.Sprintf("argoproj.io/%s", h.appContext.Config().ArgoRolloutsAPIVersion) {
+				rollout, err := h.appContext.DynamicK8SClient.Resource(h.argoRolloutsResource()).Namespace(pod.Namespace).Get(h.appContext.Context, replicaSet.OwnerReferences[0].Name, metav1.GetOptions{})
 				if err != nil {
 					return co, err
 				}
@@ -393,6 +1107,13 @@ func (h *Handler) getControllerObject(pod v1.Pod) (*controllerObject, error) {
 			return co, errors.Errorf("Controller object of type %s from %s API group is not supported! Please file a git issue or contribute it!", pod.OwnerReferences[0].Kind, pod.OwnerReferences[0].APIVersion)
 		}
 
+	case "ReplicationController":
+		rc, err := h.appContext.K8sClient.CoreV1().ReplicationControllers(pod.Namespace).Get(h.appContext.Context, pod.OwnerReferences[0].Name, metav1.GetOptions{})
+		if err != nil {
+			return co, err
+		}
+		return newControllerObject("ReplicationController", rc.Name, rc.Namespace, rc), nil
+
 	case "DaemonSet":
 		h.logger.Warnf("DaemonSets are not covered")
 		return newControllerObject("DaemonSet", "", "", nil), nil
@@ -402,16 +1123,108 @@ func (h *Handler) getControllerObject(pod v1.Pod) (*controllerObject, error) {
 		return newControllerObject("StaticPod", "", "", nil), nil
 
 	case "StatefulSet":
+		// OpenKruise Advanced StatefulSets share the kind of the native ones
+		if strings.HasPrefix(pod.OwnerReferences[0].APIVersion, openKruiseGroup+"/") {
+			return h.getOpenKruiseControllerObject("AdvancedStatefulSet", "statefulsets", pod)
+		}
 		sts, err := h.appContext.K8sClient.AppsV1().StatefulSets(pod.Namespace).Get(h.appContext.Context, pod.OwnerReferences[0].Name, metav1.GetOptions{})
 		if err != nil {
 			return co, err
 		}
 		return newControllerObject("StatefulSet", sts.Name, sts.Namespace, sts), nil
+	case "CloneSet":
+		if !strings.HasPrefix(pod.OwnerReferences[0].APIVersion, openKruiseGroup+"/") {
+			return co, errors.Errorf("Controller object of type %s from %s API group is not supported! Please file a git issue or contribute it!", pod.OwnerReferences[0].Kind, pod.OwnerReferences[0].APIVersion)
+		}
+		return h.getOpenKruiseControllerObject("CloneSet", "clonesets", pod)
 	default:
 		return co, errors.Errorf("Controller object of type %s is not a standard controller", pod.OwnerReferences[0].Kind)
 	}
 }
 
+// argoRolloutsResource returns the Argo Rollouts resource in ArgoRolloutsAPIVersion
+func (h *Handler) argoRolloutsResource() schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    "argoproj.io",
+		Version:  h.appContext.Config().ArgoRolloutsAPIVersion,
+		Resource: "rollouts",
+	}
+}
+
+// getWorkloadRefRollout returns the Argo Rollout referencing a Deployment through its workloadRef, or nil when there is
+// none or Argo Rollouts is not installed. The Rollouts of each namespace are listed once per eviction loop
+func (h *Handler) getWorkloadRefRollout(namespace, deployment string) (*unstructured.Unstructured, error) {
+	cached, found := h.workloadRefRollouts.Load(namespace)
+	if !found {
+		rollouts := map[string]*unstructured.Unstructured{}
+		list, err := h.appContext.DynamicK8SClient.Resource(h.argoRolloutsResource()).Namespace(namespace).List(h.appContext.Context, metav1.ListOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		if err == nil {
+			for i := range list.Items {
+				kind, _, _ := unstructured.NestedString(list.Items[i].Object, "spec", "workloadRef", "kind")
+				name, _, _ := unstructured.NestedString(list.Items[i].Object, "spec", "workloadRef", "name")
+				if kind == "Deployment" && name != "" {
+					rollouts[name] = &list.Items[i]
+				}
+			}
+		}
+		cached, _ = h.workloadRefRollouts.LoadOrStore(namespace, rollouts)
+	}
+	return cached.(map[string]*unstructured.Unstructured)[deployment], nil
+}
+
+// getOpenKruiseControllerObject returns the OpenKruise controller object owning the pod, through the dynamic client
+func (h *Handler) getOpenKruiseControllerObject(kind, resource string, pod v1.Pod) (*controllerObject, error) {
+	obj, err := h.appContext.DynamicK8SClient.Resource(h.openKruiseResource(resource)).Namespace(pod.Namespace).
+		Get(h.appContext.Context, pod.OwnerReferences[0].Name, metav1.GetOptions{})
+	if err != nil {
+		return newControllerObject("Unknown", "", "", nil), err
+	}
+	return newControllerObject(kind, obj.GetName(), obj.GetNamespace(), obj), nil
+}
+
+// openKruiseResource returns the resource from the `apps.kruise.io` API group in OpenKruiseAPIVersion
+func (h *Handler) openKruiseResource(resource string) schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    openKruiseGroup,
+		Version:  h.appContext.Config().OpenKruiseAPIVersion,
+		Resource: resource,
+	}
+}
+
+// isOpenKruiseRolloutInProgress reports whether an OpenKruise CloneSet or Advanced StatefulSet is rolling out a new
+// revision, comparing its updated replicas with the desired ones
+func isOpenKruiseRolloutInProgress(obj *unstructured.Unstructured) (bool, error) {
+	observedGeneration, _, err := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	if err != nil {
+		return false, err
+	}
+	if obj.GetGeneration() > observedGeneration {
+		// the controller did not process the latest spec yet
+		return false, nil
+	}
+
+	replicas, found, err := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		replicas = 1
+	}
+	updatedReplicas, _, err := unstructured.NestedInt64(obj.Object, "status", "updatedReplicas")
+	if err != nil {
+		return false, err
+	}
+	updatedReadyReplicas, _, err := unstructured.NestedInt64(obj.Object, "status", "updatedReadyReplicas")
+	if err != nil {
+		return false, err
+	}
+
+	return updatedReplicas < replicas || updatedReadyReplicas < updatedReplicas, nil
+}
+
 func (h *Handler) isRolloutRestartInProgress(co *controllerObject) (bool, error) {
 	switch co.Kind {
 	case "Deployment":
@@ -453,20 +1266,18 @@ func (h *Handler) isRolloutRestartInProgress(co *controllerObject) (bool, error)
 		if sts.Status.UpdateRevision != sts.Status.CurrentRevision {
 			return true, nil
 		}
+	case "ReplicaSet", "ReplicationController":
+		// unlike Deployments, these controllers don't replace their pods on template changes. Once the restart recorded
+		// on their template, the pods left on parked nodes are evicted and recreated from it
+		_, restarted := h.rolloutRestarts.Load(co.Fingerprint())
+		return restarted && h.isRestartAnnotationSet(co), nil
 	case "Rollout":
-		rollout := co.Object.(*unstructured.Unstructured)
-
 		// TODO - check if the other rollout conditions should be checked as well
 		// See https://github.com/argoproj/argo-rollouts/blob/bfef7f0d2bb71b085398c35ec95c1b2aacd07187/rollout/sync.go#L618
-		isPaused, found, err := unstructured.NestedBool(rollout.Object, "spec", "paused")
-		if err != nil {
-			return false, err
-		}
-
-		if found && isPaused {
-			h.logger.Warnf("Argo Rollout %s is currently paused, won't restart it!", rollout.GetName())
-			return false, nil
-		}
+		// Paused Rollouts are handled according to the PausedRolloutPolicy before getting here
+		return false, nil
+	case "CloneSet", "AdvancedStatefulSet":
+		return isOpenKruiseRolloutInProgress(co.Object.(*unstructured.Unstructured))
 	default:
 		return false, errors.Errorf("rollout restart not supported for object of type %s", co.Kind)
 	}
@@ -474,54 +1285,121 @@ func (h *Handler) isRolloutRestartInProgress(co *controllerObject) (bool, error)
 	return false, nil
 }
 
-func (h *Handler) rolloutRestart(rr chan *controllerObject, done, doneBack chan bool) {
-	processed := map[string]bool{}
-	for {
-		select {
-		case co := <-rr:
-			key := co.Fingerprint()
+// getScalingHPA returns the name of the HorizontalPodAutoscaler targeting the controller object if it is scaling it,
+// or did so within HPAStabilizationWindow. An empty name is returned otherwise
+func (h *Handler) getScalingHPA(co *controllerObject) (string, error) {
+	hpaList, err := h.appContext.K8sClient.AutoscalingV2().HorizontalPodAutoscalers(co.Namespace).List(h.appContext.Context, metav1.ListOptions{})
+	if err != nil {
+		return "", err
+	}
 
-			if _, ok := processed[key]; ok {
-				h.logger.
-					WithField("key", key).
-					Debugf("Controller object already processed")
-				break
-			}
+	for _, hpa := range hpaList.Items {
+		if hpa.Spec.ScaleTargetRef.Kind != co.ScaleTargetKind() || hpa.Spec.ScaleTargetRef.Name != co.Name {
+			continue
+		}
 
-			processed[key] = true
+		if hpa.Status.DesiredReplicas != hpa.Status.CurrentReplicas {
+			return hpa.Name, nil
+		}
+		if hpa.Status.LastScaleTime != nil && time.Since(hpa.Status.LastScaleTime.Time) < h.appContext.Config().HPAStabilizationWindow {
+			return hpa.Name, nil
+		}
+	}
 
-			rolloutRestartInProgress, err := h.isRolloutRestartInProgress(co)
-			if err != nil {
-				h.logger.
-					WithField("key", key).
-					Warnf("Failed to get rollout status: %s", err.Error())
-				metrics.ShredderErrorsTotal.Inc()
-				break
-			}
+	return "", nil
+}
 
-			if rolloutRestartInProgress {
-				h.logger.
-					WithField("key", key).
-					Debug("Rollout restart already in progress")
-				break
-			}
+// rolloutRestart restarts the controller objects received on the rr channel until it is closed
+func (h *Handler) rolloutRestart(rr chan *controllerObject) {
+	for co := range rr {
+		metrics.ShredderPendingRolloutRestarts.WithLabelValues(h.appContext.Cluster).Dec()
+		key := co.Fingerprint()
 
-			err = h.doRolloutRestart(co)
-			if err != nil {
-				h.logger.
-					WithField("key", key).
-					Warnf("Failed to perform rollout restart: %s", err.Error())
-				metrics.ShredderErrorsTotal.Inc()
-			}
+		if utils.PauseStatus().Paused {
+			h.logger.WithField("key", key).Debug("k8s-shredder paused, not rollout restarting controller object")
+			continue
+		}
 
-		case <-done:
-			h.logger.Debugf("See you next time!")
-			doneBack <- true
-			return
+		if !h.claimRolloutRestart(key) {
+			h.logger.
+				WithField("key", key).
+				Debugf("Controller object already processed")
+			continue
+		}
+
+		rolloutRestartInProgress, err := h.isRolloutRestartInProgress(co)
+		if err != nil {
+			h.logger.
+				WithField("key", key).
+				Warnf("Failed to get rollout status: %s", err.Error())
+			h.countError()
+			continue
+		}
+
+		if rolloutRestartInProgress {
+			h.logger.
+				WithField("key", key).
+				Debug("Rollout restart already in progress")
+			continue
+		}
+
+		start := time.Now()
+		err = h.doRolloutRestart(co)
+		audit.Record(audit.Entry{
+			Action:    audit.ActionRestart,
+			Kind:      co.Kind,
+			Namespace: co.Namespace,
+			Name:      co.Name,
+			DryRun:    h.appContext.IsDryRun(),
+		}, start, err)
+		if err != nil {
+			h.logger.
+				WithField("key", key).
+				Warnf("Failed to perform rollout restart: %s", err.Error())
+			h.countError()
+			continue
+		}
+
+		h.summary.rolloutRestarts.Add(1)
+		if !h.appContext.IsDryRun() {
+			h.rolloutRestarts.Store(key, time.Now())
 		}
 	}
 }
 
+// claimRolloutRestart reports whether the controller object with the given fingerprint should be processed, which is
+// not the case when it was already processed during the current eviction loop or within RolloutRestartDedupTTL
+func (h *Handler) claimRolloutRestart(key string) bool {
+	now := time.Now()
+
+	value, loaded := h.queuedRestarts.LoadOrStore(key, now)
+	if !loaded {
+		return true
+	}
+
+	if h.isQueuedRestartFresh(value.(time.Time)) {
+		return false
+	}
+
+	// only one of the concurrent rollout restart goroutines can replace the expired entry
+	return h.queuedRestarts.CompareAndSwap(key, value, now)
+}
+
+// isQueuedRestartFresh reports whether a controller object processed at the given time must not be processed again yet
+func (h *Handler) isQueuedRestartFresh(processedAt time.Time) bool {
+	return !processedAt.Before(h.loopStart) || time.Since(processedAt) < h.appContext.Config().RolloutRestartDedupTTL
+}
+
+// pruneQueuedRestarts forgets the controller objects that can be processed again
+func (h *Handler) pruneQueuedRestarts() {
+	h.queuedRestarts.Range(func(key, value any) bool {
+		if time.Since(value.(time.Time)) >= h.appContext.Config().RolloutRestartDedupTTL {
+			h.queuedRestarts.Delete(key)
+		}
+		return true
+	})
+}
+
 func (h *Handler) doRolloutRestart(co *controllerObject) error {
 	h.logger.
 		WithField("fingerprint", co.Fingerprint()).
@@ -540,7 +1418,7 @@ func (h *Handler) doRolloutRestart(co *controllerObject) error {
 			"template": map[string]interface{}{
 				"metadata": map[string]map[string]string{
 					"annotations": {
-						h.appContext.Config.RestartedAtAnnotation: restartedAt,
+						h.appContext.Config().RestartedAtAnnotation: restartedAt,
 					},
 				},
 			},
@@ -550,33 +1428,84 @@ func (h *Handler) doRolloutRestart(co *controllerObject) error {
 	switch co.Kind {
 	case "Deployment":
 		deployment := co.Object.(*appsv1.Deployment)
-		_, err := h.appContext.K8sClient.AppsV1().Deployments(deployment.Namespace).
-			Patch(h.appContext.Context, deployment.Name, types.StrategicMergePatchType, patchData, patchOptions)
+		err := utils.RetryAPICall(h.appContext, func() error {
+			_, err := h.appContext.K8sClient.AppsV1().Deployments(deployment.Namespace).
+				Patch(h.appContext.Context, deployment.Name, types.StrategicMergePatchType, patchData, patchOptions)
+			return err
+		})
 		if err != nil {
 			return err
 		}
 	case "StatefulSet":
 		sts := co.Object.(*appsv1.StatefulSet)
-		_, err := h.appContext.K8sClient.AppsV1().StatefulSets(sts.Namespace).
-			Patch(h.appContext.Context, sts.Name, types.StrategicMergePatchType, patchData, patchOptions)
+		err := utils.RetryAPICall(h.appContext, func() error {
+			_, err := h.appContext.K8sClient.AppsV1().StatefulSets(sts.Namespace).
+				Patch(h.appContext.Context, sts.Name, types.StrategicMergePatchType, patchData, patchOptions)
+			return err
+		})
 		if err != nil {
 			return err
 		}
 	case "Rollout":
 		rollout := co.Object.(*unstructured.Unstructured)
-		gvr := schema.GroupVersionResource{
-			Group:    "argoproj.io",
-			Version:  h.appContext.Config.ArgoRolloutsAPIVersion,
-			Resource: "rollouts",
-		}
+		gvr := h.argoRolloutsResource()
 
+		spec := map[string]interface{}{
+			"restartAt": restartedAt,
+		}
+		// only the resume-restart PausedRolloutPolicy sends paused Rollouts for a rollout restart
+		resumed := isArgoRolloutPaused(co)
+		if resumed {
+			spec["paused"] = false
+		}
 		patchDataRollout, _ := json.Marshal(map[string]interface{}{
-			"spec": map[string]interface{}{
-				"restartAt": restartedAt,
-			},
+			"spec": spec,
 		})
 
-		_, err := h.appContext.DynamicK8SClient.Resource(gvr).Namespace(rollout.GetNamespace()).Patch(h.appContext.Context, rollout.GetName(), types.MergePatchType, patchDataRollout, patchOptions)
+		err := utils.RetryAPICall(h.appContext, func() error {
+			_, err := h.appContext.DynamicK8SClient.Resource(gvr).Namespace(rollout.GetNamespace()).Patch(h.appContext.Context, rollout.GetName(), types.MergePatchType, patchDataRollout, patchOptions)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if resumed {
+			h.logger.WithField("fingerprint", co.Fingerprint()).Info("Resumed paused Argo Rollout")
+			metrics.ShredderPausedRolloutEscalationsTotal.WithLabelValues(h.appContext.Cluster, config.PausedRolloutPolicyResumeRestart).Inc()
+		}
+	case "ReplicaSet":
+		rs := co.Object.(*appsv1.ReplicaSet)
+		err := utils.RetryAPICall(h.appContext, func() error {
+			_, err := h.appContext.K8sClient.AppsV1().ReplicaSets(rs.Namespace).
+				Patch(h.appContext.Context, rs.Name, types.StrategicMergePatchType, patchData, patchOptions)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	case "ReplicationController":
+		rc := co.Object.(*v1.ReplicationController)
+		err := utils.RetryAPICall(h.appContext, func() error {
+			_, err := h.appContext.K8sClient.CoreV1().ReplicationControllers(rc.Namespace).
+				Patch(h.appContext.Context, rc.Name, types.StrategicMergePatchType, patchData, patchOptions)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	case "CloneSet", "AdvancedStatefulSet":
+		obj := co.Object.(*unstructured.Unstructured)
+		resource := "clonesets"
+		if co.Kind == "AdvancedStatefulSet" {
+			resource = "statefulsets"
+		}
+
+		// OpenKruise workloads pick up pod template changes like the native ones
+		err := utils.RetryAPICall(h.appContext, func() error {
+			_, err := h.appContext.DynamicK8SClient.Resource(h.openKruiseResource(resource)).Namespace(obj.GetNamespace()).
+				Patch(h.appContext.Context, obj.GetName(), types.MergePatchType, patchData, patchOptions)
+			return err
+		})
 		if err != nil {
 			return err
 		}
@@ -587,3 +1516,66 @@ func (h *Handler) doRolloutRestart(co *controllerObject) error {
 	}
 	return nil
 }
+
+// isRestartAnnotationSet reports whether the pod template of the controller object has the RestartedAtAnnotation
+func (h *Handler) isRestartAnnotationSet(co *controllerObject) bool {
+	var annotations map[string]string
+	switch co.Kind {
+	case "Deployment":
+		annotations = co.Object.(*appsv1.Deployment).Spec.Template.Annotations
+	case "StatefulSet":
+		annotations = co.Object.(*appsv1.StatefulSet).Spec.Template.Annotations
+	case "ReplicaSet":
+		annotations = co.Object.(*appsv1.ReplicaSet).Spec.Template.Annotations
+	case "ReplicationController":
+		if template := co.Object.(*v1.ReplicationController).Spec.Template; template != nil {
+			annotations = template.Annotations
+		}
+	case "CloneSet", "AdvancedStatefulSet":
+		annotations, _, _ = unstructured.NestedStringMap(co.Object.(*unstructured.Unstructured).Object, "spec", "template", "metadata", "annotations")
+	}
+
+	_, ok := annotations[h.appContext.Config().RestartedAtAnnotation]
+	return ok
+}
+
+// isRolloutRestartReverted reports whether the restartedAt annotation set by k8s-shredder during a rollout restart in a
+// previous eviction loop is gone. This happens when a GitOps tool like Argo CD syncs the object back to its desired state,
+// in which case restarting it again would be pointless. With record, the revert is remembered, logged and counted.
+func (h *Handler) isRolloutRestartReverted(co *controllerObject, record bool) bool {
+	key := co.Fingerprint()
+
+	if _, ok := h.revertedRestarts.Load(key); ok {
+		return true
+	}
+
+	value, ok := h.rolloutRestarts.Load(key)
+	if !ok || !value.(time.Time).Before(h.loopStart) {
+		return false
+	}
+
+	if co.Kind == "Rollout" || h.isRestartAnnotationSet(co) {
+		return false
+	}
+	if !record {
+		return true
+	}
+
+	if _, loaded := h.revertedRestarts.LoadOrStore(key, true); !loaded {
+		h.logger.WithField("key", key).Warnf("Rollout restart annotation %s was reverted, falling back to pod eviction", h.appContext.Config().RestartedAtAnnotation)
+		metrics.ShredderRolloutRestartsRevertedTotal.WithLabelValues(h.appContext.Cluster).Inc()
+	}
+	return true
+}
+
+// pruneRolloutRestarts forgets the rollout restarts older than the longest parked node TTL, as the nodes they were
+// meant to drain are expired by now
+func (h *Handler) pruneRolloutRestarts() {
+	h.rolloutRestarts.Range(func(key, value any) bool {
+		if time.Since(value.(time.Time)) > h.appContext.Config().MaxParkedNodeTTL() {
+			h.rolloutRestarts.Delete(key)
+			h.revertedRestarts.Delete(key)
+		}
+		return true
+	})
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package handler
+
+import (
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// getPodJob returns the Job owning a pod, or nil for pods not run by a Job. The pods of CronJobs are owned by the Jobs
+// the CronJob creates
+func (h *Handler) getPodJob(pod v1.Pod) (*batchv1.Job, error) {
+	owner := metav1.GetControllerOf(&pod)
+	if owner == nil || owner.Kind != "Job" {
+		return nil, nil
+	}
+
+	return h.appContext.K8sClient.BatchV1().Jobs(pod.Namespace).Get(h.appContext.Context, owner.Name, metav1.GetOptions{})
+}
+
+// jobFinished checks whether a Job completed or failed
+func jobFinished(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if (condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed) && condition.Status == v1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// jobCompletion returns the ratio of the completions of a Job already reached
+func jobCompletion(job *batchv1.Job) float64 {
+	completions := int32(1)
+	if job.Spec.Completions != nil && *job.Spec.Completions > 0 {
+		completions = *job.Spec.Completions
+	}
+	return float64(job.Status.Succeeded) / float64(completions)
+}
+
+// deferJobEviction checks whether the eviction of a pod run by a Job waits for the Job to finish, returning why it does.
+// Pods are deferred for up to JobEvictionMaxWait after their node was parked for ttl, until expiresOn
+func (h *Handler) deferJobEviction(pod v1.Pod, expiresOn time.Time, ttl time.Duration) (string, error) {
+	deadline := expiresOn.Add(-ttl).Add(h.appContext.Config().JobEvictionMaxWait)
+	if time.Now().UTC().After(deadline) {
+		return "", nil
+	}
+
+	job, err := h.getPodJob(pod)
+	if err != nil || job == nil || jobFinished(job) {
+		return "", err
+	}
+	return fmt.Sprintf("Job %s/%s is running, deferring the eviction until %s", job.Namespace, job.Name, deadline.Format(time.RFC3339)), nil
+}
+
+// deferJobExpiry checks whether the expiry action of a parked node waits for one of its Jobs to finish, returning why it
+// does. Only the Jobs having reached JobNearCompletionRatio of their completions are waited for, for up to
+// JobEvictionMaxWait after expiresOn
+func (h *Handler) deferJobExpiry(pods []v1.Pod, expiresOn time.Time) (string, error) {
+	cfg := *h.appContext.Config()
+
+	deadline := expiresOn.Add(cfg.JobEvictionMaxWait)
+	if cfg.JobNearCompletionRatio == 0 || time.Now().UTC().After(deadline) {
+		return "", nil
+	}
+
+	seen := map[string]bool{}
+	for _, pod := range pods {
+		owner := metav1.GetControllerOf(&pod)
+		if owner == nil || owner.Kind != "Job" || seen[pod.Namespace+"/"+owner.Name] {
+			continue
+		}
+		seen[pod.Namespace+"/"+owner.Name] = true
+
+		job, err := h.getPodJob(pod)
+		if err != nil {
+			return "", err
+		}
+		if jobFinished(job) {
+			continue
+		}
+		if completion := jobCompletion(job); completion >= cfg.JobNearCompletionRatio {
+			return fmt.Sprintf("Job %s/%s is %.0f%% complete, deferring the expiry until %s", job.Namespace, job.Name, completion*100, deadline.Format(time.RFC3339)), nil
+		}
+	}
+	return "", nil
+}
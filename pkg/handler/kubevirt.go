@@ -0,0 +1,138 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package handler
+
+import (
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/audit"
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+	"github.com/adobe/k8s-shredder/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const kubeVirtGroup = "kubevirt.io"
+
+// virtLauncherVMI returns the name of the KubeVirt VirtualMachineInstance run by a virt-launcher pod, or an empty string
+// for any other pod
+func virtLauncherVMI(pod v1.Pod) string {
+	for _, owner := range pod.OwnerReferences {
+		gv, err := schema.ParseGroupVersion(owner.APIVersion)
+		if err == nil && gv.Group == kubeVirtGroup && owner.Kind == "VirtualMachineInstance" {
+			return owner.Name
+		}
+	}
+	return ""
+}
+
+// kubeVirtResource returns the resource from the `kubevirt.io` API group in KubeVirtAPIVersion
+func (h *Handler) kubeVirtResource(resource string) schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    kubeVirtGroup,
+		Version:  h.appContext.Config().KubeVirtAPIVersion,
+		Resource: resource,
+	}
+}
+
+// liveMigrateVMI moves the VirtualMachineInstance run by a virt-launcher pod to another node, by creating a
+// VirtualMachineInstanceMigration. It returns false when the VMI can't be live migrated, so that the pod gets evicted instead
+func (h *Handler) liveMigrateVMI(pod v1.Pod, vmiName string) (bool, error) {
+	logger := h.logger.WithField("namespace", pod.Namespace).WithField("vmi", vmiName)
+
+	vmi, err := h.appContext.DynamicK8SClient.Resource(h.kubeVirtResource("virtualmachineinstances")).Namespace(pod.Namespace).
+		Get(h.appContext.Context, vmiName, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	// the VMI already moved to another node, the pod left behind is about to be deleted
+	if nodeName, _, _ := unstructured.NestedString(vmi.Object, "status", "nodeName"); nodeName != "" && nodeName != pod.Spec.NodeName {
+		logger.Debugf("VirtualMachineInstance already runs on node %s", nodeName)
+		return true, nil
+	}
+
+	if !vmiLiveMigratable(vmi) {
+		logger.Info("VirtualMachineInstance is not live migratable, evicting its pod instead")
+		return false, nil
+	}
+
+	// a migration still running will move the VMI away, its completion deleting the pod
+	if vmiMigrationInProgress(vmi) {
+		logger.Debug("VirtualMachineInstance is already being live migrated")
+		return true, nil
+	}
+
+	migration := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": kubeVirtGroup + "/" + h.appContext.Config().KubeVirtAPIVersion,
+		"kind":       "VirtualMachineInstanceMigration",
+		"metadata": map[string]interface{}{
+			"generateName": vmiName + "-shredder-",
+			"namespace":    pod.Namespace,
+		},
+		"spec": map[string]interface{}{
+			"vmiName": vmiName,
+		},
+	}}
+
+	createOptions := metav1.CreateOptions{FieldManager: "k8s-shredder"}
+	if h.appContext.IsDryRun() {
+		createOptions.DryRun = []string{metav1.DryRunAll}
+	}
+
+	start := time.Now()
+	err = utils.RetryAPICall(h.appContext, func() error {
+		_, err := h.appContext.DynamicK8SClient.Resource(h.kubeVirtResource("virtualmachineinstancemigrations")).Namespace(pod.Namespace).
+			Create(h.appContext.Context, migration, createOptions)
+		return err
+	})
+	audit.Record(audit.Entry{
+		Action:    audit.ActionLiveMigrate,
+		Kind:      "VirtualMachineInstance",
+		Namespace: pod.Namespace,
+		Name:      vmiName,
+		Node:      pod.Spec.NodeName,
+		DryRun:    h.appContext.IsDryRun(),
+	}, start, err)
+	if err != nil {
+		return false, err
+	}
+
+	logger.Info("Live migrating VirtualMachineInstance")
+	metrics.ShredderVMILiveMigrationsTotal.WithLabelValues(h.appContext.Cluster).Inc()
+	return true, nil
+}
+
+// vmiLiveMigratable checks the LiveMigratable condition KubeVirt sets on a VirtualMachineInstance
+func vmiLiveMigratable(vmi *unstructured.Unstructured) bool {
+	conditions, _, _ := unstructured.NestedSlice(vmi.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if ok && condition["type"] == "LiveMigratable" {
+			return condition["status"] == string(v1.ConditionTrue)
+		}
+	}
+	return false
+}
+
+// vmiMigrationInProgress checks whether the last live migration of a VirtualMachineInstance is still running
+func vmiMigrationInProgress(vmi *unstructured.Unstructured) bool {
+	migrationState, found, _ := unstructured.NestedMap(vmi.Object, "status", "migrationState")
+	if !found {
+		return false
+	}
+	completed, _, _ := unstructured.NestedBool(migrationState, "completed")
+	failed, _, _ := unstructured.NestedBool(migrationState, "failed")
+	return !completed && !failed
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package handler
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// loopSummary tallies what happened during an eviction loop. Its counters are updated concurrently by the node and
+// rollout restart goroutines
+type loopSummary struct {
+	nodesProcessed   atomic.Int64
+	podsEvicted      atomic.Int64
+	podsForceDeleted atomic.Int64
+	rolloutRestarts  atomic.Int64
+	errors           atomic.Int64
+}
+
+// reset clears the counters at the beginning of an eviction loop
+func (s *loopSummary) reset() {
+	s.nodesProcessed.Store(0)
+	s.podsEvicted.Store(0)
+	s.podsForceDeleted.Store(0)
+	s.rolloutRestarts.Store(0)
+	s.errors.Store(0)
+}
+
+// countError increments the error metric along with the errors of the current eviction loop
+func (h *Handler) countError() {
+	metrics.ShredderErrorsTotal.WithLabelValues(h.appContext.Cluster).Inc()
+	h.summary.errors.Add(1)
+}
+
+// reportLoopSummary logs a single record summarizing the eviction loop and exposes it through the shredder_last_loop_*
+// metrics
+func (h *Handler) reportLoopSummary(duration time.Duration) {
+	s := &h.summary
+	cluster := h.appContext.Cluster
+
+	metrics.ShredderLastLoopNodesProcessed.WithLabelValues(cluster).Set(float64(s.nodesProcessed.Load()))
+	metrics.ShredderLastLoopPodsEvicted.WithLabelValues(cluster).Set(float64(s.podsEvicted.Load()))
+	metrics.ShredderLastLoopPodsForceDeleted.WithLabelValues(cluster).Set(float64(s.podsForceDeleted.Load()))
+	metrics.ShredderLastLoopRolloutRestarts.WithLabelValues(cluster).Set(float64(s.rolloutRestarts.Load()))
+	metrics.ShredderLastLoopErrors.WithLabelValues(cluster).Set(float64(s.errors.Load()))
+	metrics.ShredderLastLoopDurationSeconds.WithLabelValues(cluster).Set(duration.Seconds())
+
+	h.logger.WithFields(log.Fields{
+		"nodesProcessed":   s.nodesProcessed.Load(),
+		"podsEvicted":      s.podsEvicted.Load(),
+		"podsForceDeleted": s.podsForceDeleted.Load(),
+		"rolloutRestarts":  s.rolloutRestarts.Load(),
+		"errors":           s.errors.Load(),
+		"duration":         duration.Round(time.Millisecond).String(),
+	}).Info("Eviction loop summary")
+}
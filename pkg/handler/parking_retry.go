@@ -0,0 +1,97 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package handler
+
+import (
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+	"github.com/adobe/k8s-shredder/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+// failedParking is a node that could not be parked, retried during the next eviction loops
+type failedParking struct {
+	nodeInfo utils.NodeInfo
+	source   string
+	attempts int
+}
+
+// queueFailedParkings keeps the nodes from a ParkingError returned by ParkNodes, so that they get parked again during the
+// next eviction loops instead of staying unparked until their detector finds them again
+func (h *Handler) queueFailedParkings(err error, source string) {
+	var parkingErr *utils.ParkingError
+	if h.appContext.Config().ParkingRetryLimit <= 0 || !errors.As(err, &parkingErr) {
+		return
+	}
+
+	for _, nodeInfo := range parkingErr.Failed {
+		failed := failedParking{nodeInfo: nodeInfo, source: source}
+		if previous, found := h.failedParkings.Load(nodeInfo.Name); found {
+			failed.attempts = previous.(failedParking).attempts
+		}
+		failed.attempts++
+		h.failedParkings.Store(nodeInfo.Name, failed)
+	}
+}
+
+// retryFailedParkings parks again the nodes which could not be parked, until they are parked or ParkingRetryLimit
+// attempts failed
+func (h *Handler) retryFailedParkings() {
+	bySource := map[string][]utils.NodeInfo{}
+	h.failedParkings.Range(func(key, value any) bool {
+		failed := value.(failedParking)
+		if failed.attempts > h.appContext.Config().ParkingRetryLimit {
+			h.logger.WithField("node", key).Errorf("Giving up parking node on behalf of %s after %d attempts", failed.source, failed.attempts)
+			h.failedParkings.Delete(key)
+			return true
+		}
+		bySource[failed.source] = append(bySource[failed.source], failed.nodeInfo)
+		return true
+	})
+
+	for source, nodes := range bySource {
+		h.logger.WithField("source", source).Infof("Retrying to park %d nodes", len(nodes))
+		err := h.parkNodes(nodes, source)
+
+		stillFailed := map[string]bool{}
+		var parkingErr *utils.ParkingError
+		if errors.As(err, &parkingErr) {
+			for _, nodeInfo := range parkingErr.Failed {
+				stillFailed[nodeInfo.Name] = true
+			}
+		} else if err != nil {
+			h.logger.WithField("source", source).Errorf("Failed to park nodes: %s", err.Error())
+			h.countError()
+			for _, nodeInfo := range nodes {
+				stillFailed[nodeInfo.Name] = true
+			}
+		}
+
+		for _, nodeInfo := range nodes {
+			if !stillFailed[nodeInfo.Name] {
+				h.failedParkings.Delete(nodeInfo.Name)
+				continue
+			}
+			if value, found := h.failedParkings.Load(nodeInfo.Name); found {
+				failed := value.(failedParking)
+				failed.attempts++
+				h.failedParkings.Store(nodeInfo.Name, failed)
+			}
+		}
+	}
+
+	count := 0
+	h.failedParkings.Range(func(_, _ any) bool {
+		count++
+		return true
+	})
+	metrics.ShredderParkingRetriesPending.WithLabelValues(h.appContext.Cluster).Set(float64(count))
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/config"
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+	"github.com/adobe/k8s-shredder/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// isArgoRolloutPaused reports whether a controller object is an Argo Rollout paused through its spec
+func isArgoRolloutPaused(co *controllerObject) bool {
+	if co.Kind != "Rollout" {
+		return false
+	}
+	paused, _, _ := unstructured.NestedBool(co.Object.(*unstructured.Unstructured).Object, "spec", "paused")
+	return paused
+}
+
+// pausedRolloutEscalationStart returns when the PausedRolloutPolicy applies to the paused Argo Rollouts running pods on
+// a parked node expiring at expiresOn and parked for ttl
+func (h *Handler) pausedRolloutEscalationStart(expiresOn time.Time, ttl time.Duration) time.Time {
+	return expiresOn.Add(-ttl * time.Duration(100-h.appContext.Config().PausedRolloutEscalationThreshold*100) / 100)
+}
+
+// notifyPausedRollout sets the PausedRolloutAnnotation of a paused Argo Rollout to the time its pods get force evicted
+// and records a warning event on it. Nothing is done when the Rollout was already notified about that time.
+func (h *Handler) notifyPausedRollout(co *controllerObject, expiresOn time.Time) error {
+	rollout := co.Object.(*unstructured.Unstructured)
+	annotation := h.appContext.Config().PausedRolloutAnnotation
+	deadline := expiresOn.UTC().Format(time.RFC3339)
+
+	if rollout.GetAnnotations()[annotation] == deadline {
+		return nil
+	}
+
+	patchOptions := metav1.PatchOptions{
+		FieldManager: "k8s-shredder",
+	}
+	if h.appContext.IsDryRun() {
+		patchOptions.DryRun = []string{metav1.DryRunAll}
+	}
+
+	patchData, _ := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				annotation: deadline,
+			},
+		},
+	})
+
+	err := utils.RetryAPICall(h.appContext, func() error {
+		_, err := h.appContext.DynamicK8SClient.Resource(h.argoRolloutsResource()).Namespace(rollout.GetNamespace()).
+			Patch(h.appContext.Context, rollout.GetName(), types.MergePatchType, patchData, patchOptions)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("Argo Rollout is paused and can't be rollout restarted, its pods running on parked nodes get force evicted on %s", deadline)
+	h.appContext.RecordEvent(rollout, v1.EventTypeWarning, "PausedRolloutBlockingNode", message)
+	metrics.ShredderPausedRolloutEscalationsTotal.WithLabelValues(h.appContext.Cluster, config.PausedRolloutPolicyNotify).Inc()
+	h.logger.WithField("key", co.Fingerprint()).Warnf("Argo Rollout is paused, notified its owners about the force eviction of its pods on %s", deadline)
+	return nil
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/audit"
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+	"github.com/adobe/k8s-shredder/pkg/utils"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+)
+
+// nodeLifecycle accumulates what happened to a parked node until it got drained
+type nodeLifecycle struct {
+	mu                   sync.Mutex
+	evictedPods          int
+	deletedPods          int
+	restartedControllers map[string]bool
+	pdbConflicts         int
+	eligiblePods         int
+	reported             bool
+	expiryNotified       bool
+}
+
+// NodeReport summarizes the shredding of a parked node, once it got drained
+type NodeReport struct {
+	Node              string    `json:"node"`
+	ParkedAt          time.Time `json:"parkedAt"`
+	DrainedAt         time.Time `json:"drainedAt"`
+	ParkedFor         string    `json:"parkedFor"`
+	EvictedPods       int       `json:"evictedPods"`
+	DeletedPods       int       `json:"deletedPods"`
+	RestartsTriggered int       `json:"restartsTriggered"`
+	PDBConflicts      int       `json:"pdbConflicts"`
+	DryRun            bool      `json:"dryRun"`
+}
+
+// parkedAt returns when a node got parked. For the nodes parked by older releases, missing the ParkedAtLabel, it is
+// derived from the expiry time, assuming the TTL did not change since
+func (h *Handler) parkedAt(node v1.Node, expiresOn time.Time, ttl time.Duration) time.Time {
+	parkedAt, err := utils.GetParkedNodeParkedTime(node, h.appContext.Config().ParkedAtLabel)
+	if err != nil {
+		return expiresOn.Add(-ttl)
+	}
+	return parkedAt
+}
+
+// lifecycle returns the lifecycle of a parked node, creating it if needed
+func (h *Handler) lifecycle(nodeName string) *nodeLifecycle {
+	value, _ := h.lifecycles.LoadOrStore(nodeName, &nodeLifecycle{restartedControllers: map[string]bool{}})
+	return value.(*nodeLifecycle)
+}
+
+// recordLifecycle updates the lifecycle of a parked node
+func (h *Handler) recordLifecycle(nodeName string, update func(l *nodeLifecycle)) {
+	if nodeName == "" {
+		return
+	}
+	l := h.lifecycle(nodeName)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	update(l)
+}
+
+// recordDrainProgress updates the drain progress of a parked node from the number of pods left to evict, relative to the
+// most pods observed on it since it got parked
+func (h *Handler) recordDrainProgress(nodeName string, remainingPods int) {
+	progress := 1.0
+	h.recordLifecycle(nodeName, func(l *nodeLifecycle) {
+		l.eligiblePods = max(l.eligiblePods, remainingPods)
+		if l.eligiblePods > 0 {
+			progress = float64(l.eligiblePods-remainingPods) / float64(l.eligiblePods)
+		}
+	})
+	metrics.ShredderNodeDrainProgress.WithLabelValues(h.appContext.Cluster, nodeName).Set(progress)
+}
+
+// reportDrainedNode emits the end-of-life report of a parked node left without any pod to evict, once
+func (h *Handler) reportDrainedNode(node v1.Node, expiresOn time.Time, ttl time.Duration) {
+	l := h.lifecycle(node.Name)
+	l.mu.Lock()
+	if l.reported {
+		l.mu.Unlock()
+		return
+	}
+	l.reported = true
+
+	parkedAt := h.parkedAt(node, expiresOn, ttl)
+	report := NodeReport{
+		Node:              node.Name,
+		ParkedAt:          parkedAt,
+		DrainedAt:         time.Now().UTC(),
+		ParkedFor:         time.Since(parkedAt).Round(time.Second).String(),
+		EvictedPods:       l.evictedPods,
+		DeletedPods:       l.deletedPods,
+		RestartsTriggered: len(l.restartedControllers),
+		PDBConflicts:      l.pdbConflicts,
+		DryRun:            h.appContext.IsDryRun(),
+	}
+	l.mu.Unlock()
+
+	message := fmt.Sprintf("Node drained after being parked for %s: %d pods evicted, %d pods deleted, %d rollout restarts triggered, %d PDB conflicts",
+		report.ParkedFor, report.EvictedPods, report.DeletedPods, report.RestartsTriggered, report.PDBConflicts)
+	h.logger.WithField("node", node.Name).Info(message)
+	h.appContext.RecordEvent(&node, v1.EventTypeNormal, "NodeShredded", message)
+
+	audit.Record(audit.Entry{
+		Action: audit.ActionReport,
+		Kind:   "Node",
+		Name:   node.Name,
+		Node:   node.Name,
+		DryRun: report.DryRun,
+		Details: map[string]interface{}{
+			"parked_for":         report.ParkedFor,
+			"evicted_pods":       report.EvictedPods,
+			"deleted_pods":       report.DeletedPods,
+			"restarts_triggered": report.RestartsTriggered,
+			"pdb_conflicts":      report.PDBConflicts,
+		},
+	}, time.Now(), nil)
+
+	if h.appContext.Config().NodeReportWebhookURL != "" {
+		if err := h.postJSON(h.appContext.Config().NodeReportWebhookURL, report); err != nil {
+			h.logger.WithField("node", node.Name).Warnf("Failed to send node report: %s", err.Error())
+		}
+	}
+}
+
+// postJSON posts a JSON body to a webhook URL
+func (h *Handler) postJSON(url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(h.appContext.Context, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return errors.Errorf("unexpected response status %s", res.Status)
+	}
+	return nil
+}
+
+// pruneLifecycles forgets the lifecycles of the nodes which are not parked anymore
+func (h *Handler) pruneLifecycles() {
+	h.lifecycles.Range(func(key, value any) bool {
+		if !h.parkedNodes[key.(string)] {
+			h.lifecycles.Delete(key)
+		}
+		return true
+	})
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package handler
+
+import (
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+// Role is the part of the work done by a k8s-shredder instance, so that detection and eviction can be scaled
+// independently in large clusters. The instances coordinate through the parking labels of the nodes
+type Role string
+
+const (
+	// RoleAll both parks the nodes found by the detectors and drains the parked nodes
+	RoleAll Role = "all"
+	// RoleDetector only parks the nodes found by the detectors
+	RoleDetector Role = "detector"
+	// RoleEvictor only drains the parked nodes, whoever parked them
+	RoleEvictor Role = "evictor"
+)
+
+// ParseRole parses the value of the --role flag
+func ParseRole(value string) (Role, error) {
+	switch role := Role(value); role {
+	case RoleAll, RoleDetector, RoleEvictor:
+		return role, nil
+	default:
+		return "", errors.Errorf("unknown role %q, must be one of %s, %s or %s", value, RoleAll, RoleDetector, RoleEvictor)
+	}
+}
+
+// Detects reports whether the role parks the nodes found by the detectors
+func (r Role) Detects() bool {
+	return r != RoleEvictor
+}
+
+// Evicts reports whether the role drains the parked nodes
+func (r Role) Evicts() bool {
+	return r != RoleDetector
+}
+
+// SetRole sets the role of the handler, RoleAll by default
+func (h *Handler) SetRole(role Role) {
+	h.role = role
+}
+
+// RunDetection parks the nodes found by the enabled detectors without draining them, in place of the eviction loop of
+// the instances running with RoleDetector
+func (h *Handler) RunDetection() {
+	if pause := utils.PauseStatus(); pause.Paused {
+		h.logger.WithField("reason", pause.Reason).Warnf("=== k8s-shredder PAUSED since %s, skipping detection ===", pause.Since.Format(time.RFC3339))
+		h.loopPaused()
+		return
+	}
+
+	start := time.Now()
+	h.loopStarted(start)
+	h.runDetection()
+	h.loopEnded(nil)
+}
+
+// runDetection parks the nodes found by the enabled detectors, along with the ones waiting to be parked
+func (h *Handler) runDetection() {
+	h.retryFailedParkings()
+	h.promoteSoftParkedNodes()
+	h.runDetectors()
+
+	if h.appContext.Config().EnableCapacityUnpark {
+		h.balanceCapacity()
+	}
+}
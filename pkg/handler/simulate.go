@@ -0,0 +1,179 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package handler
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/adobe/k8s-shredder/pkg/detection"
+	"github.com/adobe/k8s-shredder/pkg/utils"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// SimulatedNode is a node the enabled detectors would park
+type SimulatedNode struct {
+	Name   string
+	Source string
+	Pods   int
+}
+
+// BlockingPDB is a PodDisruptionBudget which doesn't allow evicting all the displaced pods it selects at once
+type BlockingPDB struct {
+	Namespace          string
+	Name               string
+	Pods               int
+	DisruptionsAllowed int32
+}
+
+// Simulation reports the impact of parking the nodes currently matched by the enabled detectors
+type Simulation struct {
+	Nodes []SimulatedNode
+	// PodsByNamespace counts the pods that would need rescheduling, per namespace
+	PodsByNamespace map[string]int
+	// Requested is the sum of the resource requests of the pods that would need rescheduling
+	Requested v1.ResourceList
+	// Available is the allocatable capacity left on the schedulable nodes which would not be parked
+	Available          v1.ResourceList
+	CapacitySufficient bool
+	BlockingPDBs       []BlockingPDB
+}
+
+// Simulate runs the enabled detectors and reports how many pods would need rescheduling if the nodes they matched were
+// parked, whether the capacity left on the other nodes suffices and which PodDisruptionBudgets would block. The capacity
+// check compares aggregated requests, ignoring fragmentation and scheduling constraints. Nothing is changed in the cluster
+func (h *Handler) Simulate() (*Simulation, error) {
+	s := &Simulation{
+		PodsByNamespace: map[string]int{},
+		Requested:       v1.ResourceList{},
+		Available:       v1.ResourceList{},
+	}
+
+	candidates := map[string]bool{}
+	for _, detector := range detection.EnabledDetectors(h.appContext) {
+		nodes, err := detector.Detect(h.appContext.Context)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to run detector %s", detector.Name())
+		}
+		for _, node := range nodes {
+			if candidates[node.Name] {
+				continue
+			}
+			candidates[node.Name] = true
+			s.Nodes = append(s.Nodes, SimulatedNode{Name: node.Name, Source: detector.Name()})
+		}
+	}
+
+	allNodes, err := h.appContext.ListNodes(h.appContext.Context, h.appContext.K8sClient, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list nodes")
+	}
+
+	pods, err := utils.ListPods(h.appContext.Context, h.appContext.K8sClient, "", h.appContext.Config().APIListPageSize, metav1.ListOptions{
+		FieldSelector: "status.phase!=Succeeded,status.phase!=Failed",
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list pods")
+	}
+
+	remaining := map[string]bool{}
+	for _, node := range allNodes {
+		if candidates[node.Name] || node.Spec.Unschedulable || !utils.NodeReady(node) {
+			continue
+		}
+		remaining[node.Name] = true
+		utils.AddResources(s.Available, node.Status.Allocatable)
+	}
+
+	podsPerNode := map[string]int{}
+	var displaced []v1.Pod
+	for _, pod := range pods {
+		requests := utils.PodRequests(pod)
+
+		if remaining[pod.Spec.NodeName] {
+			utils.SubtractResources(s.Available, requests)
+			continue
+		}
+		if !candidates[pod.Spec.NodeName] || pod.DeletionTimestamp != nil || utils.PodExclusionReason(pod, *h.appContext.Config()) != "" {
+			continue
+		}
+
+		displaced = append(displaced, pod)
+		podsPerNode[pod.Spec.NodeName]++
+		s.PodsByNamespace[pod.Namespace]++
+		utils.AddResources(s.Requested, requests)
+	}
+
+	for i := range s.Nodes {
+		s.Nodes[i].Pods = podsPerNode[s.Nodes[i].Name]
+	}
+
+	s.CapacitySufficient = true
+	for _, name := range utils.CapacityResources {
+		requested, available := s.Requested[name], s.Available[name]
+		if requested.Cmp(available) > 0 {
+			s.CapacitySufficient = false
+		}
+	}
+
+	s.BlockingPDBs, err = h.getBlockingPDBs(displaced)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// getBlockingPDBs returns the PodDisruptionBudgets selecting more of the given pods than they currently allow to disrupt
+func (h *Handler) getBlockingPDBs(pods []v1.Pod) ([]BlockingPDB, error) {
+	if len(pods) == 0 {
+		return nil, nil
+	}
+
+	pdbs, err := h.appContext.K8sClient.PolicyV1().PodDisruptionBudgets("").List(h.appContext.Context, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list PodDisruptionBudgets")
+	}
+
+	var blocking []BlockingPDB
+	for _, pdb := range pdbs.Items {
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil {
+			h.logger.Warnf("Skipping PodDisruptionBudget %s/%s with an invalid selector: %s", pdb.Namespace, pdb.Name, err.Error())
+			continue
+		}
+
+		selected := 0
+		for _, pod := range pods {
+			if pod.Namespace == pdb.Namespace && selector.Matches(labels.Set(pod.Labels)) {
+				selected++
+			}
+		}
+
+		if selected > int(pdb.Status.DisruptionsAllowed) {
+			blocking = append(blocking, BlockingPDB{
+				Namespace:          pdb.Namespace,
+				Name:               pdb.Name,
+				Pods:               selected,
+				DisruptionsAllowed: pdb.Status.DisruptionsAllowed,
+			})
+		}
+	}
+
+	sort.Slice(blocking, func(i, j int) bool {
+		return fmt.Sprintf("%s/%s", blocking[i].Namespace, blocking[i].Name) < fmt.Sprintf("%s/%s", blocking[j].Namespace, blocking[j].Name)
+	})
+	return blocking, nil
+}
@@ -0,0 +1,36 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package handler
+
+import (
+	"github.com/adobe/k8s-shredder/pkg/utils"
+)
+
+// promoteSoftParkedNodes parks the soft parked nodes once SoftParkingDelay elapsed, on behalf of the source they were
+// soft parked for
+func (h *Handler) promoteSoftParkedNodes() {
+	bySource, err := utils.SoftParkedNodesToPromote(h.appContext)
+	if err != nil {
+		h.logger.Errorf("Failed to list the soft parked nodes: %s", err.Error())
+		h.countError()
+		return
+	}
+
+	for source, nodes := range bySource {
+		h.logger.WithField("source", source).Infof("Parking %d soft parked nodes", len(nodes))
+		err := h.parkNodes(nodes, source)
+		if err != nil {
+			h.logger.WithField("source", source).Errorf("%s", err.Error())
+			h.queueFailedParkings(err, source)
+		}
+	}
+}
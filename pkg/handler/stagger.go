@@ -0,0 +1,91 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package handler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// forceEviction records when the pods of an expired parked node started being force evicted, along with the zone of
+// the node and the owners of its pods
+type forceEviction struct {
+	at     time.Time
+	zone   string
+	owners map[string]bool
+}
+
+// podOwners returns the fingerprints of the controllers owning the given pods
+func podOwners(pods []v1.Pod) map[string]bool {
+	owners := map[string]bool{}
+	for _, pod := range pods {
+		if owner := metav1.GetControllerOf(&pod); owner != nil {
+			owners[fmt.Sprintf("%s/%s/%s", owner.Kind, pod.Namespace, owner.Name)] = true
+		}
+	}
+	return owners
+}
+
+// claimForceEviction checks whether the pods of an expired parked node can be force evicted now, given the other nodes
+// force evicted within ForceEvictionStaggerWindow. A node waits while a node from another zone, or a node running pods of
+// the same owners, is in that window, so that expiring nodes don't take out every replica of a workload at once. The node
+// is recorded when it can go ahead, otherwise the node it waits for is returned
+func (h *Handler) claimForceEviction(node v1.Node, pods []v1.Pod) (string, bool) {
+	window := h.appContext.Config().ForceEvictionStaggerWindow
+	if window <= 0 {
+		return "", true
+	}
+
+	h.forceEvictionsMu.Lock()
+	defer h.forceEvictionsMu.Unlock()
+
+	now := time.Now()
+	zone := node.Labels[v1.LabelTopologyZone]
+	owners := podOwners(pods)
+
+	var blocker string
+	h.forceEvictions.Range(func(key, value any) bool {
+		other := value.(forceEviction)
+		if now.Sub(other.at) > window {
+			h.forceEvictions.Delete(key)
+			return true
+		}
+		if key.(string) == node.Name || blocker != "" {
+			return true
+		}
+		if other.zone != zone {
+			blocker = key.(string)
+			return true
+		}
+		for owner := range owners {
+			if other.owners[owner] {
+				blocker = key.(string)
+				break
+			}
+		}
+		return true
+	})
+	if blocker != "" {
+		metrics.ShredderForceEvictionsStaggeredTotal.WithLabelValues(h.appContext.Cluster).Inc()
+		return blocker, false
+	}
+
+	// nodes keep the time their force eviction started, so that they don't hold the others back until they are drained
+	if _, found := h.forceEvictions.Load(node.Name); !found {
+		h.forceEvictions.Store(node.Name, forceEviction{at: now, zone: zone, owners: owners})
+	}
+	return "", true
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/config"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestClaimForceEviction(t *testing.T) {
+	zonedNode := func(name, zone string) v1.Node {
+		return v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{v1.LabelTopologyZone: zone}}}
+	}
+	ownedPod := func(owner string) v1.Pod {
+		return v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: owner + "-pod", Namespace: "default", OwnerReferences: controlledBy("ReplicaSet", owner)}}
+	}
+
+	tests := []struct {
+		name        string
+		window      time.Duration
+		evictions   map[string]forceEviction
+		node        v1.Node
+		pods        []v1.Pod
+		wantBlocker string
+		wantOK      bool
+	}{
+		{
+			name:   "staggering disabled",
+			window: 0,
+			evictions: map[string]forceEviction{
+				"node-2": {at: time.Now(), zone: "zone-b"},
+			},
+			node:   zonedNode("node-1", "zone-a"),
+			pods:   []v1.Pod{ownedPod("app")},
+			wantOK: true,
+		},
+		{
+			name:   "no other force eviction",
+			window: time.Hour,
+			node:   zonedNode("node-1", "zone-a"),
+			pods:   []v1.Pod{ownedPod("app")},
+			wantOK: true,
+		},
+		{
+			name:   "same zone, different owners",
+			window: time.Hour,
+			evictions: map[string]forceEviction{
+				"node-2": {at: time.Now(), zone: "zone-a", owners: map[string]bool{"ReplicaSet/default/other": true}},
+			},
+			node:   zonedNode("node-1", "zone-a"),
+			pods:   []v1.Pod{ownedPod("app")},
+			wantOK: true,
+		},
+		{
+			name:   "same zone, shared owner",
+			window: time.Hour,
+			evictions: map[string]forceEviction{
+				"node-2": {at: time.Now(), zone: "zone-a", owners: map[string]bool{"ReplicaSet/default/app": true}},
+			},
+			node:        zonedNode("node-1", "zone-a"),
+			pods:        []v1.Pod{ownedPod("app")},
+			wantBlocker: "node-2",
+		},
+		{
+			name:   "other zone",
+			window: time.Hour,
+			evictions: map[string]forceEviction{
+				"node-2": {at: time.Now(), zone: "zone-b"},
+			},
+			node:        zonedNode("node-1", "zone-a"),
+			pods:        []v1.Pod{ownedPod("app")},
+			wantBlocker: "node-2",
+		},
+		{
+			name:   "other zone outside of the window",
+			window: time.Hour,
+			evictions: map[string]forceEviction{
+				"node-2": {at: time.Now().Add(-2 * time.Hour), zone: "zone-b", owners: map[string]bool{"ReplicaSet/default/app": true}},
+			},
+			node:   zonedNode("node-1", "zone-a"),
+			pods:   []v1.Pod{ownedPod("app")},
+			wantOK: true,
+		},
+		{
+			name:   "node already force evicting",
+			window: time.Hour,
+			evictions: map[string]forceEviction{
+				"node-1": {at: time.Now().Add(-time.Minute), zone: "zone-a", owners: map[string]bool{"ReplicaSet/default/app": true}},
+			},
+			node:   zonedNode("node-1", "zone-a"),
+			pods:   []v1.Pod{ownedPod("app")},
+			wantOK: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(config.Config{ForceEvictionStaggerWindow: tt.window, ArgoRolloutsAPIVersion: "v1alpha1"})
+			for name, eviction := range tt.evictions {
+				h.forceEvictions.Store(name, eviction)
+			}
+
+			blocker, ok := h.claimForceEviction(tt.node, tt.pods)
+			if blocker != tt.wantBlocker || ok != tt.wantOK {
+				t.Errorf("claimForceEviction() = (%q, %t), want (%q, %t)", blocker, ok, tt.wantBlocker, tt.wantOK)
+			}
+
+			if tt.window <= 0 {
+				return
+			}
+			recorded, found := h.forceEvictions.Load(tt.node.Name)
+			if found != ok {
+				t.Errorf("claimForceEviction() recorded the node = %t, want %t", found, ok)
+			}
+			// the node keeps the time its force eviction started
+			if previous, ok := tt.evictions[tt.node.Name]; ok && !recorded.(forceEviction).at.Equal(previous.at) {
+				t.Errorf("claimForceEviction() moved the start of the force eviction to %s, want %s", recorded.(forceEviction).at, previous.at)
+			}
+			for name, eviction := range tt.evictions {
+				if _, found := h.forceEvictions.Load(name); time.Since(eviction.at) > tt.window && found {
+					t.Errorf("claimForceEviction() kept %s, which is outside of the window", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package handler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/utils"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// LoopStatus describes the state of the eviction loops
+type LoopStatus struct {
+	Running      bool      `json:"running"`
+	LastStart    time.Time `json:"lastStart"`
+	LastEnd      time.Time `json:"lastEnd"`
+	LastDuration string    `json:"lastDuration"`
+	LastError    string    `json:"lastError,omitempty"`
+	ParkedNodes  int       `json:"parkedNodes"`
+	DelayedUntil time.Time `json:"delayedUntil"`
+	// LastPaused is the time of the last eviction loop skipped while k8s-shredder was paused
+	LastPaused time.Time `json:"lastPaused,omitempty"`
+}
+
+// loopStatus guards the LoopStatus, which is read while eviction loops are running
+type loopStatus struct {
+	mu     sync.RWMutex
+	status LoopStatus
+	// waiters are closed at the end of the next eviction loop
+	waiters []chan struct{}
+}
+
+// ParkedNode describes a parked node
+type ParkedNode struct {
+	Name      string    `json:"name"`
+	ExpiresOn time.Time `json:"expiresOn"`
+	Reason    string    `json:"reason,omitempty"`
+	Batch     string    `json:"batch,omitempty"`
+	Protected bool      `json:"protected"`
+	State     string    `json:"state,omitempty"`
+}
+
+// NodePod describes a pod left to evict from a node
+type NodePod struct {
+	Namespace    string `json:"namespace"`
+	Name         string `json:"name"`
+	EvictionCost int64  `json:"evictionCost"`
+}
+
+// Status returns the state of the eviction loops
+func (h *Handler) Status() LoopStatus {
+	h.status.mu.RLock()
+	defer h.status.mu.RUnlock()
+
+	return h.status.status
+}
+
+// loopStarted records the start of an eviction loop
+func (h *Handler) loopStarted(start time.Time) {
+	h.status.mu.Lock()
+	defer h.status.mu.Unlock()
+
+	h.status.status.Running = true
+	h.status.status.LastStart = start
+}
+
+// loopEnded records the end of an eviction loop along with its result
+func (h *Handler) loopEnded(err error) {
+	h.status.mu.Lock()
+	defer h.status.mu.Unlock()
+
+	h.status.status.Running = false
+	h.status.status.LastEnd = time.Now()
+	h.status.status.LastDuration = h.status.status.LastEnd.Sub(h.status.status.LastStart).String()
+	h.status.status.LastError = ""
+	if err != nil {
+		h.status.status.LastError = err.Error()
+	}
+	h.status.status.ParkedNodes = len(h.parkedNodes)
+	h.status.status.DelayedUntil = h.nextLoopAt
+
+	for _, waiter := range h.status.waiters {
+		close(waiter)
+	}
+	h.status.waiters = nil
+}
+
+// loopPaused records an eviction loop skipped while k8s-shredder was paused, which counts as completed for the
+// readiness check, so that /readyz doesn't fail right after resuming
+func (h *Handler) loopPaused() {
+	h.status.mu.Lock()
+	defer h.status.mu.Unlock()
+
+	h.status.status.LastPaused = time.Now()
+}
+
+// NextLoopEnd returns a channel closed once the next eviction loop ended, or the one currently running
+func (h *Handler) NextLoopEnd() <-chan struct{} {
+	h.status.mu.Lock()
+	defer h.status.mu.Unlock()
+
+	waiter := make(chan struct{})
+	h.status.waiters = append(h.status.waiters, waiter)
+	return waiter
+}
+
+// CheckLoopFreshness returns an error when no eviction loop completed within maxAge, counting from the creation of the
+// handler until the first one completes. Loops delayed on purpose after a long one or skipped while paused are not
+// considered late
+func (h *Handler) CheckLoopFreshness(maxAge time.Duration) error {
+	// paused loops are skipped on purpose, the admin API must stay reachable to resume them
+	if utils.PauseStatus().Paused {
+		return nil
+	}
+
+	h.status.mu.RLock()
+	defer h.status.mu.RUnlock()
+
+	last := h.status.status.LastEnd
+	if last.IsZero() {
+		last = h.createdAt
+	}
+	if h.status.status.DelayedUntil.After(last) {
+		last = h.status.status.DelayedUntil
+	}
+	if h.status.status.LastPaused.After(last) {
+		last = h.status.status.LastPaused
+	}
+	if age := time.Since(last); age > maxAge {
+		return errors.Errorf("no eviction loop completed for %s", age.Round(time.Second).String())
+	}
+	return nil
+}
+
+// ParkedNodes returns the nodes currently parked
+func (h *Handler) ParkedNodes() ([]ParkedNode, error) {
+	cfg := *h.appContext.Config()
+
+	parkedNodes, err := h.appContext.ListNodes(h.appContext.Context, h.appContext.BackgroundK8sClient, metav1.ListOptions{
+		LabelSelector: labels.Set{cfg.UpgradeStatusLabel: cfg.UpgradeStatusParkedValue}.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]ParkedNode, 0, len(parkedNodes))
+	for _, node := range parkedNodes {
+		// nodes with a missing or invalid expiry get a zero expiry time
+		expiresOn, err := utils.GetParkedNodeExpiryTime(node, cfg.ExpiresOnLabel)
+		if err != nil {
+			expiresOn = time.Time{}
+		}
+
+		nodes = append(nodes, ParkedNode{
+			Name:      node.Name,
+			ExpiresOn: expiresOn,
+			Reason:    node.Labels[cfg.ParkingReasonLabel],
+			Batch:     node.Labels[cfg.ParkingBatchLabel],
+			Protected: utils.NodeIsProtected(node, cfg),
+			State:     string(utils.GetNodeState(node, cfg)),
+		})
+	}
+
+	return nodes, nil
+}
+
+// NodePods returns the pods left to evict from a node, in eviction order
+func (h *Handler) NodePods(nodeName string) ([]NodePod, error) {
+	node, err := h.appContext.BackgroundK8sClient.CoreV1().Nodes().Get(h.appContext.Context, nodeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	podList, err := h.GetPodsForNode(*node)
+	if err != nil {
+		return nil, err
+	}
+	utils.SortPodsByEvictionCost(podList, h.appContext.Config().EvictionCostAnnotation)
+
+	pods := make([]NodePod, 0, len(podList))
+	for _, pod := range podList {
+		pods = append(pods, NodePod{
+			Namespace:    pod.Namespace,
+			Name:         pod.Name,
+			EvictionCost: utils.GetPodEvictionCost(pod, h.appContext.Config().EvictionCostAnnotation),
+		})
+	}
+
+	return pods, nil
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package handler
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+)
+
+// upcomingEvictionWindows are the time windows the upcoming force evictions are summarized by
+var upcomingEvictionWindows = []struct {
+	label  string
+	window time.Duration
+}{
+	{"1h", time.Hour},
+	{"6h", 6 * time.Hour},
+	{"24h", 24 * time.Hour},
+}
+
+// UpcomingEviction is a parked node along with the number of pods force evicted once it expires
+type UpcomingEviction struct {
+	Node      string    `json:"node"`
+	ExpiresOn time.Time `json:"expiresOn"`
+	Pods      int       `json:"pods"`
+}
+
+// UpcomingEvictions summarizes the pods the parked nodes will force evict, as seen by the last eviction loop
+type UpcomingEvictions struct {
+	ComputedAt time.Time `json:"computedAt"`
+	// Within holds the number of pods force evicted within each time window, the nodes already expired included
+	Within map[string]int     `json:"within"`
+	Nodes  []UpcomingEviction `json:"nodes"`
+}
+
+// upcomingEvictions holds the upcoming force evictions recorded by the current eviction loop and the ones published by
+// the previous loop
+type upcomingEvictions struct {
+	current   *sync.Map
+	mu        sync.RWMutex
+	published UpcomingEvictions
+}
+
+// recordUpcomingEviction remembers how many pods a parked node will force evict once it expires
+func (h *Handler) recordUpcomingEviction(node string, expiresOn time.Time, pods int) {
+	h.upcoming.current.Store(node, UpcomingEviction{Node: node, ExpiresOn: expiresOn, Pods: pods})
+}
+
+// publishUpcomingEvictions sorts the upcoming force evictions recorded by the eviction loop by expiry, exposes them through
+// the HTTP API and the shredder_upcoming_force_evictions metric and starts over for the next loop
+func (h *Handler) publishUpcomingEvictions() {
+	now := time.Now()
+	summary := UpcomingEvictions{ComputedAt: now.UTC(), Within: map[string]int{}, Nodes: []UpcomingEviction{}}
+	h.upcoming.current.Range(func(_, value any) bool {
+		summary.Nodes = append(summary.Nodes, value.(UpcomingEviction))
+		return true
+	})
+	h.upcoming.current = &sync.Map{}
+
+	sort.Slice(summary.Nodes, func(i, j int) bool {
+		if !summary.Nodes[i].ExpiresOn.Equal(summary.Nodes[j].ExpiresOn) {
+			return summary.Nodes[i].ExpiresOn.Before(summary.Nodes[j].ExpiresOn)
+		}
+		return summary.Nodes[i].Node < summary.Nodes[j].Node
+	})
+
+	for _, w := range upcomingEvictionWindows {
+		pods := 0
+		for _, node := range summary.Nodes {
+			if node.ExpiresOn.Before(now.Add(w.window)) {
+				pods += node.Pods
+			}
+		}
+		summary.Within[w.label] = pods
+		metrics.ShredderUpcomingForceEvictions.WithLabelValues(h.appContext.Cluster, w.label).Set(float64(pods))
+	}
+
+	h.upcoming.mu.Lock()
+	defer h.upcoming.mu.Unlock()
+	h.upcoming.published = summary
+}
+
+// UpcomingEvictions returns the upcoming force evictions seen by the last eviction loop
+func (h *Handler) UpcomingEvictions() UpcomingEvictions {
+	h.upcoming.mu.RLock()
+	defer h.upcoming.mu.RUnlock()
+	return h.upcoming.published
+}
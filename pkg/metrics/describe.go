@@ -0,0 +1,113 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package metrics
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricInfo describes a metric exposed by k8s-shredder
+type MetricInfo struct {
+	Name   string
+	Help   string
+	Type   string
+	Labels []string
+}
+
+// descPattern parses the string representation of a prometheus.Desc, the only way to get its name and labels back
+var descPattern = regexp.MustCompile(`^Desc\{fqName: (".*"), help: (".*"), constLabels: \{.*\}, variableLabels: \{(.*)\}\}$`)
+
+// recordingRegisterer keeps the collectors registered with it, without exposing them
+type recordingRegisterer struct {
+	collectors []prometheus.Collector
+}
+
+func (r *recordingRegisterer) Register(c prometheus.Collector) error {
+	r.collectors = append(r.collectors, c)
+	return nil
+}
+
+func (r *recordingRegisterer) MustRegister(cs ...prometheus.Collector) {
+	r.collectors = append(r.collectors, cs...)
+}
+
+func (r *recordingRegisterer) Unregister(prometheus.Collector) bool {
+	return false
+}
+
+// Describe returns the metrics exposed by k8s-shredder, in registration order
+func Describe() ([]MetricInfo, error) {
+	r := &recordingRegisterer{}
+	if err := registerMetrics(r); err != nil {
+		return nil, err
+	}
+
+	infos := make([]MetricInfo, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		descs := make(chan *prometheus.Desc, 1)
+		go func() {
+			c.Describe(descs)
+			close(descs)
+		}()
+		for desc := range descs {
+			info, err := describe(desc, c)
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
+
+func describe(desc *prometheus.Desc, c prometheus.Collector) (MetricInfo, error) {
+	match := descPattern.FindStringSubmatch(desc.String())
+	if match == nil {
+		return MetricInfo{}, errors.Errorf("unexpected metric description %s", desc.String())
+	}
+
+	info := MetricInfo{Type: metricType(c)}
+	var err error
+	if info.Name, err = strconv.Unquote(match[1]); err != nil {
+		return MetricInfo{}, errors.Wrapf(err, "invalid metric name in %s", desc.String())
+	}
+	if info.Help, err = strconv.Unquote(match[2]); err != nil {
+		return MetricInfo{}, errors.Wrapf(err, "invalid metric help in %s", desc.String())
+	}
+	if match[3] != "" {
+		info.Labels = strings.Split(match[3], ",")
+	}
+	return info, nil
+}
+
+// metricType returns the Prometheus type of the metrics collected by c
+func metricType(c prometheus.Collector) string {
+	switch c.(type) {
+	case *prometheus.CounterVec:
+		return "counter"
+	case *prometheus.GaugeVec, prometheus.Gauge:
+		return "gauge"
+	case prometheus.Counter:
+		return "counter"
+	case *prometheus.HistogramVec, prometheus.Histogram:
+		return "histogram"
+	case *prometheus.SummaryVec:
+		return "summary"
+	default:
+		return "untyped"
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// nodeGaugeVecs holds all gauge vectors keyed by cluster and node_name that are garbage collected by ExpireNodeSeries
+	nodeGaugeVecs = []*prometheus.GaugeVec{
+		ShredderNodeForceToEvictTime,
+		ShredderNodeParkedDurationSeconds,
+		ShredderNodeDrainProgress,
+	}
+
+	// podGaugeVecs holds all gauge vectors keyed by cluster and pod that are rebuilt during every eviction loop
+	podGaugeVecs = []*prometheus.GaugeVec{
+		ShredderPodForceToEvictTime,
+		ShredderPodBlockedEvictions,
+		ShredderPodErrorsTotal,
+	}
+
+	nodeAgeMu sync.Mutex
+	// nodeAgeSeen holds, by cluster, the nodes whose age was exported by the last node lifetime detection
+	nodeAgeSeen = map[string]map[string]bool{}
+
+	nodeSeriesMu sync.Mutex
+	// nodeSeriesGen holds the eviction loop generation of each cluster
+	nodeSeriesGen = map[string]uint64{}
+	// nodeLastSeen holds, by cluster and node name, the generation during which a node was last observed
+	nodeLastSeen = map[string]map[string]uint64{}
+)
+
+// ObserveNode marks a node of a cluster as present during the current eviction loop
+func ObserveNode(cluster, nodeName string) {
+	nodeSeriesMu.Lock()
+	defer nodeSeriesMu.Unlock()
+
+	if nodeLastSeen[cluster] == nil {
+		nodeLastSeen[cluster] = map[string]uint64{}
+	}
+	nodeLastSeen[cluster][nodeName] = nodeSeriesGen[cluster]
+}
+
+// ExpireNodeSeries ends the current eviction loop of a cluster and deletes the series of all its nodes that were not
+// observed during it, so that unparked nodes stop exporting stale values right away and the cardinality of node_name
+// labels stays bounded while nodes churn. It must only be called when the parked nodes were successfully listed
+func ExpireNodeSeries(cluster string) int {
+	nodeSeriesMu.Lock()
+	defer nodeSeriesMu.Unlock()
+
+	expired := 0
+	for nodeName, lastSeen := range nodeLastSeen[cluster] {
+		if lastSeen == nodeSeriesGen[cluster] {
+			continue
+		}
+		for _, vec := range nodeGaugeVecs {
+			vec.DeletePartialMatch(prometheus.Labels{"cluster": cluster, "node_name": nodeName})
+		}
+		delete(nodeLastSeen[cluster], nodeName)
+		expired++
+	}
+	nodeSeriesGen[cluster]++
+
+	return expired
+}
+
+// ResetPodSeries deletes the per-pod series of a cluster at the beginning of its eviction loop
+func ResetPodSeries(cluster string) {
+	for _, vec := range podGaugeVecs {
+		vec.DeletePartialMatch(prometheus.Labels{"cluster": cluster})
+	}
+}
+
+// ObserveNodeAges exports the age of the nodes of a cluster listed by the node lifetime detection and deletes the series
+// of the nodes that were seen by the previous detection but are gone now. Unlike the parked node series, the ages cover
+// all the nodes, so they are expired against the node listing instead of the eviction loop. The number of expired series
+// is returned
+func ObserveNodeAges(cluster string, ages map[string]float64) int {
+	nodeAgeMu.Lock()
+	defer nodeAgeMu.Unlock()
+
+	expired := 0
+	for nodeName := range nodeAgeSeen[cluster] {
+		if _, found := ages[nodeName]; !found {
+			ShredderNodeAgeSeconds.DeleteLabelValues(cluster, nodeName)
+			expired++
+		}
+	}
+
+	seen := make(map[string]bool, len(ages))
+	for nodeName, age := range ages {
+		ShredderNodeAgeSeconds.WithLabelValues(cluster, nodeName).Set(age)
+		seen[nodeName] = true
+	}
+	nodeAgeSeen[cluster] = seen
+
+	return expired
+}
@@ -23,7 +23,7 @@ var (
 			Name: "shredder_apiserver_requests_total",
 			Help: "Total requests for Kubernetes API",
 		},
-		[]string{"verb", "resource", "status"},
+		[]string{"cluster", "verb", "resource", "status"},
 	)
 
 	// ShredderAPIServerRequestsDurationSeconds = Requests duration seconds for calling Kubernetes API
@@ -33,48 +33,91 @@ var (
 			Help:       "Requests duration when calling Kubernetes API",
 			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
 		},
-		[]string{"verb", "resource", "status"},
+		[]string{"cluster", "verb", "resource", "status"},
 	)
 
 	// ShredderLoopsTotal = Total loops
-	ShredderLoopsTotal = prometheus.NewCounter(
+	ShredderLoopsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "shredder_loops_total",
 			Help: "Total loops",
 		},
+		[]string{"cluster"},
 	)
 
 	// ShredderLoopsDurationSeconds = Loops duration in seconds
-	ShredderLoopsDurationSeconds = prometheus.NewSummary(
+	ShredderLoopsDurationSeconds = prometheus.NewSummaryVec(
 		prometheus.SummaryOpts{
 			Name:       "shredder_loops_duration_seconds",
 			Help:       "Loops duration in seconds",
 			Objectives: map[float64]float64{0.5: 1200, 0.9: 900, 0.99: 600},
 		},
+		[]string{"cluster"},
+	)
+
+	// ShredderParkedPodLabelingDurationSeconds = Time taken to update the parking labels of the pods of a node, in seconds
+	ShredderParkedPodLabelingDurationSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "shredder_parked_pod_labeling_duration_seconds",
+			Help:    "Time taken to update the parking labels of the pods of a node, in seconds",
+			Buckets: prometheus.ExponentialBuckets(0.1, 2, 10),
+		},
+		[]string{"cluster", "operation"},
+	)
+
+	// ShredderPodEvictionDurationSeconds = Time from eviction request to pod deletion observed, in seconds
+	ShredderPodEvictionDurationSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "shredder_pod_eviction_duration_seconds",
+			Help:    "Time from eviction request to pod deletion observed, in seconds",
+			Buckets: prometheus.ExponentialBuckets(5, 2, 10),
+		},
+		[]string{"cluster", "namespace"},
+	)
+
+	// ShredderLoopIntervalSeconds = Current interval between eviction loops in seconds
+	ShredderLoopIntervalSeconds = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shredder_loop_interval_seconds",
+			Help: "Current interval between eviction loops in seconds, stretched when loops are slow",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderLoopIntervalAdjustmentsTotal = Total times the interval between eviction loops was stretched
+	ShredderLoopIntervalAdjustmentsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_loop_interval_adjustments_total",
+			Help: "Total times the interval between eviction loops was stretched because a loop took too long",
+		},
+		[]string{"cluster"},
 	)
 
 	// ShredderProcessedNodesTotal = Total processed nodes
-	ShredderProcessedNodesTotal = prometheus.NewCounter(
+	ShredderProcessedNodesTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "shredder_processed_nodes_total",
 			Help: "Total processed nodes",
 		},
+		[]string{"cluster"},
 	)
 
 	// ShredderProcessedPodsTotal = Total processed pods
-	ShredderProcessedPodsTotal = prometheus.NewCounter(
+	ShredderProcessedPodsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "shredder_processed_pods_total",
 			Help: "Total processed pods",
 		},
+		[]string{"cluster"},
 	)
 
 	// ShredderErrorsTotal = Total errors
-	ShredderErrorsTotal = prometheus.NewCounter(
+	ShredderErrorsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "shredder_errors_total",
 			Help: "Total errors",
 		},
+		[]string{"cluster"},
 	)
 
 	// ShredderPodErrorsTotal = Total pod errors
@@ -83,7 +126,25 @@ var (
 			Name: "shredder_pod_errors_total",
 			Help: "Total pod errors per eviction loop",
 		},
-		[]string{"pod_name", "namespace", "reason", "action"},
+		[]string{"cluster", "pod_name", "namespace", "reason", "action"},
+	)
+
+	// ShredderPodBlockedEvictions = Consecutive evictions of a pod rejected with 429 Too Many Requests
+	ShredderPodBlockedEvictions = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shredder_pod_blocked_evictions",
+			Help: "Consecutive evictions of a pod rejected with 429 Too Many Requests",
+		},
+		[]string{"cluster", "pod_name", "namespace"},
+	)
+
+	// ShredderEvictionDeleteFallbacksTotal = Total pods deleted because their eviction kept being rejected
+	ShredderEvictionDeleteFallbacksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_eviction_delete_fallbacks_total",
+			Help: "Total pods deleted because their eviction kept being rejected with 429 Too Many Requests",
+		},
+		[]string{"cluster"},
 	)
 
 	// ShredderNodeForceToEvictTime = Time when the node will be forcibly evicted
@@ -92,7 +153,473 @@ var (
 			Name: "shredder_node_force_to_evict_time",
 			Help: "Time when the node will be forcibly evicted",
 		},
-		[]string{"node_name"},
+		[]string{"cluster", "node_name"},
+	)
+
+	// ShredderNodeDrainProgress = Share of the pods observed on a parked node which are gone
+	ShredderNodeDrainProgress = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shredder_node_drain_progress",
+			Help: "Share of the eligible pods observed on a parked node since it got parked which are gone, between 0 and 1",
+		},
+		[]string{"cluster", "node_name"},
+	)
+
+	// ShredderDetectorRunsTotal = Total detector runs
+	ShredderDetectorRunsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_detector_runs_total",
+			Help: "Total detector runs",
+		},
+		[]string{"cluster", "detector", "result"},
+	)
+
+	// ShredderDetectedNodes = Nodes found by a detector during its last run
+	ShredderDetectedNodes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shredder_detected_nodes",
+			Help: "Nodes found by a detector during its last run",
+		},
+		[]string{"cluster", "detector"},
+	)
+
+	// ShredderPendingRolloutRestarts = Controller objects waiting to be processed by the rollout restart goroutines
+	ShredderPendingRolloutRestarts = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shredder_pending_rollout_restarts",
+			Help: "Controller objects waiting to be processed by the rollout restart goroutines",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderVMILiveMigrationsTotal = Total KubeVirt VirtualMachineInstance live migrations triggered
+	ShredderVMILiveMigrationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_vmi_live_migrations_total",
+			Help: "Total KubeVirt VirtualMachineInstance live migrations triggered instead of evicting virt-launcher pods",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderDoNotDisruptPodsSkippedTotal = Total pods skipped because of the Karpenter do-not-disrupt annotation
+	ShredderDoNotDisruptPodsSkippedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_do_not_disrupt_pods_skipped_total",
+			Help: "Total pods skipped because they have the karpenter.sh/do-not-disrupt annotation set on true",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderSafeToEvictPodsSkippedTotal = Total pods skipped because of the cluster-autoscaler safe-to-evict annotation
+	ShredderSafeToEvictPodsSkippedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_safe_to_evict_pods_skipped_total",
+			Help: "Total pods skipped because they have the cluster-autoscaler.kubernetes.io/safe-to-evict annotation set on false",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderPausedRolloutEscalationsTotal = Total paused Argo Rollouts escalated according to their PausedRolloutPolicy
+	ShredderPausedRolloutEscalationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_paused_rollout_escalations_total",
+			Help: "Total paused Argo Rollouts resumed or notified once PausedRolloutEscalationThreshold was reached, by policy",
+		},
+		[]string{"cluster", "policy"},
+	)
+
+	// ShredderRolloutRestartsDeferredByCanaryTotal = Total rollout restarts deferred because of a canary in progress
+	ShredderRolloutRestartsDeferredByCanaryTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_rollout_restarts_deferred_by_canary_total",
+			Help: "Total pods skipped because the rollout restart of their controller object was deferred while it is going through a canary",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderJobEvictionsDeferredTotal = Total deferrals waiting for Jobs to finish
+	ShredderJobEvictionsDeferredTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_job_evictions_deferred_total",
+			Help: "Total pod evictions, or node expiries, deferred while waiting for Jobs to finish",
+		},
+		[]string{"cluster", "stage"},
+	)
+
+	// ShredderRolloutRestartsDeferredByHPATotal = Total rollout restarts deferred because of a scaling HorizontalPodAutoscaler
+	ShredderRolloutRestartsDeferredByHPATotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_rollout_restarts_deferred_by_hpa_total",
+			Help: "Total pods skipped because the rollout restart of their controller object was deferred while a HorizontalPodAutoscaler is scaling it",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderRolloutRestartsRevertedTotal = Total rollout restarts reverted by a GitOps tool
+	ShredderRolloutRestartsRevertedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_rollout_restarts_reverted_total",
+			Help: "Total rollout restarts whose restartedAt annotation was reverted, usually by a GitOps tool, and fell back to pod eviction",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderNodeConditionDetectedNodes = Nodes found in a bad condition during the last node condition detection
+	ShredderNodeConditionDetectedNodes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shredder_node_condition_detected_nodes",
+			Help: "Nodes found in a bad condition for long enough during the last node condition detection",
+		},
+		[]string{"cluster", "condition"},
+	)
+
+	// ShredderNodeAgeSeconds = Age of the nodes seen during the last node lifetime detection, in seconds
+	ShredderNodeAgeSeconds = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shredder_node_age_seconds",
+			Help: "Age of the nodes seen during the last node lifetime detection, in seconds",
+		},
+		[]string{"cluster", "node_name"},
+	)
+
+	// ShredderParkingHandshakeTimeoutsTotal = Total nodes parked without node-local agents acknowledging the handshake
+	ShredderParkingHandshakeTimeoutsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_parking_handshake_timeouts_total",
+			Help: "Total nodes parked after ParkingHandshakeTimeout without node-local agents acknowledging the handshake",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderForceEvictionsStaggeredTotal = Total expired parked nodes whose force eviction was staggered
+	ShredderForceEvictionsStaggeredTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_force_evictions_staggered_total",
+			Help: "Total times the force eviction of an expired parked node was deferred to stagger it after another node",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderParkingHooksTotal = Total calls of the parking hooks, by phase and outcome
+	ShredderParkingHooksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_parking_hooks_total",
+			Help: "Total calls of the pre-park and post-park hooks, by outcome: allowed, vetoed or error",
+		},
+		[]string{"cluster", "phase", "outcome"},
+	)
+
+	// ShredderOrphanedParkedPodsCleanedTotal = Total pods whose eviction deadline was removed as their node is not parked anymore
+	ShredderOrphanedParkedPodsCleanedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_orphaned_parked_pods_cleaned_total",
+			Help: "Total pods whose eviction deadline annotation was removed because their node is not parked anymore",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderPaused = Whether the mutating actions of k8s-shredder are paused
+	ShredderPaused = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "shredder_paused",
+			Help: "1 while the eviction loops and detectors are paused through the admin API, 0 otherwise",
+		},
+	)
+
+	// ShredderNodesParkedTotal = Total nodes parked by k8s-shredder
+	ShredderNodesParkedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_nodes_parked_total",
+			Help: "Total nodes parked by k8s-shredder",
+		},
+		[]string{"cluster", "source", "dry_run"},
+	)
+
+	// ShredderParkedNodesByState = Parked nodes in each lifecycle state
+	ShredderParkedNodesByState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shredder_parked_nodes_by_state",
+			Help: "Parked nodes in each lifecycle state",
+		},
+		[]string{"cluster", "state"},
+	)
+
+	// ShredderParkedCapacityCPUCores = CPU cores of the parked nodes, either their capacity or their allocatable
+	ShredderParkedCapacityCPUCores = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shredder_parked_capacity_cpu_cores",
+			Help: "CPU cores of the parked nodes, either their capacity or their allocatable",
+		},
+		[]string{"cluster", "kind"},
+	)
+
+	// ShredderParkedCapacityMemoryBytes = Memory bytes of the parked nodes, either their capacity or their allocatable
+	ShredderParkedCapacityMemoryBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shredder_parked_capacity_memory_bytes",
+			Help: "Memory bytes of the parked nodes, either their capacity or their allocatable",
+		},
+		[]string{"cluster", "kind"},
+	)
+
+	// ShredderLastLoopNodesProcessed = Parked nodes processed during the last eviction loop
+	ShredderLastLoopNodesProcessed = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shredder_last_loop_nodes_processed",
+			Help: "Parked nodes processed during the last eviction loop",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderLastLoopPodsEvicted = Pods evicted during the last eviction loop
+	ShredderLastLoopPodsEvicted = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shredder_last_loop_pods_evicted",
+			Help: "Pods evicted during the last eviction loop",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderLastLoopPodsForceDeleted = Pods deleted without the eviction API during the last eviction loop
+	ShredderLastLoopPodsForceDeleted = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shredder_last_loop_pods_force_deleted",
+			Help: "Pods deleted without the eviction API during the last eviction loop",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderLastLoopRolloutRestarts = Rollout restarts triggered during the last eviction loop
+	ShredderLastLoopRolloutRestarts = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shredder_last_loop_rollout_restarts",
+			Help: "Rollout restarts triggered during the last eviction loop",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderUpcomingForceEvictions = Pods force evicted within each time window as their parked node expires
+	ShredderUpcomingForceEvictions = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shredder_upcoming_force_evictions",
+			Help: "Pods force evicted within the next 1h, 6h or 24h as their parked node expires, the nodes already expired included",
+		},
+		[]string{"cluster", "within"},
+	)
+
+	// ShredderLastLoopErrors = Errors encountered during the last eviction loop
+	ShredderLastLoopErrors = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shredder_last_loop_errors",
+			Help: "Errors encountered during the last eviction loop",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderLastLoopDurationSeconds = Duration of the last eviction loop
+	ShredderLastLoopDurationSeconds = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shredder_last_loop_duration_seconds",
+			Help: "Duration of the last eviction loop",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderParkingDeferredByHeadroomTotal = Total nodes not parked to keep MinClusterHeadroomPercent free
+	ShredderParkingDeferredByHeadroomTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_parking_deferred_by_headroom_total",
+			Help: "Total nodes not parked because the remaining nodes would be left with less than MinClusterHeadroomPercent of free capacity",
+		},
+		[]string{"cluster", "source", "dry_run"},
+	)
+
+	// ShredderNodesSoftParkedTotal = Total nodes soft parked by k8s-shredder
+	ShredderNodesSoftParkedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_nodes_soft_parked_total",
+			Help: "Total nodes soft parked by k8s-shredder ahead of their parking",
+		},
+		[]string{"cluster", "source", "dry_run"},
+	)
+
+	// ShredderParkingTaintFallbacksTotal = Total parkings retried with ParkedNodeFallbackTaint
+	ShredderParkingTaintFallbacksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_parking_taint_fallbacks_total",
+			Help: "Total node parkings retried with ParkedNodeFallbackTaint because the APIServer rejected ParkedNodeTaint",
+		},
+		[]string{"cluster", "source", "dry_run"},
+	)
+
+	// ShredderParkingDeferredByWaveTotal = Total nodes not parked because the ongoing parking wave was full
+	ShredderParkingDeferredByWaveTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_parking_deferred_by_wave_total",
+			Help: "Total nodes not parked because ParkingWaveSize nodes were already parked during the ongoing wave",
+		},
+		[]string{"cluster", "source", "dry_run"},
+	)
+
+	// ShredderNodeLockContentionsTotal = Total nodes skipped because another component held their lock
+	ShredderNodeLockContentionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_node_lock_contentions_total",
+			Help: "Total nodes not parked or unparked because another component held their lock",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderParkingPartialFailuresTotal = Total nodes that could not be parked
+	ShredderParkingPartialFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_parking_partial_failures_total",
+			Help: "Total nodes that could not be parked while parking a set of nodes",
+		},
+		[]string{"cluster", "source", "dry_run"},
+	)
+
+	// ShredderParkingRetriesPending = Nodes that could not be parked, waiting to be retried
+	ShredderParkingRetriesPending = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shredder_parking_retries_pending",
+			Help: "Nodes that could not be parked, to be retried during the next eviction loops",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderUnschedulablePods = Pending pods the scheduler could not find a node for
+	ShredderUnschedulablePods = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shredder_unschedulable_pods",
+			Help: "Pending pods the scheduler could not find a node for, when EnableCapacityUnpark is set",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderCapacityUnparkedNodes = Nodes temporarily unparked to restore capacity
+	ShredderCapacityUnparkedNodes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shredder_capacity_unparked_nodes",
+			Help: "Nodes temporarily unparked to restore capacity",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderCapacityUnparksTotal = Total nodes unparked to restore capacity
+	ShredderCapacityUnparksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_capacity_unparks_total",
+			Help: "Total nodes temporarily unparked because pods could not be scheduled",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderCapacityReparksTotal = Total nodes parked again after being unparked to restore capacity
+	ShredderCapacityReparksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_capacity_reparks_total",
+			Help: "Total nodes parked again once pods could be scheduled after being unparked to restore capacity",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderBatchParkedNodes = Nodes still parked for each parking batch
+	ShredderBatchParkedNodes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shredder_batch_parked_nodes",
+			Help: "Nodes still parked for each parking batch",
+		},
+		[]string{"cluster", "batch"},
+	)
+
+	// ShredderNodesReparkedTotal = Total expired parked nodes parked again by the eviction safety check
+	ShredderNodesReparkedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_nodes_reparked_total",
+			Help: "Total expired parked nodes parked again by the eviction safety check",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderTaintEscalationsTotal = Total parked nodes whose taint effect was escalated to NoExecute
+	ShredderTaintEscalationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_taint_escalations_total",
+			Help: "Total parked nodes whose taint effect was escalated to NoExecute",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderNodesUnparkedTotal = Total nodes unparked by k8s-shredder after recovering
+	ShredderNodesUnparkedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_nodes_unparked_total",
+			Help: "Total nodes unparked by k8s-shredder after the reason they were parked for went away",
+		},
+		[]string{"cluster", "source", "dry_run"},
+	)
+
+	// ShredderParkingCooldownSkipsTotal = Total nodes not parked because they were unparked within ParkingCooldown
+	ShredderParkingCooldownSkipsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_parking_cooldown_skips_total",
+			Help: "Total nodes not parked because they were unparked within ParkingCooldown",
+		},
+		[]string{"cluster", "source"},
+	)
+
+	// ShredderProtectedNodesSkippedTotal = Total nodes skipped because they are protected
+	ShredderProtectedNodesSkippedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_protected_nodes_skipped_total",
+			Help: "Total nodes not parked or drained because they are excluded or carry a protected label",
+		},
+		[]string{"cluster"},
+	)
+
+	// ShredderAdmissionRequestsTotal = Total admission requests reviewed by the admission webhook
+	ShredderAdmissionRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shredder_admission_requests_total",
+			Help: "Total pod admission requests targeting nodes, reviewed by the admission webhook",
+		},
+		[]string{"result"},
+	)
+
+	// ShredderBuildInfo = Build information of the running k8s-shredder
+	ShredderBuildInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shredder_build_info",
+			Help: "Build information of the running k8s-shredder, always 1",
+		},
+		[]string{"version", "git_sha", "build_time"},
+	)
+
+	// ShredderConfigInfo = Key values of the configuration in use
+	ShredderConfigInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shredder_config_info",
+			Help: "Key values of the configuration in use, always 1",
+		},
+		[]string{"eviction_loop_interval", "parked_node_ttl", "rolling_restart_threshold", "max_parked_nodes", "detectors", "dry_run"},
+	)
+
+	// ShredderConfigLoadError = Whether the last configuration load failed
+	ShredderConfigLoadError = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "shredder_config_load_error",
+			Help: "Whether the last configuration load failed (1) or succeeded (0)",
+		},
+	)
+
+	// ShredderNodeParkedDurationSeconds = How long a parked node has been parked
+	ShredderNodeParkedDurationSeconds = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shredder_node_parked_duration_seconds",
+			Help: "How long a parked node has been parked",
+		},
+		[]string{"cluster", "node_name"},
 	)
 
 	// ShredderPodForceToEvictTime = Time when the pod will be forcibly evicted
@@ -101,6 +628,6 @@ var (
 			Name: "shredder_pod_force_to_evict_time",
 			Help: "Time when the pod will be forcibly evicted",
 		},
-		[]string{"pod_name", "namespace"},
+		[]string{"cluster", "pod_name", "namespace"},
 	)
 )
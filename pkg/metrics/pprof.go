@@ -0,0 +1,72 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const pprofPath = "/debug/pprof/"
+
+var (
+	pprofEnabled bool
+	pprofServer  *http.Server
+)
+
+// EnablePprof exposes the net/http/pprof endpoints under /debug/pprof/, on the metrics server when port is 0 or on a
+// dedicated server otherwise. It must be called before Init
+func EnablePprof(port int) {
+	pprofEnabled = true
+	if port == 0 {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(pprofPath, pprof.Index)
+	mux.HandleFunc(pprofPath+"cmdline", pprof.Cmdline)
+	mux.HandleFunc(pprofPath+"profile", pprof.Profile)
+	mux.HandleFunc(pprofPath+"symbol", pprof.Symbol)
+	mux.HandleFunc(pprofPath+"trace", pprof.Trace)
+
+	pprofServer = &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           mux,
+		ReadHeaderTimeout: 3 * time.Second,
+	}
+
+	go func() {
+		if err := pprofServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+}
+
+// withoutPprof hides the endpoints net/http/pprof registers on the default mux, unless they are served on the metrics port
+func withoutPprof(next http.Handler) http.Handler {
+	if pprofEnabled && pprofServer == nil {
+		return next
+	}
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if strings.HasPrefix(req.URL.Path, pprofPath) {
+			http.NotFound(res, req)
+			return
+		}
+		next.ServeHTTP(res, req)
+	})
+}
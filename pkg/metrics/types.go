@@ -12,19 +12,33 @@ governing permissions and limitations under the License.
 package metrics
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/pkg/errors"
+
 	log "github.com/sirupsen/logrus"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+var (
+	server *http.Server
+
+	readinessMu     sync.RWMutex
+	readinessErrors = map[string]error{}
+	readinessChecks = map[string]func() error{}
+)
+
 // Init ..
 func Init(port int) error {
-	if err := registerMetrics(); err != nil {
+	if err := registerMetrics(prometheus.DefaultRegisterer); err != nil {
 		return err
 	}
 	if err := serve(port); err != nil {
@@ -33,17 +47,76 @@ func Init(port int) error {
 	return nil
 }
 
-func registerMetrics() error {
-	prometheus.MustRegister(ShredderAPIServerRequestsTotal)
-	prometheus.MustRegister(ShredderAPIServerRequestsDurationSeconds)
-	prometheus.MustRegister(ShredderLoopsTotal)
-	prometheus.MustRegister(ShredderLoopsDurationSeconds)
-	prometheus.MustRegister(ShredderProcessedNodesTotal)
-	prometheus.MustRegister(ShredderProcessedPodsTotal)
-	prometheus.MustRegister(ShredderErrorsTotal)
-	prometheus.MustRegister(ShredderPodErrorsTotal)
-	prometheus.MustRegister(ShredderNodeForceToEvictTime)
-	prometheus.MustRegister(ShredderPodForceToEvictTime)
+// registerMetrics registers all the k8s-shredder metrics with r
+func registerMetrics(r prometheus.Registerer) error {
+	r.MustRegister(ShredderAPIServerRequestsTotal)
+	r.MustRegister(ShredderAPIServerRequestsDurationSeconds)
+	r.MustRegister(ShredderLoopsTotal)
+	r.MustRegister(ShredderLoopsDurationSeconds)
+	r.MustRegister(ShredderParkedPodLabelingDurationSeconds)
+	r.MustRegister(ShredderPodEvictionDurationSeconds)
+	r.MustRegister(ShredderLoopIntervalSeconds)
+	r.MustRegister(ShredderLoopIntervalAdjustmentsTotal)
+	r.MustRegister(ShredderProcessedNodesTotal)
+	r.MustRegister(ShredderProcessedPodsTotal)
+	r.MustRegister(ShredderErrorsTotal)
+	r.MustRegister(ShredderPodErrorsTotal)
+	r.MustRegister(ShredderPodBlockedEvictions)
+	r.MustRegister(ShredderEvictionDeleteFallbacksTotal)
+	r.MustRegister(ShredderNodeForceToEvictTime)
+	r.MustRegister(ShredderNodeParkedDurationSeconds)
+	r.MustRegister(ShredderNodeDrainProgress)
+	r.MustRegister(ShredderPodForceToEvictTime)
+	r.MustRegister(ShredderConfigLoadError)
+	r.MustRegister(ShredderBuildInfo)
+	r.MustRegister(ShredderConfigInfo)
+	r.MustRegister(ShredderProtectedNodesSkippedTotal)
+	r.MustRegister(ShredderParkingCooldownSkipsTotal)
+	r.MustRegister(ShredderDetectorRunsTotal)
+	r.MustRegister(ShredderDetectedNodes)
+	r.MustRegister(ShredderNodeConditionDetectedNodes)
+	r.MustRegister(ShredderNodeAgeSeconds)
+	r.MustRegister(ShredderPendingRolloutRestarts)
+	r.MustRegister(ShredderRolloutRestartsDeferredByHPATotal)
+	r.MustRegister(ShredderRolloutRestartsDeferredByCanaryTotal)
+	r.MustRegister(ShredderPausedRolloutEscalationsTotal)
+	r.MustRegister(ShredderJobEvictionsDeferredTotal)
+	r.MustRegister(ShredderDoNotDisruptPodsSkippedTotal)
+	r.MustRegister(ShredderSafeToEvictPodsSkippedTotal)
+	r.MustRegister(ShredderVMILiveMigrationsTotal)
+	r.MustRegister(ShredderRolloutRestartsRevertedTotal)
+	r.MustRegister(ShredderParkingHandshakeTimeoutsTotal)
+	r.MustRegister(ShredderNodesParkedTotal)
+	r.MustRegister(ShredderBatchParkedNodes)
+	r.MustRegister(ShredderLastLoopNodesProcessed)
+	r.MustRegister(ShredderLastLoopPodsEvicted)
+	r.MustRegister(ShredderLastLoopPodsForceDeleted)
+	r.MustRegister(ShredderLastLoopRolloutRestarts)
+	r.MustRegister(ShredderLastLoopErrors)
+	r.MustRegister(ShredderUpcomingForceEvictions)
+	r.MustRegister(ShredderLastLoopDurationSeconds)
+	r.MustRegister(ShredderParkingPartialFailuresTotal)
+	r.MustRegister(ShredderNodeLockContentionsTotal)
+	r.MustRegister(ShredderPaused)
+	r.MustRegister(ShredderOrphanedParkedPodsCleanedTotal)
+	r.MustRegister(ShredderParkingHooksTotal)
+	r.MustRegister(ShredderForceEvictionsStaggeredTotal)
+	r.MustRegister(ShredderParkingDeferredByHeadroomTotal)
+	r.MustRegister(ShredderParkingDeferredByWaveTotal)
+	r.MustRegister(ShredderNodesSoftParkedTotal)
+	r.MustRegister(ShredderParkingTaintFallbacksTotal)
+	r.MustRegister(ShredderParkingRetriesPending)
+	r.MustRegister(ShredderUnschedulablePods)
+	r.MustRegister(ShredderCapacityUnparkedNodes)
+	r.MustRegister(ShredderCapacityUnparksTotal)
+	r.MustRegister(ShredderCapacityReparksTotal)
+	r.MustRegister(ShredderParkedNodesByState)
+	r.MustRegister(ShredderParkedCapacityCPUCores)
+	r.MustRegister(ShredderParkedCapacityMemoryBytes)
+	r.MustRegister(ShredderNodesUnparkedTotal)
+	r.MustRegister(ShredderNodesReparkedTotal)
+	r.MustRegister(ShredderTaintEscalationsTotal)
+	r.MustRegister(ShredderAdmissionRequestsTotal)
 
 	return nil
 }
@@ -64,13 +137,91 @@ func serve(port int) error {
 		}
 	})
 
-	server := &http.Server{
+	http.HandleFunc("/readyz", func(res http.ResponseWriter, req *http.Request) {
+		failures := readinessFailures()
+		if len(failures) > 0 {
+			res.WriteHeader(http.StatusServiceUnavailable)
+			_, err := res.Write([]byte(strings.Join(failures, "\n")))
+			if err != nil {
+				log.Errorln("Error while replying to /readyz request:", err)
+			}
+			return
+		}
+
+		res.WriteHeader(200)
+		_, err := res.Write([]byte("OK"))
+		if err != nil {
+			log.Errorln("Error while replying to /readyz request:", err)
+		}
+	})
+
+	server = &http.Server{
 		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           withoutPprof(http.DefaultServeMux),
 		ReadHeaderTimeout: 3 * time.Second,
 	}
 
 	go func() {
-		log.Fatal(server.ListenAndServe(), nil)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
 	}()
 	return nil
 }
+
+// Shutdown gracefully stops the metrics server, allowing in-flight scrapes to complete so that the latest
+// metric values are collected before the process exits
+func Shutdown(ctx context.Context) error {
+	if pprofServer != nil {
+		if err := pprofServer.Shutdown(ctx); err != nil {
+			log.Warnln("Error while stopping the pprof server:", err)
+		}
+	}
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
+}
+
+// SetReadinessError marks the given check as failing, making /readyz return 503 until it is cleared with a nil error
+func SetReadinessError(check string, err error) {
+	readinessMu.Lock()
+	defer readinessMu.Unlock()
+
+	if err == nil {
+		delete(readinessErrors, check)
+		return
+	}
+	readinessErrors[check] = err
+}
+
+// RegisterReadinessCheck adds a check run on every /readyz request, replacing the one previously registered under
+// the same name. /readyz returns 503 while it fails
+func RegisterReadinessCheck(check string, fn func() error) {
+	readinessMu.Lock()
+	defer readinessMu.Unlock()
+
+	readinessChecks[check] = fn
+}
+
+func readinessFailures() []string {
+	readinessMu.RLock()
+	failures := make([]string, 0, len(readinessErrors))
+	for check, err := range readinessErrors {
+		failures = append(failures, fmt.Sprintf("%s: %s", check, err.Error()))
+	}
+	checks := make(map[string]func() error, len(readinessChecks))
+	for check, fn := range readinessChecks {
+		checks[check] = fn
+	}
+	readinessMu.RUnlock()
+
+	// the checks may call the APIServer, they must not hold the lock
+	for check, fn := range checks {
+		if err := fn(); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", check, err.Error()))
+		}
+	}
+	sort.Strings(failures)
+	return failures
+}
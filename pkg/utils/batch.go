@@ -0,0 +1,151 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package utils
+
+import (
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// BatchAbortReport summarizes what happened to the nodes of an aborted parking batch
+type BatchAbortReport struct {
+	// Unparked holds the nodes unparked as they did not expire yet
+	Unparked []string
+	// Expired holds the nodes left parked as they already expired, so they are likely drained
+	Expired []string
+	// NotParked holds the nodes which were not parked anymore
+	NotParked []string
+	// Failed holds the nodes which could not be updated
+	Failed []string
+}
+
+// AbortBatch unparks the nodes of a parking batch which did not expire yet and labels all of them with the
+// ParkingBatchAbortedLabel, so that no other node gets parked for that batch
+func AbortBatch(appContext *AppContext, batch string) (*BatchAbortReport, error) {
+	cfg := *appContext.Config()
+	logger := log.WithFields(log.Fields{"batch": batch, "dryRun": appContext.IsDryRun()})
+
+	nodes, err := appContext.ListNodes(appContext.Context, appContext.K8sClient, metav1.ListOptions{
+		LabelSelector: labels.Set{cfg.ParkingBatchLabel: batch}.String(),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to list the nodes of batch %s", batch)
+	}
+
+	report := &BatchAbortReport{}
+	for _, listed := range nodes {
+		nodeLogger := logger.WithField("node", listed.Name)
+
+		var outcome *[]string
+		err := RetryAPICall(appContext, func() error {
+			// read the node on every attempt, so that conflicts get resolved
+			node, err := appContext.K8sClient.CoreV1().Nodes().Get(appContext.Context, listed.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			outcome, err = abortBatchNode(appContext, node, report, nodeLogger)
+			return err
+		})
+		if err != nil {
+			nodeLogger.Errorf("Failed to abort batch on node: %s", err.Error())
+			metrics.ShredderErrorsTotal.WithLabelValues(appContext.Cluster).Inc()
+			report.Failed = append(report.Failed, listed.Name)
+			continue
+		}
+
+		*outcome = append(*outcome, listed.Name)
+	}
+
+	return report, nil
+}
+
+// abortBatchNode labels a node of an aborted batch with the ParkingBatchAbortedLabel, unparking it unless it is not
+// parked anymore or its parking expired. It returns the list of the report the node belongs to
+func abortBatchNode(appContext *AppContext, node *v1.Node, report *BatchAbortReport, logger *log.Entry) (*[]string, error) {
+	cfg := *appContext.Config()
+
+	outcome := &report.Unparked
+	switch {
+	case node.Labels[cfg.UpgradeStatusLabel] != cfg.UpgradeStatusParkedValue:
+		outcome = &report.NotParked
+	case isParkingExpired(*node, cfg.ExpiresOnLabel) || NodeHasTaint(*node, cfg.ToBeDeletedTaint):
+		outcome = &report.Expired
+	}
+	unpark := outcome == &report.Unparked
+
+	source := node.Labels[cfg.ParkingReasonLabel]
+	if unpark {
+		err := clearParking(node, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	node.Labels[cfg.ParkingBatchAbortedLabel] = "true"
+
+	var err error
+	if unpark {
+		err = updateUnparkedNode(appContext, node, source, logger)
+	} else if !appContext.IsDryRun() || appContext.ServerSideDryRun() {
+		_, err = appContext.K8sClient.CoreV1().Nodes().Update(appContext.Context, node, nodeUpdateOptions(appContext))
+	}
+	return outcome, err
+}
+
+// isParkingExpired reports whether the parked node reached its expiry time. Nodes with an invalid expiry are
+// considered expired, so that they are left alone
+func isParkingExpired(node v1.Node, expiresOnLabel string) bool {
+	expiresOn, err := GetParkedNodeExpiryTime(node, expiresOnLabel)
+	return err != nil || time.Now().UTC().After(expiresOn)
+}
+
+// skipAbortedBatches drops the nodes to park for a parking batch that was aborted
+func skipAbortedBatches(appContext *AppContext, nodes []NodeInfo, logger *log.Entry) ([]NodeInfo, error) {
+	aborted := map[string]bool{}
+
+	var filtered []NodeInfo
+	for _, nodeInfo := range nodes {
+		if nodeInfo.Batch == "" {
+			filtered = append(filtered, nodeInfo)
+			continue
+		}
+
+		isAborted, found := aborted[nodeInfo.Batch]
+		if !found {
+			nodeList, err := appContext.BackgroundK8sClient.CoreV1().Nodes().List(appContext.Context, metav1.ListOptions{
+				LabelSelector: labels.Set{
+					appContext.Config().ParkingBatchLabel:        nodeInfo.Batch,
+					appContext.Config().ParkingBatchAbortedLabel: "true",
+				}.String(),
+				Limit: 1,
+			})
+			if err != nil {
+				return nil, errors.Wrapf(err, "Failed to check if batch %s was aborted", nodeInfo.Batch)
+			}
+			isAborted = len(nodeList.Items) > 0
+			aborted[nodeInfo.Batch] = isAborted
+		}
+
+		if isAborted {
+			logger.WithField("node", nodeInfo.Name).Warnf("Not parking node, batch %s was aborted", nodeInfo.Batch)
+			continue
+		}
+		filtered = append(filtered, nodeInfo)
+	}
+
+	return filtered, nil
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package utils
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// capacityUnparking is stored in the CapacityUnparkedAnnotation of the nodes temporarily unparked to restore capacity
+type capacityUnparking struct {
+	Reason     string    `json:"reason"`
+	UnparkedAt time.Time `json:"unparkedAt"`
+}
+
+// CountUnschedulablePods returns the number of pending pods the scheduler could not find a node for
+func CountUnschedulablePods(appContext *AppContext) (int, error) {
+	pods, err := ListPods(appContext.Context, appContext.BackgroundK8sClient, "", appContext.Config().APIListPageSize, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("status.phase", string(v1.PodPending)).String(),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, pod := range pods {
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == v1.PodScheduled && condition.Status == v1.ConditionFalse && condition.Reason == v1.PodReasonUnschedulable {
+				count++
+				break
+			}
+		}
+	}
+	return count, nil
+}
+
+// sortNewestParkedFirst sorts parked nodes from the most recently parked one. The parking time is derived from the
+// expiry time for the nodes missing the ParkedAtLabel, nodes with an invalid expiry are considered parked first
+func sortNewestParkedFirst(nodes []v1.Node, appContext *AppContext) {
+	cfg := *appContext.Config()
+	parkedAt := func(node v1.Node) time.Time {
+		if parkedAt, err := GetParkedNodeParkedTime(node, cfg.ParkedAtLabel); err == nil {
+			return parkedAt
+		}
+		expiresOn, err := GetParkedNodeExpiryTime(node, cfg.ExpiresOnLabel)
+		if err != nil {
+			return time.Time{}
+		}
+		return expiresOn.Add(-cfg.ParkedNodeTTLFor(node.Labels[cfg.ParkingReasonLabel]))
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return parkedAt(nodes[i]).After(parkedAt(nodes[j]))
+	})
+}
+
+// UnparkNodesForCapacity temporarily unparks up to count of the most recently parked nodes, recording the reason they
+// were parked for in their CapacityUnparkedAnnotation so that they are parked again by ReparkCapacityUnparkedNodes.
+// It returns the names of the unparked nodes
+func UnparkNodesForCapacity(appContext *AppContext, count int) ([]string, error) {
+	cfg := *appContext.Config()
+	logger := log.WithFields(log.Fields{"source": "capacity", "dryRun": appContext.IsDryRun()})
+
+	parkedNodes, err := appContext.ListNodes(appContext.Context, appContext.K8sClient, metav1.ListOptions{
+		LabelSelector: labels.Set{cfg.UpgradeStatusLabel: cfg.UpgradeStatusParkedValue}.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortNewestParkedFirst(parkedNodes, appContext)
+
+	var unparked []string
+	var errs []string
+	for _, node := range parkedNodes {
+		if len(unparked) == count {
+			break
+		}
+		// nodes being deleted by cluster-autoscaler don't bring any capacity back
+		if NodeHasTaint(node, cfg.ToBeDeletedTaint) || NodeIsProtected(node, cfg) {
+			continue
+		}
+
+		err := RetryAPICall(appContext, func() error {
+			return unparkNodeForCapacity(appContext, node.Name, logger.WithField("node", node.Name))
+		})
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		unparked = append(unparked, node.Name)
+	}
+
+	if len(errs) > 0 {
+		return unparked, errors.New(strings.Join(errs, "; "))
+	}
+	return unparked, nil
+}
+
+func unparkNodeForCapacity(appContext *AppContext, name string, logger *log.Entry) error {
+	cfg := *appContext.Config()
+
+	node, err := appContext.K8sClient.CoreV1().Nodes().Get(appContext.Context, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if node.Labels[cfg.UpgradeStatusLabel] != cfg.UpgradeStatusParkedValue {
+		return nil
+	}
+
+	source := node.Labels[cfg.ParkingReasonLabel]
+	unparking, _ := json.Marshal(capacityUnparking{Reason: source, UnparkedAt: time.Now().UTC()})
+	err = clearParking(node, cfg)
+	if err != nil {
+		return err
+	}
+	node.Annotations[cfg.CapacityUnparkedAnnotation] = string(unparking)
+
+	return updateUnparkedNode(appContext, node, source, logger)
+}
+
+// ReparkCapacityUnparkedNodes parks again, on behalf of the reason they were first parked for, the nodes unparked by
+// UnparkNodesForCapacity for at least minDuration. It returns the names of the nodes being parked again, the ones left
+// out by MaxParkedNodes being parked during a later call
+func ReparkCapacityUnparkedNodes(appContext *AppContext, minDuration time.Duration) ([]string, error) {
+	cfg := *appContext.Config()
+
+	nodes, err := appContext.ListNodes(appContext.Context, appContext.BackgroundK8sClient, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	byReason := map[string][]NodeInfo{}
+	var reasons []string
+	for _, node := range nodes {
+		value, found := node.Annotations[cfg.CapacityUnparkedAnnotation]
+		if !found {
+			continue
+		}
+
+		var unparking capacityUnparking
+		if err := json.Unmarshal([]byte(value), &unparking); err != nil {
+			log.WithField("node", node.Name).Warnf("Invalid %s annotation, parking the node again: %s", cfg.CapacityUnparkedAnnotation, err.Error())
+		} else if time.Since(unparking.UnparkedAt) < minDuration {
+			continue
+		}
+
+		nodeInfo := NewNodeInfo(node)
+		nodeInfo.capacityRepark = true
+		if _, found := byReason[unparking.Reason]; !found {
+			reasons = append(reasons, unparking.Reason)
+		}
+		byReason[unparking.Reason] = append(byReason[unparking.Reason], nodeInfo)
+	}
+
+	var reparked []string
+	var errs []string
+	for _, reason := range reasons {
+		if err := ParkNodes(appContext, byReason[reason], reason); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		for _, nodeInfo := range byReason[reason] {
+			reparked = append(reparked, nodeInfo.Name)
+		}
+	}
+
+	if len(errs) > 0 {
+		return reparked, errors.New(strings.Join(errs, "; "))
+	}
+	return reparked, nil
+}
+
+// CountCapacityUnparkedNodes returns the number of nodes currently unparked to restore capacity
+func CountCapacityUnparkedNodes(appContext *AppContext) (int, error) {
+	nodes, err := appContext.ListNodes(appContext.Context, appContext.BackgroundK8sClient, metav1.ListOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, node := range nodes {
+		if _, found := node.Annotations[appContext.Config().CapacityUnparkedAnnotation]; found {
+			count++
+		}
+	}
+	return count, nil
+}
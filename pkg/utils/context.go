@@ -14,46 +14,189 @@ package utils
 import (
 	"context"
 	"github.com/adobe/k8s-shredder/pkg/config"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sync/atomic"
 
 	"k8s.io/client-go/kubernetes"
 )
 
 // AppContext struct stores a context and a k8s client
 type AppContext struct {
-	Context          context.Context
-	K8sClient        kubernetes.Interface
-	DynamicK8SClient dynamic.Interface
-	Config           config.Config
-	dryRun           bool
+	// Context is used by the API calls, it is only cancelled once shutdown waited for the in-flight ones
+	Context context.Context
+	// Cluster is the name of the managed cluster, empty when k8s-shredder only manages the cluster it runs in
+	Cluster string
+	// K8sClient is used for the time-critical API calls, like evicting and deleting pods or parking nodes
+	K8sClient kubernetes.Interface
+	// BackgroundK8sClient has its own rate limiter and is used for the API calls that can wait, like detection and
+	// read-only API queries, so that they never starve the time-critical ones
+	BackgroundK8sClient kubernetes.Interface
+	DynamicK8SClient    dynamic.Interface
+	EventRecorder       record.EventRecorder
+	// NodeLister serves the nodes from a shared informer cache once StartNodeInformer was called, nil otherwise
+	NodeLister corelisters.NodeLister
+	// config is swapped on configuration reloads while API handlers read it, see Config
+	cfg       atomic.Pointer[config.Config]
+	dryRun    bool
+	lifecycle *lifecycle
 }
 
-// NewAppContext creates a new AppContext object
+// lifecycle holds what the AppContexts of all the managed clusters share to shut down
+type lifecycle struct {
+	// stop is cancelled on termination signals, no new work is started once it is done
+	stop context.Context
+	// cancel cancels the Context of the AppContexts
+	cancel context.CancelFunc
+}
+
+// NewAppContext creates a new AppContext object for the cluster k8s-shredder runs in, or the first of the configured
+// Clusters
 func NewAppContext(cfg config.Config, dryRun bool) (*AppContext, error) {
-	client, err := getK8SClient()
+	appContexts, err := NewAppContexts(cfg, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	return appContexts[0], nil
+}
+
+// NewAppContexts creates an AppContext object for each of the configured Clusters, or a single one for the cluster
+// k8s-shredder runs in when none is configured. They all share the same contexts: the stop one, cancelled on termination
+// signals, and the one of the API calls, cancelled by Cancel
+func NewAppContexts(cfg config.Config, dryRun bool) ([]*AppContext, error) {
+	clusters := cfg.Clusters
+	if len(clusters) == 0 {
+		clusters = []config.ClusterConfig{{}}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stop, stopCancel := context.WithCancel(context.Background())
+	shared := &lifecycle{stop: stop, cancel: cancel}
+
+	appContexts := make([]*AppContext, 0, len(clusters))
+	for _, cluster := range clusters {
+		appContext, err := newAppContext(ctx, cfg, cluster, dryRun)
+		if err != nil {
+			cancel()
+			stopCancel()
+			return nil, err
+		}
+		appContext.lifecycle = shared
+		appContexts = append(appContexts, appContext)
+	}
+
+	go HandleOsSignals(stopCancel)
+
+	return appContexts, nil
+}
+
+// newAppContext creates the AppContext object of a single cluster
+func newAppContext(ctx context.Context, cfg config.Config, cluster config.ClusterConfig, dryRun bool) (*AppContext, error) {
+	restConfig, err := getRestConfig(cluster)
 	if err != nil {
 		return nil, err
 	}
+	restConfig.Wrap(instrumentRoundTripper(cluster.Name))
 
-	dynamicClient, err := getDynamicK8SClient()
+	client, err := getK8SClient(restConfig, cfg.CriticalAPIQPS, cfg.CriticalAPIBurst)
 	if err != nil {
 		return nil, err
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	backgroundClient, err := getK8SClient(restConfig, cfg.BackgroundAPIQPS, cfg.BackgroundAPIBurst)
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := getDynamicK8SClient(restConfig)
+	if err != nil {
+		return nil, err
+	}
 
-	go HandleOsSignals(cancel)
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	recorder := broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "k8s-shredder"})
 
-	return &AppContext{
-		Context:          ctx,
-		K8sClient:        client,
-		DynamicK8SClient: dynamicClient,
-		Config:           cfg,
-		dryRun:           dryRun,
-	}, nil
+	appContext := &AppContext{
+		Context:             ctx,
+		Cluster:             cluster.Name,
+		K8sClient:           client,
+		BackgroundK8sClient: backgroundClient,
+		DynamicK8SClient:    dynamicClient,
+		EventRecorder:       recorder,
+		dryRun:              dryRun,
+	}
+	appContext.SetConfig(cfg)
+	return appContext, nil
+}
+
+// NewOfflineAppContext creates an AppContext without any client, only good for checking what the configuration enables
+func NewOfflineAppContext(cfg config.Config) *AppContext {
+	appContext := &AppContext{}
+	appContext.SetConfig(cfg)
+	return appContext
+}
+
+// Config returns the current configuration, safe to call while it is being reloaded. It is shared and must not be
+// modified, use SetConfig instead
+func (ac *AppContext) Config() *config.Config {
+	return ac.cfg.Load()
+}
+
+// SetConfig replaces the configuration
+func (ac *AppContext) SetConfig(cfg config.Config) {
+	ac.cfg.Store(&cfg)
+}
+
+// Stopping reports whether a termination signal was received, in which case no new work must be started
+func (ac *AppContext) Stopping() bool {
+	return ac.lifecycle != nil && ac.lifecycle.stop.Err() != nil
+}
+
+// StopRequested returns a channel closed once a termination signal was received
+func (ac *AppContext) StopRequested() <-chan struct{} {
+	if ac.lifecycle == nil {
+		return nil
+	}
+	return ac.lifecycle.stop.Done()
+}
+
+// Cancel cancels Context, aborting the API calls still in flight
+func (ac *AppContext) Cancel() {
+	if ac.lifecycle != nil {
+		ac.lifecycle.cancel()
+	}
 }
 
 // IsDryRun returns true if the "--dry-run" flag was provided
 func (ac *AppContext) IsDryRun() bool {
 	return ac.dryRun
 }
+
+// ServerSideDryRun reports whether the parking operations skipped in dry-run mode are sent to the API server as dry-run
+// requests instead, see ServerSideDryRun in the configuration
+func (ac *AppContext) ServerSideDryRun() bool {
+	return ac.dryRun && ac.Config().ServerSideDryRun
+}
+
+// DryRunOption returns the DryRun option of the requests sent by the parking operations
+func (ac *AppContext) DryRunOption() []string {
+	if ac.ServerSideDryRun() {
+		return []string{metav1.DryRunAll}
+	}
+	return nil
+}
+
+// RecordEvent records a Kubernetes event for the given object, unless running in dry-run mode
+func (ac *AppContext) RecordEvent(object runtime.Object, eventType, reason, message string) {
+	if ac.dryRun || ac.EventRecorder == nil {
+		return
+	}
+	ac.EventRecorder.Event(object, eventType, reason, message)
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LimitNodesToHeadroom returns the nodes that can be parked on behalf of source while keeping MinClusterHeadroomPercent of the schedulable
+// allocatable CPU and memory free once their pods are rescheduled. Nodes are taken in order, the ones that don't fit are
+// left for the next loops. Like the simulate command, aggregated requests are compared, ignoring fragmentation and
+// scheduling constraints
+func LimitNodesToHeadroom(appContext *AppContext, nodes []NodeInfo, source string) ([]NodeInfo, error) {
+	cfg := *appContext.Config()
+	if len(nodes) == 0 || cfg.MinClusterHeadroomPercent <= 0 {
+		return nodes, nil
+	}
+
+	allNodes, err := appContext.ListNodes(appContext.Context, appContext.BackgroundK8sClient, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := ListPods(appContext.Context, appContext.BackgroundK8sClient, "", cfg.APIListPageSize, metav1.ListOptions{
+		FieldSelector: "status.phase!=Succeeded,status.phase!=Failed",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	allocatable := v1.ResourceList{}
+	requested := v1.ResourceList{}
+	schedulable := map[string]v1.ResourceList{}
+	for _, node := range allNodes {
+		if node.Spec.Unschedulable || !NodeReady(node) || node.Labels[cfg.UpgradeStatusLabel] == cfg.UpgradeStatusParkedValue {
+			continue
+		}
+		schedulable[node.Name] = node.Status.Allocatable
+		AddResources(allocatable, node.Status.Allocatable)
+	}
+
+	// DaemonSet and static pods go away with their node instead of being rescheduled
+	pinned := map[string]v1.ResourceList{}
+	for _, pod := range pods {
+		if _, found := schedulable[pod.Spec.NodeName]; !found {
+			continue
+		}
+		requests := PodRequests(pod)
+		AddResources(requested, requests)
+		if PodIsDaemonSetOrStatic(pod) {
+			if pinned[pod.Spec.NodeName] == nil {
+				pinned[pod.Spec.NodeName] = v1.ResourceList{}
+			}
+			AddResources(pinned[pod.Spec.NodeName], requests)
+		}
+	}
+
+	limited := make([]NodeInfo, 0, len(nodes))
+	for _, nodeInfo := range nodes {
+		nodeAllocatable, found := schedulable[nodeInfo.Name]
+		if !found {
+			// already parked or cordoned, it does not count in the capacity
+			limited = append(limited, nodeInfo)
+			continue
+		}
+
+		remainingAllocatable := allocatable.DeepCopy()
+		SubtractResources(remainingAllocatable, nodeAllocatable)
+		remainingRequested := requested.DeepCopy()
+		SubtractResources(remainingRequested, pinned[nodeInfo.Name])
+
+		if short := headroomShortages(remainingAllocatable, remainingRequested, cfg.MinClusterHeadroomPercent); len(short) > 0 {
+			log.WithField("node", nodeInfo.Name).Infof("Not parking node, the cluster would be left with %s, below MinClusterHeadroomPercent=%v",
+				strings.Join(short, ", "), cfg.MinClusterHeadroomPercent)
+			metrics.ShredderParkingDeferredByHeadroomTotal.WithLabelValues(appContext.Cluster, source, dryRunLabel(appContext)).Inc()
+			continue
+		}
+
+		limited = append(limited, nodeInfo)
+		allocatable, requested = remainingAllocatable, remainingRequested
+		delete(schedulable, nodeInfo.Name)
+	}
+
+	return limited, nil
+}
+
+// headroomShortages describes the CapacityResources whose free share of allocatable is below minPercent
+func headroomShortages(allocatable, requested v1.ResourceList, minPercent float64) []string {
+	var short []string
+	for _, name := range CapacityResources {
+		total := allocatable[name]
+		used := requested[name]
+		headroom := 0.0
+		if total.MilliValue() > 0 {
+			headroom = float64(total.MilliValue()-used.MilliValue()) / float64(total.MilliValue()) * 100
+		}
+		if headroom < minPercent {
+			short = append(short, fmt.Sprintf("%.1f%% of %s free", headroom, name))
+		}
+	}
+	return short
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/adobe/k8s-shredder/pkg/config"
+	"golang.org/x/exp/slices"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func resources(cpu, memory string) v1.ResourceList {
+	return v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu), v1.ResourceMemory: resource.MustParse(memory)}
+}
+
+func TestHeadroomShortages(t *testing.T) {
+	tests := []struct {
+		name        string
+		allocatable v1.ResourceList
+		requested   v1.ResourceList
+		minPercent  float64
+		want        []string
+	}{
+		{
+			name:        "enough headroom",
+			allocatable: resources("10", "10Gi"),
+			requested:   resources("5", "5Gi"),
+			minPercent:  20,
+		},
+		{
+			name:        "exactly the minimum headroom",
+			allocatable: resources("10", "10Gi"),
+			requested:   resources("8", "8Gi"),
+			minPercent:  20,
+		},
+		{
+			name:        "cpu short",
+			allocatable: resources("10", "10Gi"),
+			requested:   resources("9", "5Gi"),
+			minPercent:  20,
+			want:        []string{"10.0% of cpu free"},
+		},
+		{
+			name:        "cpu and memory short",
+			allocatable: resources("10", "10Gi"),
+			requested:   resources("9500m", "9Gi"),
+			minPercent:  20,
+			want:        []string{"5.0% of cpu free", "10.0% of memory free"},
+		},
+		{
+			name:        "overcommitted",
+			allocatable: resources("10", "10Gi"),
+			requested:   resources("15", "5Gi"),
+			minPercent:  20,
+			want:        []string{"-50.0% of cpu free"},
+		},
+		{
+			name:        "no allocatable",
+			allocatable: v1.ResourceList{},
+			requested:   v1.ResourceList{},
+			minPercent:  20,
+			want:        []string{"0.0% of cpu free", "0.0% of memory free"},
+		},
+		{
+			name:        "missing requests",
+			allocatable: resources("10", "10Gi"),
+			requested:   v1.ResourceList{},
+			minPercent:  100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := headroomShortages(tt.allocatable, tt.requested, tt.minPercent)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("headroomShortages() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimitNodesToHeadroom(t *testing.T) {
+	node := func(name string, modify ...func(*v1.Node)) *v1.Node {
+		n := &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: v1.NodeStatus{
+				Allocatable: resources("4", "8Gi"),
+				Conditions:  []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}},
+			},
+		}
+		for _, m := range modify {
+			m(n)
+		}
+		return n
+	}
+	pod := func(name, nodeName, cpu string, modify ...func(*v1.Pod)) *v1.Pod {
+		p := &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: v1.PodSpec{
+				NodeName:   nodeName,
+				Containers: []v1.Container{{Name: "app", Resources: v1.ResourceRequirements{Requests: resources(cpu, "1Gi")}}},
+			},
+			Status: v1.PodStatus{Phase: v1.PodRunning},
+		}
+		for _, m := range modify {
+			m(p)
+		}
+		return p
+	}
+	daemonSet := func(p *v1.Pod) {
+		p.OwnerReferences = []metav1.OwnerReference{{Kind: "DaemonSet", Name: "agent"}}
+	}
+
+	tests := []struct {
+		name       string
+		minPercent float64
+		objects    []runtime.Object
+		nodes      []string
+		want       []string
+	}{
+		{
+			name:       "headroom disabled",
+			minPercent: 0,
+			objects:    []runtime.Object{node("a"), pod("p", "a", "4")},
+			nodes:      []string{"a"},
+			want:       []string{"a"},
+		},
+		{
+			name:       "nodes parked until the headroom runs out",
+			minPercent: 20,
+			objects: []runtime.Object{
+				node("a"), node("b"), node("c"),
+				pod("pa", "a", "1"), pod("pb", "b", "1"), pod("pc", "c", "1"),
+			},
+			nodes: []string{"a", "b", "c"},
+			want:  []string{"a", "b"},
+		},
+		{
+			name:       "no headroom left for any node",
+			minPercent: 20,
+			objects: []runtime.Object{
+				node("a"), node("b"), node("c"),
+				pod("pa", "a", "3"), pod("pb", "b", "3"), pod("pc", "c", "3"),
+			},
+			nodes: []string{"a", "b"},
+			want:  []string{},
+		},
+		{
+			name:       "DaemonSet pods are not rescheduled",
+			minPercent: 20,
+			objects: []runtime.Object{
+				node("a"), node("b"),
+				pod("pa", "a", "2", daemonSet), pod("pb", "b", "1"),
+			},
+			nodes: []string{"a"},
+			want:  []string{"a"},
+		},
+		{
+			name:       "cordoned nodes don't count in the capacity",
+			minPercent: 20,
+			objects: []runtime.Object{
+				node("a"), node("b", func(n *v1.Node) { n.Spec.Unschedulable = true }),
+				pod("pa", "a", "1"),
+			},
+			nodes: []string{"b", "a"},
+			want:  []string{"b"},
+		},
+		{
+			name:       "not ready nodes don't count in the capacity",
+			minPercent: 20,
+			objects: []runtime.Object{
+				node("a"), node("b"),
+				node("c", func(n *v1.Node) { n.Status.Conditions[0].Status = v1.ConditionFalse }),
+				pod("pa", "a", "2"), pod("pb", "b", "2"),
+			},
+			nodes: []string{"a", "b"},
+			want:  []string{},
+		},
+		{
+			name:       "the pods of unschedulable nodes are ignored",
+			minPercent: 20,
+			objects: []runtime.Object{
+				node("a"), node("b"),
+				node("c", func(n *v1.Node) { n.Spec.Unschedulable = true }),
+				pod("pa", "a", "1"), pod("pc", "c", "4"),
+			},
+			nodes: []string{"a"},
+			want:  []string{"a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(tt.objects...)
+			appContext := &AppContext{Context: context.Background(), K8sClient: client, BackgroundK8sClient: client}
+			appContext.SetConfig(config.Config{
+				MinClusterHeadroomPercent: tt.minPercent,
+				UpgradeStatusLabel:        "shredder.ethos.adobe.net/upgrade-status",
+				UpgradeStatusParkedValue:  "parked",
+			})
+
+			var nodes []NodeInfo
+			for _, name := range tt.nodes {
+				nodes = append(nodes, NodeInfo{Name: name})
+			}
+			limited, err := LimitNodesToHeadroom(appContext, nodes, "test")
+			if err != nil {
+				t.Fatalf("LimitNodesToHeadroom() error = %v", err)
+			}
+
+			got := []string{}
+			for _, nodeInfo := range limited {
+				got = append(got, nodeInfo.Name)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("LimitNodesToHeadroom() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/config"
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Parking hook phases
+const (
+	// ParkingHookPrePark is called before parking a node, which is vetoed by a 4xx response
+	ParkingHookPrePark = "pre-park"
+	// ParkingHookPostPark is called once a node got parked
+	ParkingHookPostPark = "post-park"
+)
+
+// ParkingHookRequest is posted to PreParkHookURL and PostParkHookURL for every node parked
+type ParkingHookRequest struct {
+	Phase     string    `json:"phase"`
+	Node      string    `json:"node"`
+	Cluster   string    `json:"cluster,omitempty"`
+	Source    string    `json:"source"`
+	Batch     string    `json:"batch,omitempty"`
+	ExpiresOn time.Time `json:"expiresOn"`
+	DryRun    bool      `json:"dryRun"`
+}
+
+// errParkingVetoed is returned by callParkingHook when the pre-park hook refused the parking
+var errParkingVetoed = errors.New("parking vetoed by the pre-park hook")
+
+// prePark calls PreParkHookURL, returning errParkingVetoed when the hook answered with a 4xx status. Other failures,
+// including timeouts, only prevent the parking with the `fail` PreParkHookFailurePolicy
+func prePark(appContext *AppContext, request ParkingHookRequest, logger *log.Entry) error {
+	cfg := *appContext.Config()
+	if cfg.PreParkHookURL == "" || !callsParkingHooks(appContext) {
+		return nil
+	}
+
+	request.Phase = ParkingHookPrePark
+	err := callParkingHook(appContext, cfg.PreParkHookURL, request)
+	switch {
+	case err == nil:
+		metrics.ShredderParkingHooksTotal.WithLabelValues(appContext.Cluster, ParkingHookPrePark, "allowed").Inc()
+		return nil
+	case errors.Is(err, errParkingVetoed):
+		metrics.ShredderParkingHooksTotal.WithLabelValues(appContext.Cluster, ParkingHookPrePark, "vetoed").Inc()
+		return err
+	case cfg.PreParkHookFailurePolicy == config.ParkingHookFailurePolicyFail:
+		metrics.ShredderParkingHooksTotal.WithLabelValues(appContext.Cluster, ParkingHookPrePark, "error").Inc()
+		return errors.Wrap(err, "Failed to call the pre-park hook")
+	default:
+		metrics.ShredderParkingHooksTotal.WithLabelValues(appContext.Cluster, ParkingHookPrePark, "error").Inc()
+		logger.Warnf("Failed to call the pre-park hook, parking the node anyway: %s", err.Error())
+		return nil
+	}
+}
+
+// postPark calls PostParkHookURL, its failures being logged only as the node is already parked
+func postPark(appContext *AppContext, request ParkingHookRequest, logger *log.Entry) {
+	if appContext.Config().PostParkHookURL == "" || !callsParkingHooks(appContext) {
+		return
+	}
+
+	request.Phase = ParkingHookPostPark
+	if err := callParkingHook(appContext, appContext.Config().PostParkHookURL, request); err != nil {
+		metrics.ShredderParkingHooksTotal.WithLabelValues(appContext.Cluster, ParkingHookPostPark, "error").Inc()
+		logger.Warnf("Failed to call the post-park hook: %s", err.Error())
+		return
+	}
+	metrics.ShredderParkingHooksTotal.WithLabelValues(appContext.Cluster, ParkingHookPostPark, "allowed").Inc()
+}
+
+// callsParkingHooks reports whether the parking hooks are called, which is not the case with the --dry-run flag alone, as
+// nothing reaches the API server then. With ServerSideDryRun, the hooks are told about it through the dryRun field
+func callsParkingHooks(appContext *AppContext) bool {
+	return !appContext.IsDryRun() || appContext.ServerSideDryRun()
+}
+
+// callParkingHook posts the request to url within ParkingHookTimeout
+func callParkingHook(appContext *AppContext, url string, request ParkingHookRequest) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(appContext.Context, appContext.Config().ParkingHookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode >= 400 && res.StatusCode < 500:
+		return errors.Wrapf(errParkingVetoed, "response status %s", res.Status)
+	case res.StatusCode >= 300:
+		return errors.Errorf("unexpected response status %s", res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// nodeInformerSyncTimeout is how long the initial list of the node informer can take
+const nodeInformerSyncTimeout = 2 * time.Minute
+
+// StartNodeInformer starts a node informer running until the application context is cancelled and waits for its cache
+// to be filled, so that ListNodes serves the nodes from it
+func (ac *AppContext) StartNodeInformer() error {
+	factory := informers.NewSharedInformerFactory(ac.BackgroundK8sClient, 0)
+	nodeInformer := factory.Core().V1().Nodes()
+	lister := nodeInformer.Lister()
+	informer := nodeInformer.Informer()
+
+	factory.Start(ac.Context.Done())
+
+	ctx, cancel := context.WithTimeout(ac.Context, nodeInformerSyncTimeout)
+	defer cancel()
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return errors.Errorf("node informer cache not synced within %s", nodeInformerSyncTimeout.String())
+	}
+
+	ac.NodeLister = lister
+	log.WithField("cluster", ac.Cluster).Info("Node informer cache synced")
+	return nil
+}
+
+// ListNodes returns the nodes matching the label and field selectors of opts, from the node informer cache when started,
+// otherwise from the APIServer using client. The cache is eventually consistent, the nodes updated moments ago may not
+// reflect the update yet. The returned nodes are copies that can be safely modified
+func (ac *AppContext) ListNodes(ctx context.Context, client kubernetes.Interface, opts metav1.ListOptions) ([]v1.Node, error) {
+	if ac.NodeLister == nil {
+		return ListNodes(ctx, client, ac.Config().APIListPageSize, opts)
+	}
+
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+	fieldSelector, err := fields.ParseSelector(opts.FieldSelector)
+	if err != nil {
+		return nil, err
+	}
+	// only the fields the APIServer supports for nodes can be matched against the cached nodes
+	for _, requirement := range fieldSelector.Requirements() {
+		if requirement.Field != "metadata.name" && requirement.Field != "spec.unschedulable" {
+			return nil, errors.Errorf("field selector %q is not supported by the node informer cache", requirement.Field)
+		}
+	}
+
+	cached, err := ac.NodeLister.List(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]v1.Node, 0, len(cached))
+	for _, node := range cached {
+		nodeFields := fields.Set{"metadata.name": node.Name, "spec.unschedulable": strconv.FormatBool(node.Spec.Unschedulable)}
+		if !fieldSelector.Matches(nodeFields) {
+			continue
+		}
+		nodes = append(nodes, *node.DeepCopy())
+	}
+	return nodes, nil
+}
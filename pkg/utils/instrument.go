@@ -0,0 +1,106 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package utils
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+)
+
+// instrumentedRoundTripper records the count and the latency of the requests sent to the APIServer of a cluster
+type instrumentedRoundTripper struct {
+	next    http.RoundTripper
+	cluster string
+}
+
+// instrumentRoundTripper returns a function wrapping the transport of the Kubernetes clients of a cluster, see
+// rest.Config.Wrap
+func instrumentRoundTripper(cluster string) func(http.RoundTripper) http.RoundTripper {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &instrumentedRoundTripper{next: next, cluster: cluster}
+	}
+}
+
+func (rt *instrumentedRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	res, err := rt.next.RoundTrip(req)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(res.StatusCode)
+	}
+	verb, resource := requestVerbAndResource(req)
+	metrics.ShredderAPIServerRequestsTotal.WithLabelValues(rt.cluster, verb, resource, status).Inc()
+	// watches last until the APIServer closes them, their duration says nothing about its latency
+	if verb != "watch" {
+		metrics.ShredderAPIServerRequestsDurationSeconds.WithLabelValues(rt.cluster, verb, resource, status).Observe(time.Since(start).Seconds())
+	}
+	return res, err
+}
+
+// requestVerbAndResource returns the Kubernetes verb of a request along with the resource it targets, including its
+// subresource if any, e.g. `create` and `pods/eviction`
+func requestVerbAndResource(req *http.Request) (string, string) {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+
+	// strip the /api/v1 or /apis/<group>/<version> prefix
+	switch {
+	case len(parts) >= 2 && parts[0] == "api":
+		parts = parts[2:]
+	case len(parts) >= 3 && parts[0] == "apis":
+		parts = parts[3:]
+	default:
+		return strings.ToLower(req.Method), "other"
+	}
+	if len(parts) == 0 {
+		return strings.ToLower(req.Method), "discovery"
+	}
+	// /namespaces/<namespace> is the namespace itself, anything below is a namespaced resource
+	if len(parts) > 2 && parts[0] == "namespaces" {
+		parts = parts[2:]
+	}
+
+	resource := parts[0]
+	named := len(parts) > 1
+	if len(parts) > 2 {
+		resource += "/" + parts[2]
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		switch {
+		case req.URL.Query().Get("watch") == "true":
+			return "watch", resource
+		case named:
+			return "get", resource
+		default:
+			return "list", resource
+		}
+	case http.MethodPost:
+		return "create", resource
+	case http.MethodPut:
+		return "update", resource
+	case http.MethodPatch:
+		return "patch", resource
+	case http.MethodDelete:
+		if named {
+			return "delete", resource
+		}
+		return "deletecollection", resource
+	default:
+		return strings.ToLower(req.Method), resource
+	}
+}
@@ -12,20 +12,49 @@ governing permissions and limitations under the License.
 package utils
 
 import (
+	"cmp"
+	"context"
+	"fmt"
+
+	shredderconfig "github.com/adobe/k8s-shredder/pkg/config"
 	"github.com/pkg/errors"
+	"golang.org/x/exp/slices"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"os"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"strconv"
+	"strings"
 	"time"
 )
 
-func getK8SClient() (*kubernetes.Clientset, error) {
-	cfg, err := config.GetConfig()
+// getRestConfig returns the configuration to reach the given cluster. The controller-runtime discovery (in-cluster
+// configuration, then kubeconfig) is used when neither a kubeconfig nor a context is set
+func getRestConfig(cluster shredderconfig.ClusterConfig) (*rest.Config, error) {
+	if cluster.Kubeconfig == "" && cluster.Context == "" {
+		return config.GetConfig()
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = cluster.Kubeconfig
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{
+		CurrentContext: cluster.Context,
+	}).ClientConfig()
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrapf(err, "failed to load the configuration of cluster %s", cluster.Name)
 	}
+	return restConfig, nil
+}
+
+// getK8SClient creates a client with its own client-side rate limiter allowing qps requests per second, with bursts of
+// up to burst requests
+func getK8SClient(restConfig *rest.Config, qps float32, burst int) (*kubernetes.Clientset, error) {
+	cfg := rest.CopyConfig(restConfig)
+	cfg.QPS = qps
+	cfg.Burst = burst
 
 	client, err := kubernetes.NewForConfig(cfg)
 	if err != nil {
@@ -35,14 +64,9 @@ func getK8SClient() (*kubernetes.Clientset, error) {
 	return client, nil
 }
 
-func getDynamicK8SClient() (*dynamic.DynamicClient, error) {
-	cfg, err := config.GetConfig()
-	if err != nil {
-		return nil, err
-	}
-
+func getDynamicK8SClient(restConfig *rest.Config) (*dynamic.DynamicClient, error) {
 	// Create a dynamic client
-	dynamicClient, err := dynamic.NewForConfig(cfg)
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
 	if err != nil {
 		return nil, errors.Errorf("Error creating dynamic client: %v", err)
 	}
@@ -70,6 +94,96 @@ func NodeHasLabel(node v1.Node, key string) bool {
 	return false
 }
 
+// NodeMatchesLabel check if a node matches a label spec, which is either a label key or a `key=value` pair
+func NodeMatchesLabel(node v1.Node, labelSpec string) bool {
+	key, value, hasValue := strings.Cut(labelSpec, "=")
+	nodeValue, ok := node.Labels[key]
+	if !ok {
+		return false
+	}
+	return !hasValue || nodeValue == value
+}
+
+// NodeIsProtected check if a node is excluded by name or carries any of the protected labels
+func NodeIsProtected(node v1.Node, cfg shredderconfig.Config) bool {
+	if slices.Contains(cfg.ExcludedNodeNames, node.Name) {
+		return true
+	}
+	for _, labelSpec := range cfg.ProtectedNodeLabels {
+		if NodeMatchesLabel(node, labelSpec) {
+			return true
+		}
+	}
+	return false
+}
+
+// PodIsDaemonSetOrStatic check if a pod is controlled by a DaemonSet or is a static pod
+func PodIsDaemonSetOrStatic(pod v1.Pod) bool {
+	return len(pod.OwnerReferences) > 0 && slices.Contains([]string{"DaemonSet", "Node"}, pod.OwnerReferences[0].Kind)
+}
+
+// PodMatchesLabel check if a pod matches a label spec, which is either a label key or a `key=value` pair
+func PodMatchesLabel(pod v1.Pod, labelSpec string) bool {
+	key, value, hasValue := strings.Cut(labelSpec, "=")
+	podValue, ok := pod.Labels[key]
+	if !ok {
+		return false
+	}
+	return !hasValue || podValue == value
+}
+
+// PodIsDaemonSet check if a pod is controlled by a DaemonSet
+func PodIsDaemonSet(pod v1.Pod) bool {
+	return len(pod.OwnerReferences) > 0 && pod.OwnerReferences[0].Kind == "DaemonSet"
+}
+
+// PodExclusionReason returns why a pod running on a parked node is not eligible for eviction, or an empty string when it
+// is. DaemonSet and static pods are always excluded, on top of the configured exclusions
+func PodExclusionReason(pod v1.Pod, cfg shredderconfig.Config) string {
+	if PodIsDaemonSetOrStatic(pod) {
+		return "it is part of a DaemonSet or is a static pod"
+	}
+	return PodConfiguredExclusionReason(pod, cfg)
+}
+
+// PodConfiguredExclusionReason returns why a pod is excluded from eviction by the configuration, or an empty string when
+// it is not
+func PodConfiguredExclusionReason(pod v1.Pod, cfg shredderconfig.Config) string {
+	if len(pod.OwnerReferences) > 0 && slices.Contains(cfg.ExcludedPodOwnerKinds, pod.OwnerReferences[0].Kind) {
+		return fmt.Sprintf("it is owned by a %s", pod.OwnerReferences[0].Kind)
+	}
+	if slices.Contains(cfg.ExcludedPodNamespaces, pod.Namespace) {
+		return fmt.Sprintf("its namespace %s is excluded", pod.Namespace)
+	}
+	for _, labelSpec := range cfg.ExcludedPodLabels {
+		if PodMatchesLabel(pod, labelSpec) {
+			return fmt.Sprintf("it has the excluded label %s", labelSpec)
+		}
+	}
+	if slices.Contains(cfg.ExcludedPodPhases, string(pod.Status.Phase)) {
+		return fmt.Sprintf("it is in the excluded %s phase", pod.Status.Phase)
+	}
+	if cfg.ActiveDeadlineMargin > 0 && pod.Spec.ActiveDeadlineSeconds != nil && pod.Status.StartTime != nil {
+		deadline := pod.Status.StartTime.Add(time.Duration(*pod.Spec.ActiveDeadlineSeconds) * time.Second)
+		if time.Until(deadline) < cfg.ActiveDeadlineMargin {
+			return fmt.Sprintf("its active deadline %s is less than %s away", deadline.UTC().Format(time.RFC3339), cfg.ActiveDeadlineMargin.String())
+		}
+	}
+	return ""
+}
+
+// GetCurrentNamespace returns the namespace k8s-shredder runs in, defaulting to kube-system when running outside a cluster
+func GetCurrentNamespace() string {
+	if namespace := os.Getenv("POD_NAMESPACE"); namespace != "" {
+		return namespace
+	}
+	namespace, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+	if err == nil && len(namespace) > 0 {
+		return strings.TrimSpace(string(namespace))
+	}
+	return "kube-system"
+}
+
 // PodEvictionAllowed check if a pod has the `skipEvictionLabel`=false label set
 func PodEvictionAllowed(pod v1.Pod, skipEvictionLabel string) bool {
 	if PodHasLabel(pod, skipEvictionLabel) {
@@ -90,11 +204,79 @@ func PodHasLabel(pod v1.Pod, key string) bool {
 	return false
 }
 
+// ScaleDownDisabledAnnotation is the node annotation preventing cluster-autoscaler from removing a node
+const ScaleDownDisabledAnnotation = "cluster-autoscaler.kubernetes.io/scale-down-disabled"
+
+// SafeToEvictAnnotation is the pod annotation preventing cluster-autoscaler from removing the node of a pod when set on false
+const SafeToEvictAnnotation = "cluster-autoscaler.kubernetes.io/safe-to-evict"
+
+// DoNotDisruptAnnotation is the pod annotation preventing Karpenter from voluntarily disrupting the node of a pod
+const DoNotDisruptAnnotation = "karpenter.sh/do-not-disrupt"
+
+// PodDeletionCostAnnotation is the standard annotation used by the ReplicaSet controller to pick the pods to delete first
+const PodDeletionCostAnnotation = "controller.kubernetes.io/pod-deletion-cost"
+
+// GetPodEvictionCost get the eviction cost of a pod from the override annotation, falling back to the standard
+// pod-deletion-cost annotation. Pods with a lower cost are evicted first, missing or invalid costs count as 0
+func GetPodEvictionCost(pod v1.Pod, overrideAnnotation string) int64 {
+	for _, annotation := range []string{overrideAnnotation, PodDeletionCostAnnotation} {
+		value, found := pod.Annotations[annotation]
+		if annotation == "" || !found {
+			continue
+		}
+		cost, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return 0
+		}
+		return cost
+	}
+	return 0
+}
+
+// SortPodsByEvictionCost sorts the pods by ascending eviction cost, keeping the original order of pods with the same cost
+func SortPodsByEvictionCost(pods []v1.Pod, overrideAnnotation string) {
+	slices.SortStableFunc(pods, func(a, b v1.Pod) int {
+		return cmp.Compare(GetPodEvictionCost(a, overrideAnnotation), GetPodEvictionCost(b, overrideAnnotation))
+	})
+}
+
 // GetParkedNodeExpiryTime get the time a parked node TTL expires
 func GetParkedNodeExpiryTime(node v1.Node, expiresOnLabel string) (time.Time, error) {
-	i, err := strconv.ParseFloat(node.Labels[expiresOnLabel], 64)
+	return getNodeTimeLabel(node, expiresOnLabel)
+}
+
+// GetParkedNodeParkedTime get the time a node was parked
+func GetParkedNodeParkedTime(node v1.Node, parkedAtLabel string) (time.Time, error) {
+	return getNodeTimeLabel(node, parkedAtLabel)
+}
+
+// getNodeTimeLabel parses a node label holding a Unix timestamp
+func getNodeTimeLabel(node v1.Node, label string) (time.Time, error) {
+	i, err := strconv.ParseFloat(node.Labels[label], 64)
 	if err != nil {
-		return time.Now().UTC(), errors.Errorf("Failed to parse label %s with value %s", expiresOnLabel, node.Labels[expiresOnLabel])
+		return time.Now().UTC(), errors.Errorf("Failed to parse label %s with value %s", label, node.Labels[label])
 	}
 	return time.Unix(int64(i), 0).UTC(), nil
 }
+
+// GetStatefulSetPodOrdinal get the ordinal of a pod managed by a StatefulSet, from its `<statefulset>-<ordinal>` name
+func GetStatefulSetPodOrdinal(pod v1.Pod, statefulSetName string) (int, error) {
+	suffix, found := strings.CutPrefix(pod.Name, statefulSetName+"-")
+	ordinal, err := strconv.Atoi(suffix)
+	if !found || err != nil {
+		return -1, errors.Errorf("Failed to get the ordinal of pod %s from StatefulSet %s", pod.Name, statefulSetName)
+	}
+	return ordinal, nil
+}
+
+// CheckAPIServer queries the /readyz endpoint of the APIServer, returning an error when it can't be reached within 5 seconds
+func (ac *AppContext) CheckAPIServer() error {
+	ctx, cancel := context.WithTimeout(ac.Context, 5*time.Second)
+	defer cancel()
+
+	err := ac.BackgroundK8sClient.Discovery().RESTClient().Get().AbsPath("/readyz").Do(ctx).Error()
+	if err != nil {
+		return errors.Wrap(err, "APIServer unreachable")
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/pager"
+)
+
+// ListNodes lists the nodes matching opts, pageSize nodes at a time so that large clusters are not fetched in a single
+// response. A pageSize of 0 disables pagination
+func ListNodes(ctx context.Context, client kubernetes.Interface, pageSize int64, opts metav1.ListOptions) ([]v1.Node, error) {
+	p := pager.New(pager.SimplePageFunc(func(opts metav1.ListOptions) (runtime.Object, error) {
+		return client.CoreV1().Nodes().List(ctx, opts)
+	}))
+	p.PageSize = pageSize
+
+	var nodes []v1.Node
+	err := p.EachListItem(ctx, opts, func(obj runtime.Object) error {
+		nodes = append(nodes, *obj.(*v1.Node))
+		return nil
+	})
+	return nodes, err
+}
+
+// ListPods lists the pods of a namespace matching opts, pageSize pods at a time so that large clusters are not fetched
+// in a single response. A pageSize of 0 disables pagination
+func ListPods(ctx context.Context, client kubernetes.Interface, namespace string, pageSize int64, opts metav1.ListOptions) ([]v1.Pod, error) {
+	p := pager.New(pager.SimplePageFunc(func(opts metav1.ListOptions) (runtime.Object, error) {
+		return client.CoreV1().Pods(namespace).List(ctx, opts)
+	}))
+	p.PageSize = pageSize
+
+	var pods []v1.Pod
+	err := p.EachListItem(ctx, opts, func(obj runtime.Object) error {
+		pods = append(pods, *obj.(*v1.Pod))
+		return nil
+	})
+	return pods, err
+}
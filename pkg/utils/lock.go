@@ -0,0 +1,125 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// nodeLock is the content of the NodeLockAnnotation, telling which component is working on a node
+type nodeLock struct {
+	Owner      string    `json:"owner"`
+	AcquiredAt time.Time `json:"acquiredAt"`
+}
+
+// NodeLockOwner identifies the k8s-shredder instance and the source, like a detector, taking a node lock
+func NodeLockOwner(source string) string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "k8s-shredder"
+	}
+	return hostname + "/" + source
+}
+
+// WithNodeLock runs fn while holding the NodeLockAnnotation lock of a node on behalf of owner. It returns false without
+// running fn when another owner holds a lock younger than NodeLockTTL. Locking is skipped in dry-run mode or when
+// NodeLockAnnotation is empty
+func WithNodeLock(appContext *AppContext, name, owner string, fn func() error) (bool, error) {
+	if appContext.Config().NodeLockAnnotation == "" || appContext.IsDryRun() {
+		return true, fn()
+	}
+
+	acquired := false
+	err := RetryAPICall(appContext, func() error {
+		var err error
+		acquired, err = acquireNodeLock(appContext, name, owner)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	if !acquired {
+		metrics.ShredderNodeLockContentionsTotal.WithLabelValues(appContext.Cluster).Inc()
+		return false, nil
+	}
+
+	defer func() {
+		err := RetryAPICall(appContext, func() error {
+			return releaseNodeLock(appContext, name, owner)
+		})
+		if err != nil {
+			log.WithField("node", name).Warnf("Failed to release node lock, it expires after %s: %s", appContext.Config().NodeLockTTL.String(), err.Error())
+		}
+	}()
+
+	return true, fn()
+}
+
+// acquireNodeLock sets the NodeLockAnnotation of a node, unless another owner holds a fresh lock. Concurrent attempts
+// are resolved by the resourceVersion of the node, the loser getting a conflict
+func acquireNodeLock(appContext *AppContext, name, owner string) (bool, error) {
+	cfg := *appContext.Config()
+
+	node, err := appContext.K8sClient.CoreV1().Nodes().Get(appContext.Context, name, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	var current nodeLock
+	if value, found := node.Annotations[cfg.NodeLockAnnotation]; found && json.Unmarshal([]byte(value), &current) == nil {
+		if current.Owner != owner && time.Since(current.AcquiredAt) < cfg.NodeLockTTL {
+			log.WithField("node", name).Debugf("Node is locked by %s since %s", current.Owner, current.AcquiredAt.Format(time.RFC3339))
+			return false, nil
+		}
+	}
+
+	value, err := json.Marshal(nodeLock{Owner: owner, AcquiredAt: time.Now().UTC()})
+	if err != nil {
+		return false, err
+	}
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+	node.Annotations[cfg.NodeLockAnnotation] = string(value)
+
+	_, err = appContext.K8sClient.CoreV1().Nodes().Update(appContext.Context, node, metav1.UpdateOptions{FieldManager: "k8s-shredder"})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// releaseNodeLock removes the NodeLockAnnotation of a node, as long as it is still held by owner
+func releaseNodeLock(appContext *AppContext, name, owner string) error {
+	cfg := *appContext.Config()
+
+	node, err := appContext.K8sClient.CoreV1().Nodes().Get(appContext.Context, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	var current nodeLock
+	value, found := node.Annotations[cfg.NodeLockAnnotation]
+	if !found || json.Unmarshal([]byte(value), &current) != nil || current.Owner != owner {
+		return nil
+	}
+
+	delete(node.Annotations, cfg.NodeLockAnnotation)
+	_, err = appContext.K8sClient.CoreV1().Nodes().Update(appContext.Context, node, metav1.UpdateOptions{FieldManager: "k8s-shredder"})
+	return err
+}
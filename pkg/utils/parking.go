@@ -0,0 +1,829 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/audit"
+	"github.com/adobe/k8s-shredder/pkg/config"
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// NodeInfo holds the details of a node selected for parking
+type NodeInfo struct {
+	Name   string
+	Labels map[string]string
+	// Zone is the availability zone of the node, taken from the topology.kubernetes.io/zone label
+	Zone string
+	// InstanceType is the instance type of the node, taken from the node.kubernetes.io/instance-type label
+	InstanceType string
+	// CreatedAt is the creation time of the node, used to park the oldest nodes first
+	CreatedAt time.Time
+	// Batch is the optional identifier of the rollout the node is parked for, stored in the ParkingBatchLabel
+	Batch string
+	// ReasonMessage is the human-readable explanation of why the node is parked, stored in the
+	// ParkingReasonMessageAnnotation along with the source
+	ReasonMessage string
+	// Urgent is set for the nodes about to go away anyway, which are parked right away, regardless of the parking limits
+	// and handshake
+	Urgent bool
+	// capacityRepark is set when parking again a node unparked to restore capacity
+	capacityRepark bool
+}
+
+// NewNodeInfo returns the NodeInfo of the given node, including its zone and instance type
+func NewNodeInfo(node v1.Node) NodeInfo {
+	return NodeInfo{
+		Name:         node.Name,
+		Labels:       node.Labels,
+		Zone:         node.Labels[v1.LabelTopologyZone],
+		InstanceType: node.Labels[v1.LabelInstanceTypeStable],
+		CreatedAt:    node.CreationTimestamp.Time,
+	}
+}
+
+// ParkingError is returned by ParkNodes when some of the nodes could not be parked
+type ParkingError struct {
+	Failed []NodeInfo
+}
+
+func (e *ParkingError) Error() string {
+	names := make([]string, 0, len(e.Failed))
+	for _, nodeInfo := range e.Failed {
+		names = append(names, nodeInfo.Name)
+	}
+	return fmt.Sprintf("Failed to park nodes %s", strings.Join(names, ", "))
+}
+
+// ParkNodes parks the given nodes on behalf of source: labels them as parked with an expiry time, cordons and taints them.
+// Already parked and protected nodes are skipped and the number of parked nodes is capped by MaxParkedNodes,
+// MaxParkedNodesPerZone and MaxParkedNodesBySource, then by ParkingWaveSize and MinClusterHeadroomPercent, except for
+// urgent nodes. The nodes
+// that could not be parked are returned in a ParkingError
+func ParkNodes(appContext *AppContext, nodes []NodeInfo, source string) error {
+	logger := log.WithFields(log.Fields{"source": source, "dryRun": appContext.IsDryRun()})
+
+	// holding back the parking of urgent nodes would only leave less time to drain them
+	var urgent, regular []NodeInfo
+	for _, nodeInfo := range nodes {
+		if nodeInfo.Urgent {
+			urgent = append(urgent, nodeInfo)
+		} else {
+			regular = append(regular, nodeInfo)
+		}
+	}
+
+	nodes, err := skipAbortedBatches(appContext, regular, logger)
+	if err != nil {
+		return err
+	}
+
+	nodes, err = LimitNodesToPark(appContext, nodes, source)
+	if err != nil {
+		return err
+	}
+
+	nodes, err = LimitNodesToWave(appContext, nodes, source)
+	if err != nil {
+		return err
+	}
+
+	nodes, err = LimitNodesToHeadroom(appContext, nodes, source)
+	if err != nil {
+		return err
+	}
+	nodes = append(urgent, nodes...)
+
+	var failed []NodeInfo
+	for _, nodeInfo := range nodes {
+		locked, err := WithNodeLock(appContext, nodeInfo.Name, NodeLockOwner(source), func() error {
+			return RetryAPICall(appContext, func() error {
+				return parkNode(appContext, nodeInfo, source, logger)
+			})
+		})
+		if err == nil && !locked {
+			logger.WithField("node", nodeInfo.Name).Info("Node is locked by another component, not parking it")
+			continue
+		}
+		if err != nil {
+			logger.WithField("node", nodeInfo.Name).Errorf("Failed to park node: %s", err.Error())
+			metrics.ShredderErrorsTotal.WithLabelValues(appContext.Cluster).Inc()
+			metrics.ShredderParkingPartialFailuresTotal.WithLabelValues(appContext.Cluster, source, dryRunLabel(appContext)).Inc()
+			failed = append(failed, nodeInfo)
+		}
+	}
+
+	if len(failed) > 0 {
+		return &ParkingError{Failed: failed}
+	}
+	return nil
+}
+
+func parkNode(appContext *AppContext, nodeInfo NodeInfo, source string, logger *log.Entry) error {
+	cfg := *appContext.Config()
+	logger = logger.WithField("node", nodeInfo.Name)
+
+	node, err := appContext.K8sClient.CoreV1().Nodes().Get(appContext.Context, nodeInfo.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if node.Labels[cfg.UpgradeStatusLabel] == cfg.UpgradeStatusParkedValue {
+		logger.Debug("Node is already parked")
+		return nil
+	}
+
+	if NodeIsProtected(*node, cfg) {
+		logger.Warn("Refusing to park protected node")
+		metrics.ShredderProtectedNodesSkippedTotal.WithLabelValues(appContext.Cluster).Inc()
+		return nil
+	}
+
+	// cluster-autoscaler is already draining and removing the node
+	if NodeHasTaint(*node, cfg.ToBeDeletedTaint) {
+		logger.Debugf("Node has the %s taint, not parking it", cfg.ToBeDeletedTaint)
+		return nil
+	}
+
+	if _, found := node.Annotations[cfg.CapacityUnparkedAnnotation]; found && !nodeInfo.capacityRepark {
+		logger.Debug("Node is temporarily unparked to restore capacity, not parking it")
+		return nil
+	}
+
+	if until := parkingCooldownEnd(*node, cfg); !nodeInfo.Urgent && !nodeInfo.capacityRepark && time.Now().UTC().Before(until) {
+		logger.Debugf("Node was unparked recently, not parking it again before %s", until.Format(time.RFC3339))
+		metrics.ShredderParkingCooldownSkipsTotal.WithLabelValues(appContext.Cluster, source).Inc()
+		return nil
+	}
+
+	taint, err := config.ParseTaint(cfg.ParkedNodeTaint)
+	if err != nil {
+		return err
+	}
+
+	if cfg.SoftParkingDelay > 0 && !nodeInfo.Urgent && !nodeInfo.capacityRepark {
+		if node.Labels[cfg.UpgradeStatusLabel] != cfg.UpgradeStatusSoftParkedValue {
+			return softParkNode(appContext, node, nodeInfo, source, taint, logger)
+		}
+		if until := softParkingEnd(*node, cfg); time.Now().UTC().Before(until) {
+			logger.Debugf("Node is soft parked, not parking it before %s", until.Format(time.RFC3339))
+			return nil
+		}
+	}
+
+	if cfg.ParkingHandshake && !nodeInfo.Urgent {
+		ready, err := parkingHandshake(appContext, node, logger)
+		if err != nil || !ready {
+			return err
+		}
+	}
+
+	parkedAt := time.Now().UTC()
+	expiresOn := parkedAt.Add(cfg.ParkedNodeTTLFor(source))
+
+	if node.Labels == nil {
+		node.Labels = map[string]string{}
+	}
+	node.Labels[cfg.UpgradeStatusLabel] = cfg.UpgradeStatusParkedValue
+	node.Labels[cfg.ExpiresOnLabel] = strconv.FormatInt(expiresOn.Unix(), 10)
+	node.Labels[cfg.ParkedAtLabel] = strconv.FormatInt(parkedAt.Unix(), 10)
+	node.Labels[cfg.ParkingReasonLabel] = source
+	if nodeInfo.Batch != "" {
+		node.Labels[cfg.ParkingBatchLabel] = nodeInfo.Batch
+	}
+	if cfg.ParkingReasonMessageAnnotation != "" {
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+		// soft parked nodes promoted without their detector keep the message of their soft parking
+		if _, found := node.Annotations[cfg.ParkingReasonMessageAnnotation]; !found || nodeInfo.ReasonMessage != "" {
+			node.Annotations[cfg.ParkingReasonMessageAnnotation] = parkingReasonMessage(source, nodeInfo.ReasonMessage)
+		}
+	}
+	delete(node.Annotations, cfg.ParkingHandshakeAnnotation)
+	delete(node.Annotations, cfg.ParkingHandshakeAckAnnotation)
+	delete(node.Annotations, cfg.CapacityUnparkedAnnotation)
+	if err := setNodeState(node, cfg, NodeStateParked); err != nil {
+		// the node was unparked without k8s-shredder, its lifecycle starts over
+		logger.Warnf("%s, resetting it", err.Error())
+		node.Annotations[cfg.NodeStateAnnotation] = string(NodeStateParked)
+	}
+	if cfg.EnableClusterAutoscalerScaleDown {
+		node.Annotations[ScaleDownDisabledAnnotation] = "false"
+	}
+	node.Spec.Unschedulable = true
+	// soft parked nodes have the ParkedNodeTaint, or its fallback, with the PreferNoSchedule effect
+	taints := node.Spec.Taints[:0]
+	for _, t := range node.Spec.Taints {
+		if !isParkedNodeTaint(t, cfg) || t.Effect != v1.TaintEffectPreferNoSchedule {
+			taints = append(taints, t)
+		}
+	}
+	node.Spec.Taints = taints
+	if !NodeHasTaint(*node, taint.Key) {
+		node.Spec.Taints = append(node.Spec.Taints, taint)
+	}
+
+	hookRequest := ParkingHookRequest{
+		Node:      node.Name,
+		Cluster:   appContext.Cluster,
+		Source:    source,
+		Batch:     nodeInfo.Batch,
+		ExpiresOn: expiresOn,
+		DryRun:    appContext.IsDryRun(),
+	}
+	if err := prePark(appContext, hookRequest, logger); err != nil {
+		if errors.Is(err, errParkingVetoed) {
+			// detectors find the node again during the next loops, asking the hook once more
+			logger.Infof("Not parking node: %s", err.Error())
+			return nil
+		}
+		return err
+	}
+
+	auditEntry := audit.Entry{Action: audit.ActionPark, Kind: "Node", Name: node.Name, Node: node.Name, DryRun: appContext.IsDryRun()}
+
+	if appContext.IsDryRun() && !appContext.ServerSideDryRun() {
+		logger.Infof("Would have parked node until %s", expiresOn.Format(time.RFC3339))
+		audit.Record(auditEntry, time.Now(), nil)
+		metrics.ShredderNodesParkedTotal.WithLabelValues(appContext.Cluster, source, dryRunLabel(appContext)).Inc()
+		postPark(appContext, hookRequest, logger)
+		return nil
+	}
+
+	start := time.Now()
+	err = updateParkingNode(appContext, node, taint, source, logger)
+	audit.Record(auditEntry, start, err)
+	if err != nil {
+		return err
+	}
+
+	if appContext.IsDryRun() {
+		logger.Infof("Would have parked node until %s, the API server accepted it", expiresOn.Format(time.RFC3339))
+	} else {
+		logger.Infof("Parked node until %s", expiresOn.Format(time.RFC3339))
+	}
+	if cfg.EnableParkedPodLabels {
+		labelParkedPods(appContext, node.Name, expiresOn, logger)
+	}
+	metrics.ShredderNodesParkedTotal.WithLabelValues(appContext.Cluster, source, dryRunLabel(appContext)).Inc()
+	postPark(appContext, hookRequest, logger)
+	return nil
+}
+
+// ParkedNodeTaintEscalated reports whether the ParkedNodeTaint, or ParkedNodeFallbackTaint, of the node already has the
+// NoExecute effect
+func ParkedNodeTaintEscalated(node v1.Node, cfg config.Config) bool {
+	for _, t := range node.Spec.Taints {
+		if isParkedNodeTaint(t, cfg) && t.Effect == v1.TaintEffectNoExecute {
+			return true
+		}
+	}
+	return false
+}
+
+// EscalateParkedNodeTaint switches the effect of the ParkedNodeTaint, or ParkedNodeFallbackTaint, of a parked node to
+// NoExecute, so that the kubelet evicts the pods not tolerating it ahead of the force eviction. Note that such evictions
+// don't honor PodDisruptionBudgets
+func EscalateParkedNodeTaint(appContext *AppContext, name string, logger *log.Entry) error {
+	node, err := appContext.K8sClient.CoreV1().Nodes().Get(appContext.Context, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	var taint *v1.Taint
+	for i := range node.Spec.Taints {
+		if isParkedNodeTaint(node.Spec.Taints[i], *appContext.Config()) && node.Spec.Taints[i].Effect != v1.TaintEffectNoExecute {
+			node.Spec.Taints[i].Effect = v1.TaintEffectNoExecute
+			taint = &node.Spec.Taints[i]
+		}
+	}
+	if taint == nil {
+		return nil
+	}
+
+	auditEntry := audit.Entry{Action: audit.ActionEscalateTaint, Kind: "Node", Name: node.Name, Node: node.Name, DryRun: appContext.IsDryRun()}
+
+	if appContext.IsDryRun() && !appContext.ServerSideDryRun() {
+		logger.Infof("Would have escalated the %s taint effect to %s", taint.Key, v1.TaintEffectNoExecute)
+		audit.Record(auditEntry, time.Now(), nil)
+		return nil
+	}
+
+	start := time.Now()
+	_, err = appContext.K8sClient.CoreV1().Nodes().Update(appContext.Context, node, nodeUpdateOptions(appContext))
+	audit.Record(auditEntry, start, err)
+	if err != nil {
+		return err
+	}
+	if appContext.IsDryRun() {
+		logger.Infof("Would have escalated the %s taint effect to %s, the API server accepted it", taint.Key, v1.TaintEffectNoExecute)
+		return nil
+	}
+
+	logger.Infof("Escalated the %s taint effect to %s", taint.Key, v1.TaintEffectNoExecute)
+	metrics.ShredderTaintEscalationsTotal.WithLabelValues(appContext.Cluster).Inc()
+	return nil
+}
+
+// ReparkNode parks an expired node again, resetting its TTL to ttl, and returns its new expiry time
+func ReparkNode(appContext *AppContext, name string, ttl time.Duration, logger *log.Entry) (time.Time, error) {
+	cfg := *appContext.Config()
+
+	node, err := appContext.K8sClient.CoreV1().Nodes().Get(appContext.Context, name, metav1.GetOptions{})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	parkedAt := time.Now().UTC()
+	expiresOn := parkedAt.Add(ttl)
+	node.Labels[cfg.ExpiresOnLabel] = strconv.FormatInt(expiresOn.Unix(), 10)
+	node.Labels[cfg.ParkedAtLabel] = strconv.FormatInt(parkedAt.Unix(), 10)
+	if err := setNodeState(node, cfg, NodeStateParked); err != nil {
+		return time.Time{}, err
+	}
+
+	auditEntry := audit.Entry{Action: audit.ActionRepark, Kind: "Node", Name: node.Name, Node: node.Name, DryRun: appContext.IsDryRun()}
+
+	if appContext.IsDryRun() && !appContext.ServerSideDryRun() {
+		logger.Infof("Would have parked the node again until %s", expiresOn.Format(time.RFC3339))
+		audit.Record(auditEntry, time.Now(), nil)
+		return expiresOn, nil
+	}
+
+	start := time.Now()
+	_, err = appContext.K8sClient.CoreV1().Nodes().Update(appContext.Context, node, nodeUpdateOptions(appContext))
+	audit.Record(auditEntry, start, err)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	metrics.ShredderNodesReparkedTotal.WithLabelValues(appContext.Cluster).Inc()
+	return expiresOn, nil
+}
+
+// UnparkNodes reverts the parking done on behalf of source for the given nodes, soft parked ones included: removes the
+// parking labels, the ParkedNodeTaint and uncordons them. Nodes parked for another reason, being deleted by cluster-autoscaler
+// or locked by another component are skipped. The names of the nodes actually unparked are returned
+func UnparkNodes(appContext *AppContext, nodes []NodeInfo, source string) ([]string, error) {
+	logger := log.WithFields(log.Fields{"source": source, "dryRun": appContext.IsDryRun()})
+
+	var unparked, failed []string
+	for _, nodeInfo := range nodes {
+		done := false
+		locked, err := WithNodeLock(appContext, nodeInfo.Name, NodeLockOwner(source), func() error {
+			return RetryAPICall(appContext, func() error {
+				var err error
+				done, err = unparkNode(appContext, nodeInfo, source, logger)
+				return err
+			})
+		})
+		if err == nil && !locked {
+			logger.WithField("node", nodeInfo.Name).Info("Node is locked by another component, not unparking it")
+			continue
+		}
+		if err != nil {
+			logger.WithField("node", nodeInfo.Name).Errorf("Failed to unpark node: %s", err.Error())
+			metrics.ShredderErrorsTotal.WithLabelValues(appContext.Cluster).Inc()
+			failed = append(failed, nodeInfo.Name)
+			continue
+		}
+		if done {
+			unparked = append(unparked, nodeInfo.Name)
+		}
+	}
+
+	if len(failed) > 0 {
+		return unparked, errors.Errorf("Failed to unpark nodes %s", strings.Join(failed, ", "))
+	}
+	return unparked, nil
+}
+
+// unparkNode unparks a single node parked on behalf of source, reporting whether it was unparked or skipped
+func unparkNode(appContext *AppContext, nodeInfo NodeInfo, source string, logger *log.Entry) (bool, error) {
+	cfg := *appContext.Config()
+	logger = logger.WithField("node", nodeInfo.Name)
+
+	node, err := appContext.K8sClient.CoreV1().Nodes().Get(appContext.Context, nodeInfo.Name, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	status := node.Labels[cfg.UpgradeStatusLabel]
+	if (status != cfg.UpgradeStatusParkedValue && status != cfg.UpgradeStatusSoftParkedValue) || node.Labels[cfg.ParkingReasonLabel] != source {
+		logger.Debug("Node is not parked on behalf of this source anymore")
+		return false, nil
+	}
+
+	if NodeHasTaint(*node, cfg.ToBeDeletedTaint) {
+		logger.Debugf("Node has the %s taint, leaving it parked", cfg.ToBeDeletedTaint)
+		return false, nil
+	}
+
+	err = clearParking(node, cfg)
+	if err != nil {
+		return false, err
+	}
+	delete(node.Labels, cfg.ParkingBatchLabel)
+
+	if err := updateUnparkedNode(appContext, node, source, logger); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// parkingReasonMessage returns the explanation of why a node got parked, as shown by `kubectl describe node`
+func parkingReasonMessage(source, message string) string {
+	if message == "" {
+		return fmt.Sprintf("Parked by %s", source)
+	}
+	return fmt.Sprintf("Parked by %s: %s", source, message)
+}
+
+// clearParking removes the parking labels, ParkedNodeTaint and ParkedNodeFallbackTaint from a node and uncordons it,
+// without updating it
+func clearParking(node *v1.Node, cfg config.Config) error {
+	if _, err := config.ParseTaint(cfg.ParkedNodeTaint); err != nil {
+		return err
+	}
+
+	if cfg.UpgradeStatusUnparkedValue != "" {
+		node.Labels[cfg.UpgradeStatusLabel] = cfg.UpgradeStatusUnparkedValue
+	} else {
+		delete(node.Labels, cfg.UpgradeStatusLabel)
+	}
+	delete(node.Labels, cfg.ExpiresOnLabel)
+	delete(node.Labels, cfg.ParkedAtLabel)
+	delete(node.Labels, cfg.ParkingReasonLabel)
+	delete(node.Annotations, cfg.ParkingReasonMessageAnnotation)
+	delete(node.Annotations, cfg.ForceEvictionTierAnnotation)
+	// every state can move to Unparked, including the missing one of nodes parked by older releases
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+	node.Annotations[cfg.NodeStateAnnotation] = string(NodeStateUnparked)
+	if cfg.UnparkedAtAnnotation != "" {
+		node.Annotations[cfg.UnparkedAtAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	}
+	node.Spec.Unschedulable = false
+	taints := node.Spec.Taints[:0]
+	for _, t := range node.Spec.Taints {
+		if !isParkedNodeTaint(t, cfg) {
+			taints = append(taints, t)
+		}
+	}
+	node.Spec.Taints = taints
+
+	return nil
+}
+
+// parkingCooldownEnd returns when a node unparked within ParkingCooldown can be parked again, the zero time when it can
+// be parked right away
+func parkingCooldownEnd(node v1.Node, cfg config.Config) time.Time {
+	if cfg.ParkingCooldown <= 0 {
+		return time.Time{}
+	}
+	unparkedAt, err := time.Parse(time.RFC3339, node.Annotations[cfg.UnparkedAtAnnotation])
+	if err != nil {
+		return time.Time{}
+	}
+	return unparkedAt.Add(cfg.ParkingCooldown)
+}
+
+// updateUnparkedNode updates a node whose parking, made on behalf of source, was cleared
+func updateUnparkedNode(appContext *AppContext, node *v1.Node, source string, logger *log.Entry) error {
+	auditEntry := audit.Entry{Action: audit.ActionUnpark, Kind: "Node", Name: node.Name, Node: node.Name, DryRun: appContext.IsDryRun()}
+
+	if appContext.IsDryRun() && !appContext.ServerSideDryRun() {
+		logger.Info("Would have unparked node")
+		audit.Record(auditEntry, time.Now(), nil)
+		metrics.ShredderNodesUnparkedTotal.WithLabelValues(appContext.Cluster, source, dryRunLabel(appContext)).Inc()
+		return nil
+	}
+
+	start := time.Now()
+	_, err := appContext.K8sClient.CoreV1().Nodes().Update(appContext.Context, node, nodeUpdateOptions(appContext))
+	audit.Record(auditEntry, start, err)
+	if err != nil {
+		return err
+	}
+
+	if appContext.IsDryRun() {
+		logger.Info("Would have unparked node, the API server accepted it")
+	} else {
+		logger.Info("Unparked node")
+	}
+	if appContext.Config().EnableParkedPodLabels {
+		unlabelParkedPods(appContext, node.Name, logger)
+	}
+	metrics.ShredderNodesUnparkedTotal.WithLabelValues(appContext.Cluster, source, dryRunLabel(appContext)).Inc()
+	return nil
+}
+
+// updateParkingNode updates a node being parked with taint. When the APIServer rejects the update, e.g. because an
+// admission policy restricts the taint keys, it is attempted again with ParkedNodeFallbackTaint, keeping the effect of
+// taint. Node updates are atomic: a rejected update leaves neither the labels nor the cordon behind, there is nothing to
+// roll back
+func updateParkingNode(appContext *AppContext, node *v1.Node, taint v1.Taint, source string, logger *log.Entry) error {
+	cfg := *appContext.Config()
+
+	_, err := appContext.K8sClient.CoreV1().Nodes().Update(appContext.Context, node, nodeUpdateOptions(appContext))
+	if err == nil || cfg.ParkedNodeFallbackTaint == "" || !(apierrors.IsForbidden(err) || apierrors.IsInvalid(err)) {
+		return err
+	}
+
+	fallback, parseErr := config.ParseTaint(cfg.ParkedNodeFallbackTaint)
+	if parseErr != nil {
+		return parseErr
+	}
+	fallback.Effect = taint.Effect
+	if fallback.Key == taint.Key && fallback.Value == taint.Value {
+		return err
+	}
+
+	logger.Warnf("Node update rejected with the %s taint, retrying with the %s fallback taint: %s", taint.Key, fallback.Key, err.Error())
+	metrics.ShredderParkingTaintFallbacksTotal.WithLabelValues(appContext.Cluster, source, dryRunLabel(appContext)).Inc()
+	for i := range node.Spec.Taints {
+		if node.Spec.Taints[i].Key == taint.Key && node.Spec.Taints[i].Effect == taint.Effect {
+			node.Spec.Taints[i] = fallback
+		}
+	}
+
+	_, err = appContext.K8sClient.CoreV1().Nodes().Update(appContext.Context, node, nodeUpdateOptions(appContext))
+	return err
+}
+
+// isParkedNodeTaint checks whether a taint is the ParkedNodeTaint or the ParkedNodeFallbackTaint, whatever its effect
+func isParkedNodeTaint(taint v1.Taint, cfg config.Config) bool {
+	for _, parkedNodeTaint := range []string{cfg.ParkedNodeTaint, cfg.ParkedNodeFallbackTaint} {
+		if parkedNodeTaint == "" {
+			continue
+		}
+		if t, err := config.ParseTaint(parkedNodeTaint); err == nil && t.Key == taint.Key {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeUpdateOptions returns the options of the node updates done while parking and unparking nodes, which are sent as
+// dry-run requests with ServerSideDryRun
+func nodeUpdateOptions(appContext *AppContext) metav1.UpdateOptions {
+	return metav1.UpdateOptions{FieldManager: "k8s-shredder", DryRun: appContext.DryRunOption()}
+}
+
+// dryRunLabel is the value of the dry_run label of the parking metrics
+func dryRunLabel(appContext *AppContext) string {
+	return strconv.FormatBool(appContext.IsDryRun())
+}
+
+// parkingHandshake gives node-local agents a chance to get ready before a node is parked. The node is first annotated
+// with ParkingHandshakeAnnotation and it is parked during a later eviction loop, once an agent acknowledged it with
+// ParkingHandshakeAckAnnotation or after ParkingHandshakeTimeout
+func parkingHandshake(appContext *AppContext, node *v1.Node, logger *log.Entry) (bool, error) {
+	cfg := *appContext.Config()
+
+	requestedAt, found := node.Annotations[cfg.ParkingHandshakeAnnotation]
+	if !found {
+		if appContext.IsDryRun() {
+			logger.Info("Would have asked node-local agents to prepare for parking")
+			return false, nil
+		}
+
+		patchData, _ := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"annotations": map[string]string{
+					cfg.ParkingHandshakeAnnotation: time.Now().UTC().Format(time.RFC3339),
+					cfg.NodeStateAnnotation:        string(NodeStateDetected),
+				},
+			},
+		})
+		_, err := appContext.K8sClient.CoreV1().Nodes().Patch(appContext.Context, node.Name, types.MergePatchType, patchData, metav1.PatchOptions{FieldManager: "k8s-shredder"})
+		if err != nil {
+			return false, errors.Wrapf(err, "Failed to annotate node %s with %s", node.Name, cfg.ParkingHandshakeAnnotation)
+		}
+
+		logger.Info("Asked node-local agents to prepare for parking")
+		return false, nil
+	}
+
+	if _, found := node.Annotations[cfg.ParkingHandshakeAckAnnotation]; found {
+		logger.Info("Node-local agents are ready for parking")
+		return true, nil
+	}
+
+	requestedAtTime, err := time.Parse(time.RFC3339, requestedAt)
+	if err != nil {
+		return false, errors.Wrapf(err, "Invalid %s annotation on node %s", cfg.ParkingHandshakeAnnotation, node.Name)
+	}
+
+	if time.Since(requestedAtTime) < cfg.ParkingHandshakeTimeout {
+		logger.Debugf("Waiting for node-local agents to acknowledge %s", cfg.ParkingHandshakeAnnotation)
+		return false, nil
+	}
+
+	logger.Warnf("Node-local agents did not acknowledge %s within %s, parking anyway", cfg.ParkingHandshakeAnnotation, cfg.ParkingHandshakeTimeout.String())
+	metrics.ShredderParkingHandshakeTimeoutsTotal.WithLabelValues(appContext.Cluster).Inc()
+	return true, nil
+}
+
+// LimitNodesToPark drops the nodes that would exceed MaxParkedNodes, MaxParkedNodesPerZone or the MaxParkedNodesBySource
+// limit of source once parked, taking into account the nodes that are already parked. Only the nodes parked on behalf of
+// source count towards its own limit. The oldest nodes are picked first, then by name so that the selection stays
+// stable between loops
+func LimitNodesToPark(appContext *AppContext, nodes []NodeInfo, source string) ([]NodeInfo, error) {
+	cfg := *appContext.Config()
+	_, sourceLimited := cfg.MaxParkedNodesBySource[source]
+	if len(nodes) == 0 || (cfg.MaxParkedNodes <= 0 && cfg.MaxParkedNodesPerZone == "" && !sourceLimited) {
+		return nodes, nil
+	}
+
+	allNodes, err := appContext.ListNodes(appContext.Context, appContext.BackgroundK8sClient, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	parked := 0
+	sourceParked := 0
+	zones := make(map[string]string, len(allNodes))
+	zoneTotal := make(map[string]int)
+	zoneParked := make(map[string]int)
+	parkedNodes := make(map[string]bool)
+	for _, node := range allNodes {
+		zone := node.Labels[v1.LabelTopologyZone]
+		zones[node.Name] = zone
+		zoneTotal[zone]++
+		if node.Labels[cfg.UpgradeStatusLabel] == cfg.UpgradeStatusParkedValue {
+			parked++
+			zoneParked[zone]++
+			parkedNodes[node.Name] = true
+			if node.Labels[cfg.ParkingReasonLabel] == source {
+				sourceParked++
+			}
+		}
+	}
+
+	available := -1
+	if cfg.MaxParkedNodes > 0 {
+		available = cfg.MaxParkedNodes - parked
+		if available <= 0 {
+			log.Infof("%d nodes already parked, MaxParkedNodes=%d reached, not parking any other node", parked, cfg.MaxParkedNodes)
+			return nil, nil
+		}
+	}
+
+	sourceAvailable := -1
+	if sourceLimited {
+		maxForSource, _, err := cfg.MaxParkedNodesForSource(source, len(allNodes))
+		if err != nil {
+			return nil, err
+		}
+		sourceAvailable = maxForSource - sourceParked
+		if sourceAvailable <= 0 {
+			log.WithField("source", source).Infof("%d nodes already parked on behalf of source, MaxParkedNodesBySource=%s reached, not parking any other node",
+				sourceParked, cfg.MaxParkedNodesBySource[source])
+			return nil, nil
+		}
+	}
+
+	sortOldestNodesFirst(nodes)
+
+	limited := make([]NodeInfo, 0, len(nodes))
+	for _, nodeInfo := range nodes {
+		if parkedNodes[nodeInfo.Name] {
+			// already parked, parkNode will skip it anyway
+			limited = append(limited, nodeInfo)
+			continue
+		}
+		if available == 0 {
+			log.WithField("node", nodeInfo.Name).Infof("MaxParkedNodes=%d reached, not parking node", cfg.MaxParkedNodes)
+			continue
+		}
+		if sourceAvailable == 0 {
+			log.WithFields(log.Fields{"node": nodeInfo.Name, "source": source}).
+				Infof("MaxParkedNodesBySource=%s reached, not parking node", cfg.MaxParkedNodesBySource[source])
+			continue
+		}
+
+		if zone, ok := zones[nodeInfo.Name]; ok && nodeInfo.Zone == "" {
+			nodeInfo.Zone = zone
+		}
+		if cfg.MaxParkedNodesPerZone != "" {
+			maxInZone, err := cfg.MaxParkedNodesInZone(zoneTotal[nodeInfo.Zone])
+			if err != nil {
+				return nil, err
+			}
+			if zoneParked[nodeInfo.Zone] >= maxInZone {
+				log.WithFields(log.Fields{"node": nodeInfo.Name, "zone": nodeInfo.Zone}).
+					Infof("%d nodes already parked in zone, MaxParkedNodesPerZone=%s reached, not parking node",
+						zoneParked[nodeInfo.Zone], cfg.MaxParkedNodesPerZone)
+				continue
+			}
+			zoneParked[nodeInfo.Zone]++
+		}
+
+		limited = append(limited, nodeInfo)
+		if available > 0 {
+			available--
+		}
+		if sourceAvailable > 0 {
+			sourceAvailable--
+		}
+	}
+
+	return limited, nil
+}
+
+// sortOldestNodesFirst sorts the nodes to park by creation time, then by name so that the selection stays stable
+// between loops
+func sortOldestNodesFirst(nodes []NodeInfo) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if !nodes[i].CreatedAt.Equal(nodes[j].CreatedAt) {
+			return nodes[i].CreatedAt.Before(nodes[j].CreatedAt)
+		}
+		return nodes[i].Name < nodes[j].Name
+	})
+}
+
+// EnforceMaxParkedNodes unparks the most recently parked nodes until no more than MaxParkedNodes are parked. Each node
+// is unparked on behalf of the source it was parked for. The names of the unparked nodes are returned
+func EnforceMaxParkedNodes(appContext *AppContext) ([]string, error) {
+	cfg := *appContext.Config()
+	if cfg.MaxParkedNodes <= 0 {
+		return nil, nil
+	}
+
+	parkedNodes, err := appContext.ListNodes(appContext.Context, appContext.K8sClient, metav1.ListOptions{
+		LabelSelector: labels.Set{cfg.UpgradeStatusLabel: cfg.UpgradeStatusParkedValue}.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	extra := len(parkedNodes) - cfg.MaxParkedNodes
+	if extra <= 0 {
+		return nil, nil
+	}
+
+	sortNewestParkedFirst(parkedNodes, appContext)
+
+	// the nodes skipped by UnparkNodes, e.g. locked or being deleted by cluster-autoscaler, don't count, the next
+	// candidates are unparked instead
+	var unparked []string
+	var errs []string
+	for _, node := range parkedNodes {
+		if len(unparked) == extra {
+			break
+		}
+		if NodeHasTaint(node, cfg.ToBeDeletedTaint) {
+			continue
+		}
+
+		done, err := UnparkNodes(appContext, []NodeInfo{NewNodeInfo(node)}, node.Labels[cfg.ParkingReasonLabel])
+		unparked = append(unparked, done...)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(unparked) < extra {
+		errs = append(errs, fmt.Sprintf("only %d of the %d nodes exceeding MaxParkedNodes could be unparked", len(unparked), extra))
+	}
+
+	if len(errs) > 0 {
+		return unparked, errors.New(strings.Join(errs, "; "))
+	}
+	return unparked, nil
+}
+
+// CountParkedNodes returns the number of nodes currently parked
+func CountParkedNodes(appContext *AppContext) (int, error) {
+	nodes, err := appContext.ListNodes(appContext.Context, appContext.BackgroundK8sClient, metav1.ListOptions{
+		LabelSelector: labels.Set{appContext.Config().UpgradeStatusLabel: appContext.Config().UpgradeStatusParkedValue}.String(),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(nodes), nil
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package utils
+
+import (
+	"sync"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// PauseState tells whether the mutating actions of k8s-shredder are paused, since when and why
+type PauseState struct {
+	Paused bool      `json:"paused"`
+	Since  time.Time `json:"since,omitempty"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+var (
+	pauseMu    sync.RWMutex
+	pauseState PauseState
+)
+
+// Pause stops every eviction loop and detector of all the managed clusters from changing anything, including the loops
+// already running, until Resume is called. The pause is kept in memory only, a restart resumes k8s-shredder
+func Pause(reason string) PauseState {
+	pauseMu.Lock()
+	defer pauseMu.Unlock()
+
+	if !pauseState.Paused {
+		pauseState = PauseState{Paused: true, Since: time.Now().UTC(), Reason: reason}
+	}
+	metrics.ShredderPaused.Set(1)
+	log.WithField("reason", pauseState.Reason).Warn("=== k8s-shredder PAUSED: no node is parked or drained until it is resumed ===")
+	return pauseState
+}
+
+// Resume lets the eviction loops and detectors change the cluster again
+func Resume() PauseState {
+	pauseMu.Lock()
+	defer pauseMu.Unlock()
+
+	if pauseState.Paused {
+		log.Warnf("=== k8s-shredder RESUMED after being paused for %s ===", time.Since(pauseState.Since).Round(time.Second).String())
+	}
+	pauseState = PauseState{}
+	metrics.ShredderPaused.Set(0)
+	return pauseState
+}
+
+// PauseStatus returns the current pause state
+func PauseStatus() PauseState {
+	pauseMu.RLock()
+	defer pauseMu.RUnlock()
+
+	return pauseState
+}
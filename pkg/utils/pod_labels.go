@@ -0,0 +1,229 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// parkedPodsToLabel returns the pods of a node to label when parking it, see selectPodsToLabel
+func parkedPodsToLabel(appContext *AppContext, nodeName string) ([]v1.Pod, error) {
+	cfg := *appContext.Config()
+
+	podSelector, err := labels.Parse(cfg.ParkedPodLabelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := ListPods(appContext.Context, appContext.K8sClient, "", cfg.APIListPageSize, metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("spec.nodeName=%s", nodeName),
+		LabelSelector: podSelector.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return selectPodsToLabel(appContext, pods)
+}
+
+// selectPodsToLabel filters the pods of a node down to the ones getting the parking labels: the running pods not
+// controlled by a DaemonSet, nor static, matching ParkedPodLabelSelector and running in a namespace matching
+// ParkedPodNamespaceSelector
+func selectPodsToLabel(appContext *AppContext, pods []v1.Pod) ([]v1.Pod, error) {
+	cfg := *appContext.Config()
+
+	podSelector, err := labels.Parse(cfg.ParkedPodLabelSelector)
+	if err != nil {
+		return nil, err
+	}
+	namespaceSelector, err := labels.Parse(cfg.ParkedPodNamespaceSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	// the namespaces are only fetched when selecting on them, once per node
+	namespaceMatches := map[string]bool{}
+	var selected []v1.Pod
+	for _, pod := range pods {
+		if PodIsDaemonSetOrStatic(pod) || pod.DeletionTimestamp != nil || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		if !podSelector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		if !namespaceSelector.Empty() {
+			matches, found := namespaceMatches[pod.Namespace]
+			if !found {
+				ns, err := appContext.K8sClient.CoreV1().Namespaces().Get(appContext.Context, pod.Namespace, metav1.GetOptions{})
+				if err != nil {
+					return nil, err
+				}
+				matches = namespaceSelector.Matches(labels.Set(ns.Labels))
+				namespaceMatches[pod.Namespace] = matches
+			}
+			if !matches {
+				continue
+			}
+		}
+		selected = append(selected, pod)
+	}
+	return selected, nil
+}
+
+// parkedPodLabelsFieldManager owns the parking labels of the pods, so that applying a pod without them removes them
+const parkedPodLabelsFieldManager = "k8s-shredder-parking"
+
+// labelParkedPods labels the selected pods of a parked node with UpgradeStatusLabel and ExpiresOnLabel, so that the
+// workloads know they are about to be evicted. Failing to label a pod doesn't prevent the node from being parked
+func labelParkedPods(appContext *AppContext, nodeName string, expiresOn time.Time, logger *log.Entry) {
+	cfg := *appContext.Config()
+
+	pods, err := parkedPodsToLabel(appContext, nodeName)
+	if err != nil {
+		logger.Warnf("Failed to list the pods to label: %s", err.Error())
+		return
+	}
+
+	patchPods(appContext, pods, "label", map[string]string{
+		cfg.UpgradeStatusLabel: cfg.UpgradeStatusParkedValue,
+		cfg.ExpiresOnLabel:     strconv.FormatInt(expiresOn.Unix(), 10),
+	}, logger)
+}
+
+// ReconcileParkedPodLabels labels the selected pods of a parked node missing the parking labels, like the pods scheduled
+// onto it after parking thanks to their tolerations, returning how many pods were labeled. nodePods are all the pods of
+// the node, as listed by the eviction loop
+func ReconcileParkedPodLabels(appContext *AppContext, nodePods []v1.Pod, expiresOn time.Time, logger *log.Entry) (int, error) {
+	cfg := *appContext.Config()
+
+	pods, err := selectPodsToLabel(appContext, nodePods)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to select the pods to label")
+	}
+
+	expiresOnValue := strconv.FormatInt(expiresOn.Unix(), 10)
+	var unlabeled []v1.Pod
+	for _, pod := range pods {
+		if pod.Labels[cfg.UpgradeStatusLabel] != cfg.UpgradeStatusParkedValue || pod.Labels[cfg.ExpiresOnLabel] != expiresOnValue {
+			unlabeled = append(unlabeled, pod)
+		}
+	}
+
+	patchPods(appContext, unlabeled, "reconcile", map[string]string{
+		cfg.UpgradeStatusLabel: cfg.UpgradeStatusParkedValue,
+		cfg.ExpiresOnLabel:     expiresOnValue,
+	}, logger)
+	return len(unlabeled), nil
+}
+
+// PodMissingParkingLabels checks whether a pod of a parked node gets the parking labels but doesn't have them yet, meaning
+// it was not given notice of its eviction
+func PodMissingParkingLabels(appContext *AppContext, pod v1.Pod) (bool, error) {
+	cfg := *appContext.Config()
+
+	selected, err := selectPodsToLabel(appContext, []v1.Pod{pod})
+	if err != nil {
+		return false, err
+	}
+	return len(selected) > 0 && pod.Labels[cfg.UpgradeStatusLabel] != cfg.UpgradeStatusParkedValue, nil
+}
+
+// unlabelParkedPods removes the parking labels from the pods of an unparked node, regardless of the selectors, which
+// may have changed since the node got parked
+func unlabelParkedPods(appContext *AppContext, nodeName string, logger *log.Entry) {
+	cfg := *appContext.Config()
+
+	labeled, err := ListPods(appContext.Context, appContext.K8sClient, "", cfg.APIListPageSize, metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("spec.nodeName=%s", nodeName),
+		LabelSelector: cfg.UpgradeStatusLabel,
+	})
+	if err != nil {
+		logger.Warnf("Failed to list the pods to unlabel: %s", err.Error())
+		return
+	}
+
+	// applying no labels releases the ones owned by parkedPodLabelsFieldManager, leaving the labels set by others
+	patchPods(appContext, labeled, "unlabel", nil, logger)
+}
+
+// patchPods server-side applies the given parking labels to the pods, ParkedPodLabelingConcurrency pods at a time,
+// logging the pods which could not be patched
+func patchPods(appContext *AppContext, pods []v1.Pod, operation string, podLabels map[string]string, logger *log.Entry) {
+	if len(pods) == 0 {
+		return
+	}
+	if appContext.IsDryRun() && !appContext.ServerSideDryRun() {
+		logger.Infof("Would have updated the parking labels of %d pods", len(pods))
+		return
+	}
+
+	start := time.Now()
+	queue := make(chan v1.Pod)
+	var failed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < min(appContext.Config().ParkedPodLabelingConcurrency, len(pods)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pod := range queue {
+				if err := applyPodLabels(appContext, pod, podLabels); err != nil {
+					failed.Add(1)
+					logger.WithField("pod", pod.Namespace+"/"+pod.Name).Warnf("Failed to update the parking labels of pod: %s", err.Error())
+				}
+			}
+		}()
+	}
+	for _, pod := range pods {
+		queue <- pod
+	}
+	close(queue)
+	wg.Wait()
+
+	duration := time.Since(start)
+	metrics.ShredderParkedPodLabelingDurationSeconds.WithLabelValues(appContext.Cluster, operation).Observe(duration.Seconds())
+	logger.Debugf("Updated the parking labels of %d pods in %s, %d failed", len(pods), duration.String(), failed.Load())
+}
+
+// applyPodLabels server-side applies the given labels to a pod as parkedPodLabelsFieldManager
+func applyPodLabels(appContext *AppContext, pod v1.Pod, podLabels map[string]string) error {
+	metadata := map[string]interface{}{"name": pod.Name, "namespace": pod.Namespace}
+	if len(podLabels) > 0 {
+		metadata["labels"] = podLabels
+	}
+	patchData, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   metadata,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the apply patch")
+	}
+
+	force := true
+	return RetryAPICall(appContext, func() error {
+		_, err := appContext.K8sClient.CoreV1().Pods(pod.Namespace).Patch(appContext.Context, pod.Name, types.ApplyPatchType, patchData,
+			metav1.PatchOptions{FieldManager: parkedPodLabelsFieldManager, Force: &force, DryRun: appContext.DryRunOption()})
+		return err
+	})
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package utils
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NodesByProviderID resolves the given provider IDs into the nodes to park for batch. Provider IDs are either complete,
+// e.g. `aws:///us-east-1a/i-0123456789abcdef0`, or reduced to their last segment, usually the instance ID referenced by
+// cloud maintenance notifications. The provider IDs matching no node are returned as well
+func NodesByProviderID(appContext *AppContext, providerIDs []string, batch string) ([]NodeInfo, []string, error) {
+	nodes, err := appContext.ListNodes(appContext.Context, appContext.BackgroundK8sClient, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to list nodes")
+	}
+
+	index := make(map[string]string, 2*len(nodes))
+	for _, node := range nodes {
+		if node.Spec.ProviderID == "" {
+			continue
+		}
+		index[node.Spec.ProviderID] = node.Name
+		index[providerIDSuffix(node.Spec.ProviderID)] = node.Name
+	}
+
+	var resolved []NodeInfo
+	var unresolved []string
+	for _, providerID := range providerIDs {
+		name, found := index[providerID]
+		if !found {
+			unresolved = append(unresolved, providerID)
+			continue
+		}
+		resolved = append(resolved, NodeInfo{Name: name, Batch: batch, ReasonMessage: "matched provider ID " + providerID})
+	}
+	return resolved, unresolved, nil
+}
+
+// providerIDSuffix returns the last segment of a provider ID
+func providerIDSuffix(providerID string) string {
+	return providerID[strings.LastIndex(providerID, "/")+1:]
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package utils
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// CapacityResources are the resources compared while checking whether the remaining nodes can take the pods of parked nodes
+var CapacityResources = []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory}
+
+// NodeReady checks the Ready condition of a node
+func NodeReady(node v1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// PodRequests returns the resources requested by a pod, its init containers running one after the other before the
+// containers
+func PodRequests(pod v1.Pod) v1.ResourceList {
+	requests := v1.ResourceList{}
+	for _, container := range pod.Spec.Containers {
+		AddResources(requests, container.Resources.Requests)
+	}
+	for _, container := range pod.Spec.InitContainers {
+		for _, name := range CapacityResources {
+			if quantity, ok := container.Resources.Requests[name]; ok && quantity.Cmp(requests[name]) > 0 {
+				requests[name] = quantity.DeepCopy()
+			}
+		}
+	}
+	AddResources(requests, pod.Spec.Overhead)
+	return requests
+}
+
+// AddResources adds the CapacityResources of add to list
+func AddResources(list, add v1.ResourceList) {
+	for _, name := range CapacityResources {
+		quantity := list[name].DeepCopy()
+		quantity.Add(add[name])
+		list[name] = quantity
+	}
+}
+
+// SubtractResources subtracts the CapacityResources of sub from list, without going below zero
+func SubtractResources(list, sub v1.ResourceList) {
+	for _, name := range CapacityResources {
+		quantity := list[name].DeepCopy()
+		quantity.Sub(sub[name])
+		if quantity.Sign() < 0 {
+			quantity = resource.Quantity{}
+		}
+		list[name] = quantity
+	}
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package utils
+
+import (
+	"github.com/adobe/k8s-shredder/pkg/config"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+)
+
+// IsTransientAPIError reports whether an API call failing with err is worth retrying: conflicts, throttling,
+// timeouts and server errors
+func IsTransientAPIError(err error) bool {
+	return apierrors.IsConflict(err) ||
+		apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsInternalError(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		apierrors.IsUnexpectedServerError(err)
+}
+
+// RetryAPICall calls fn until it succeeds, fails with a non transient error or APIRetryAttempts attempts were made,
+// backing off exponentially between attempts. fn must read again the objects it updates so that conflicts get resolved
+func RetryAPICall(appContext *AppContext, fn func() error) error {
+	return RetryAPICallOn(appContext, IsTransientAPIError, fn)
+}
+
+// RetryAPICallOn is like RetryAPICall but only retries the errors for which retriable returns true
+func RetryAPICallOn(appContext *AppContext, retriable func(error) bool, fn func() error) error {
+	return retry.OnError(apiRetryBackoff(*appContext.Config()), retriable, fn)
+}
+
+// apiRetryBackoff returns the jittered exponential backoff used between API call attempts
+func apiRetryBackoff(cfg config.Config) wait.Backoff {
+	return wait.Backoff{
+		Steps:    cfg.APIRetryAttempts,
+		Duration: cfg.APIRetryInitialBackoff,
+		Factor:   2,
+		Jitter:   0.1,
+	}
+}
@@ -20,7 +20,8 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-// HandleOsSignals gracefully handles OS signals
+// HandleOsSignals gracefully handles OS signals. The first signal cancels the application context so that in-flight
+// work can be drained, a second one terminates the process immediately
 func HandleOsSignals(cancel context.CancelFunc) {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c,
@@ -31,7 +32,10 @@ func HandleOsSignals(cancel context.CancelFunc) {
 	)
 
 	sig := <-c
-	log.Debugf("Got signal %s, terminating gracefully", sig.String())
+	log.Infof("Got signal %s, terminating gracefully", sig.String())
 	cancel()
-	os.Exit(0)
+
+	sig = <-c
+	log.Warnf("Got signal %s while shutting down, terminating immediately", sig.String())
+	os.Exit(1)
 }
@@ -0,0 +1,119 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package utils
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/audit"
+	"github.com/adobe/k8s-shredder/pkg/config"
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// softParkNode soft parks a node: it gets the parking labels, with UpgradeStatusSoftParkedValue, and the ParkedNodeTaint
+// with the PreferNoSchedule effect, so that the scheduler avoids it while the running pods are left alone. The node is
+// parked once SoftParkingDelay elapsed, see SoftParkedNodesToPromote
+func softParkNode(appContext *AppContext, node *v1.Node, nodeInfo NodeInfo, source string, taint v1.Taint, logger *log.Entry) error {
+	cfg := *appContext.Config()
+
+	softParkedAt := time.Now().UTC()
+	promotedAt := softParkedAt.Add(cfg.SoftParkingDelay)
+
+	if node.Labels == nil {
+		node.Labels = map[string]string{}
+	}
+	node.Labels[cfg.UpgradeStatusLabel] = cfg.UpgradeStatusSoftParkedValue
+	node.Labels[cfg.ParkedAtLabel] = strconv.FormatInt(softParkedAt.Unix(), 10)
+	node.Labels[cfg.ParkingReasonLabel] = source
+	if nodeInfo.Batch != "" {
+		node.Labels[cfg.ParkingBatchLabel] = nodeInfo.Batch
+	}
+	if cfg.ParkingReasonMessageAnnotation != "" {
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+		node.Annotations[cfg.ParkingReasonMessageAnnotation] = parkingReasonMessage(source, nodeInfo.ReasonMessage)
+	}
+	if err := setNodeState(node, cfg, NodeStateSoftParked); err != nil {
+		// the node was unparked without k8s-shredder, its lifecycle starts over
+		logger.Warnf("%s, resetting it", err.Error())
+		node.Annotations[cfg.NodeStateAnnotation] = string(NodeStateSoftParked)
+	}
+	taint.Effect = v1.TaintEffectPreferNoSchedule
+	if !NodeHasTaint(*node, taint.Key) {
+		node.Spec.Taints = append(node.Spec.Taints, taint)
+	}
+
+	auditEntry := audit.Entry{Action: audit.ActionSoftPark, Kind: "Node", Name: node.Name, Node: node.Name, DryRun: appContext.IsDryRun()}
+
+	if appContext.IsDryRun() && !appContext.ServerSideDryRun() {
+		logger.Infof("Would have soft parked node until %s", promotedAt.Format(time.RFC3339))
+		audit.Record(auditEntry, time.Now(), nil)
+		metrics.ShredderNodesSoftParkedTotal.WithLabelValues(appContext.Cluster, source, dryRunLabel(appContext)).Inc()
+		return nil
+	}
+
+	start := time.Now()
+	err := updateParkingNode(appContext, node, taint, source, logger)
+	audit.Record(auditEntry, start, err)
+	if err != nil {
+		return err
+	}
+
+	if appContext.IsDryRun() {
+		logger.Infof("Would have soft parked node until %s, the API server accepted it", promotedAt.Format(time.RFC3339))
+	} else {
+		logger.Infof("Soft parked node until %s", promotedAt.Format(time.RFC3339))
+	}
+	metrics.ShredderNodesSoftParkedTotal.WithLabelValues(appContext.Cluster, source, dryRunLabel(appContext)).Inc()
+	return nil
+}
+
+// softParkingEnd returns when a soft parked node is due for parking, the zero time when the ParkedAtLabel can't be parsed
+func softParkingEnd(node v1.Node, cfg config.Config) time.Time {
+	softParkedAt, err := GetParkedNodeParkedTime(node, cfg.ParkedAtLabel)
+	if err != nil {
+		return time.Time{}
+	}
+	return softParkedAt.Add(cfg.SoftParkingDelay)
+}
+
+// SoftParkedNodesToPromote returns the soft parked nodes due for parking, by the source they were soft parked for, so
+// that they get parked even when their detector doesn't find them anymore
+func SoftParkedNodesToPromote(appContext *AppContext) (map[string][]NodeInfo, error) {
+	cfg := *appContext.Config()
+
+	softParkedNodes, err := appContext.ListNodes(appContext.Context, appContext.BackgroundK8sClient, metav1.ListOptions{
+		LabelSelector: labels.Set{cfg.UpgradeStatusLabel: cfg.UpgradeStatusSoftParkedValue}.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	bySource := map[string][]NodeInfo{}
+	for _, node := range softParkedNodes {
+		if now.Before(softParkingEnd(node, cfg)) {
+			continue
+		}
+		nodeInfo := NewNodeInfo(node)
+		nodeInfo.Batch = node.Labels[cfg.ParkingBatchLabel]
+		source := node.Labels[cfg.ParkingReasonLabel]
+		bySource[source] = append(bySource[source], nodeInfo)
+	}
+	return bySource, nil
+}
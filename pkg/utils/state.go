@@ -0,0 +1,143 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package utils
+
+import (
+	"encoding/json"
+
+	"github.com/adobe/k8s-shredder/pkg/config"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// NodeState is the lifecycle state of a node handled by k8s-shredder, persisted in the NodeStateAnnotation
+type NodeState string
+
+const (
+	// NodeStateDetected is set while node-local agents are asked to prepare for parking
+	NodeStateDetected NodeState = "Detected"
+	// NodeStateSoftParked is set while the node is soft parked, ahead of its parking
+	NodeStateSoftParked NodeState = "SoftParked"
+	// NodeStateParked is set when the node gets parked
+	NodeStateParked NodeState = "Parked"
+	// NodeStateDraining is set while the pods of a parked node are evicted or rollout restarted
+	NodeStateDraining NodeState = "Draining"
+	// NodeStateExpired is set once the parked node TTL expired
+	NodeStateExpired NodeState = "Expired"
+	// NodeStateForceEvicting is set while the pods of an expired parked node are deleted
+	NodeStateForceEvicting NodeState = "ForceEvicting"
+	// NodeStateCleared is set once a parked node is left without any pod to evict
+	NodeStateCleared NodeState = "Cleared"
+	// NodeStateUnparked is set when the node gets unparked
+	NodeStateUnparked NodeState = "Unparked"
+)
+
+// NodeStates lists all the node states, in lifecycle order
+var NodeStates = []NodeState{
+	NodeStateDetected,
+	NodeStateSoftParked,
+	NodeStateParked,
+	NodeStateDraining,
+	NodeStateExpired,
+	NodeStateForceEvicting,
+	NodeStateCleared,
+	NodeStateUnparked,
+}
+
+// nodeStateTransitions lists the states each state can move to, the empty state being the one of nodes never handled
+var nodeStateTransitions = map[NodeState][]NodeState{
+	"":                     {NodeStateDetected, NodeStateSoftParked, NodeStateParked},
+	NodeStateDetected:      {NodeStateSoftParked, NodeStateParked, NodeStateUnparked},
+	NodeStateSoftParked:    {NodeStateDetected, NodeStateParked, NodeStateUnparked},
+	NodeStateParked:        {NodeStateDraining, NodeStateExpired, NodeStateCleared, NodeStateUnparked},
+	NodeStateDraining:      {NodeStateExpired, NodeStateCleared, NodeStateUnparked},
+	NodeStateExpired:       {NodeStateParked, NodeStateForceEvicting, NodeStateCleared, NodeStateUnparked},
+	NodeStateForceEvicting: {NodeStateCleared, NodeStateUnparked},
+	NodeStateCleared:       {NodeStateDraining, NodeStateForceEvicting, NodeStateUnparked},
+	NodeStateUnparked:      {NodeStateDetected, NodeStateSoftParked, NodeStateParked},
+}
+
+// ValidNodeStateTransition checks whether a node can move from one state to another
+func ValidNodeStateTransition(from, to NodeState) bool {
+	for _, state := range nodeStateTransitions[from] {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
+
+// GetNodeState returns the state recorded on a node, unknown values being handled like a missing annotation
+func GetNodeState(node v1.Node, cfg config.Config) NodeState {
+	state := NodeState(node.Annotations[cfg.NodeStateAnnotation])
+	if _, found := nodeStateTransitions[state]; !found {
+		return ""
+	}
+	return state
+}
+
+// setNodeState records a new state in the annotations of a node object, without updating it in the APIServer
+func setNodeState(node *v1.Node, cfg config.Config, to NodeState) error {
+	from := GetNodeState(*node, cfg)
+	if from == to {
+		return nil
+	}
+	if !ValidNodeStateTransition(from, to) {
+		return errors.Errorf("Invalid state transition from %q to %q for node %s", from, to, node.Name)
+	}
+
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+	node.Annotations[cfg.NodeStateAnnotation] = string(to)
+	return nil
+}
+
+// TransitionNodeState moves a node to a new state, patching its NodeStateAnnotation. Staying in the same state is a no-op
+// while invalid transitions are refused
+func TransitionNodeState(appContext *AppContext, node v1.Node, to NodeState, logger *log.Entry) error {
+	cfg := *appContext.Config()
+
+	from := GetNodeState(node, cfg)
+	if from == to {
+		return nil
+	}
+	if !ValidNodeStateTransition(from, to) {
+		return errors.Errorf("Invalid state transition from %q to %q for node %s", from, to, node.Name)
+	}
+
+	if appContext.IsDryRun() {
+		logger.Debugf("Would have moved node from state %q to %q", from, to)
+		return nil
+	}
+
+	patchData, _ := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				cfg.NodeStateAnnotation: string(to),
+			},
+		},
+	})
+	err := RetryAPICall(appContext, func() error {
+		_, err := appContext.K8sClient.CoreV1().Nodes().Patch(appContext.Context, node.Name, types.MergePatchType, patchData, metav1.PatchOptions{FieldManager: "k8s-shredder"})
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Failed to annotate node %s with %s", node.Name, cfg.NodeStateAnnotation)
+	}
+
+	logger.Debugf("Moved node from state %q to %q", from, to)
+	return nil
+}
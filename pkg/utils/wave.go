@@ -0,0 +1,95 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package utils
+
+import (
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ParkingWave is the state of the ongoing parking wave. It is derived from the ParkedAtLabel of the parked nodes rather
+// than kept in memory, so that restarts and multiple replicas agree on it
+type ParkingWave struct {
+	// Start is when the first node of the wave got parked, zero when no wave is ongoing
+	Start time.Time
+	// Parked lists the nodes parked during the wave
+	Parked []string
+}
+
+// End is when the wave is over and the next one can start
+func (w ParkingWave) End(interval time.Duration) time.Time {
+	return w.Start.Add(interval)
+}
+
+// parkingWave returns the wave made of the given parked nodes parked after now-interval
+func parkingWave(parkedNodes []v1.Node, parkedAtLabel string, interval time.Duration, now time.Time) ParkingWave {
+	var wave ParkingWave
+	for _, node := range parkedNodes {
+		parkedAt, err := GetParkedNodeParkedTime(node, parkedAtLabel)
+		if err != nil || !parkedAt.After(now.Add(-interval)) {
+			continue
+		}
+		if wave.Start.IsZero() || parkedAt.Before(wave.Start) {
+			wave.Start = parkedAt
+		}
+		wave.Parked = append(wave.Parked, node.Name)
+	}
+	return wave
+}
+
+// LimitNodesToWave drops the nodes that would exceed ParkingWaveSize, the nodes parked during the ongoing wave
+// included. The oldest nodes are picked first, the dropped ones are found again by the detectors and parked during a
+// later wave
+func LimitNodesToWave(appContext *AppContext, nodes []NodeInfo, source string) ([]NodeInfo, error) {
+	cfg := *appContext.Config()
+	if len(nodes) == 0 || cfg.ParkingWaveSize <= 0 {
+		return nodes, nil
+	}
+
+	parkedNodes, err := appContext.ListNodes(appContext.Context, appContext.BackgroundK8sClient, metav1.ListOptions{
+		LabelSelector: labels.Set{cfg.UpgradeStatusLabel: cfg.UpgradeStatusParkedValue}.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	parked := make(map[string]bool, len(parkedNodes))
+	for _, node := range parkedNodes {
+		parked[node.Name] = true
+	}
+
+	sortOldestNodesFirst(nodes)
+	wave := parkingWave(parkedNodes, cfg.ParkedAtLabel, cfg.ParkingWaveInterval, time.Now().UTC())
+	available := cfg.ParkingWaveSize - len(wave.Parked)
+	limited := make([]NodeInfo, 0, len(nodes))
+	for _, nodeInfo := range nodes {
+		if parked[nodeInfo.Name] {
+			// already parked, parkNode will skip it anyway
+			limited = append(limited, nodeInfo)
+			continue
+		}
+		if available <= 0 {
+			log.WithFields(log.Fields{"node": nodeInfo.Name, "source": source}).
+				Infof("ParkingWaveSize=%d reached, not parking node before the next wave at %s", cfg.ParkingWaveSize,
+					wave.End(cfg.ParkingWaveInterval).Format(time.RFC3339))
+			metrics.ShredderParkingDeferredByWaveTotal.WithLabelValues(appContext.Cluster, source, dryRunLabel(appContext)).Inc()
+			continue
+		}
+		limited = append(limited, nodeInfo)
+		available--
+	}
+	return limited, nil
+}
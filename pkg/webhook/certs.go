@@ -0,0 +1,157 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/audit"
+	"github.com/adobe/k8s-shredder/pkg/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// certReloader serves the certificate found in a directory, reloading it whenever it is rotated
+// (e.g. by cert-manager updating the mounted secret)
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newCertReloader(certDir string) *certReloader {
+	return &certReloader{
+		certFile: filepath.Join(certDir, "tls.crt"),
+		keyFile:  filepath.Join(certDir, "tls.key"),
+	}
+}
+
+// GetCertificate returns the current certificate, reloading it from disk if it changed since it was last loaded
+func (c *certReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	info, err := os.Stat(c.certFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to stat certificate %s", c.certFile)
+	}
+
+	if c.cert == nil || info.ModTime().After(c.modTime) {
+		cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load certificate %s", c.certFile)
+		}
+		log.Infof("Loaded admission webhook certificate %s", c.certFile)
+		c.cert = &cert
+		c.modTime = info.ModTime()
+	}
+
+	return c.cert, nil
+}
+
+// generateSelfSignedCertificate returns a self-signed serving certificate for the given DNS names along with
+// its PEM encoding, to be used as CA bundle
+func generateSelfSignedCertificate(dnsNames []string) (tls.Certificate, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: dnsNames[0], Organization: []string{"k8s-shredder"}},
+		DNSNames:              dnsNames,
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	return cert, certPEM, nil
+}
+
+// injectCABundle sets the CA bundle of all the webhooks from the configured ValidatingWebhookConfiguration so that
+// the API server trusts the self-signed certificate
+func injectCABundle(appContext *utils.AppContext, caBundle []byte) error {
+	name := appContext.Config().AdmissionWebhookConfigurationName
+	client := appContext.K8sClient.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+
+	ctx, cancel := context.WithTimeout(appContext.Context, 30*time.Second)
+	defer cancel()
+
+	err := utils.RetryAPICall(appContext, func() error {
+		webhookConfiguration, err := client.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "failed to get ValidatingWebhookConfiguration %s", name)
+		}
+
+		for i := range webhookConfiguration.Webhooks {
+			webhookConfiguration.Webhooks[i].ClientConfig.CABundle = caBundle
+		}
+
+		start := time.Now()
+		_, err = client.Update(ctx, webhookConfiguration, metav1.UpdateOptions{FieldManager: "k8s-shredder"})
+		audit.Record(audit.Entry{Action: audit.ActionInjectCABundle, Kind: "ValidatingWebhookConfiguration", Name: name}, start, err)
+		if err != nil {
+			return errors.Wrapf(err, "failed to inject CA bundle into ValidatingWebhookConfiguration %s", name)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Injected CA bundle into ValidatingWebhookConfiguration %s", name)
+	return nil
+}
@@ -0,0 +1,229 @@
+/*
+Copyright 2022 Adobe. All rights reserved.
+This file is licensed to you under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License. You may obtain a copy
+of the License at http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software distributed under
+the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR REPRESENTATIONS
+OF ANY KIND, either express or implied. See the License for the specific language
+governing permissions and limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/adobe/k8s-shredder/pkg/metrics"
+	"github.com/adobe/k8s-shredder/pkg/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ValidatePodsPath is the path on which the admission webhook server validates pod creations and bindings
+const ValidatePodsPath = "/validate-pods"
+
+// Server is an admission webhook server that rejects pods being scheduled onto parked nodes, covering
+// workloads that tolerate the parking taints
+type Server struct {
+	appContext *utils.AppContext
+	logger     *log.Entry
+	server     *http.Server
+}
+
+// NewServer returns a new admission webhook Server for the given application context
+func NewServer(appContext *utils.AppContext) (*Server, error) {
+	s := &Server{
+		appContext: appContext,
+		logger:     log.WithField("component", "admission-webhook").WithField("dryRun", appContext.IsDryRun()),
+	}
+
+	tlsConfig, err := s.tlsConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to setup admission webhook TLS")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(ValidatePodsPath, s.handleValidatePods)
+
+	s.server = &http.Server{
+		Addr:              fmt.Sprintf(":%d", appContext.Config().AdmissionWebhookPort),
+		Handler:           mux,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 3 * time.Second,
+	}
+
+	return s, nil
+}
+
+// Start serves admission requests in the background
+func (s *Server) Start() {
+	s.logger.Infof("Starting admission webhook server on %s", s.server.Addr)
+	go func() {
+		if err := s.server.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Fatalf("Admission webhook server failed: %s", err)
+		}
+	}()
+}
+
+// Shutdown gracefully stops the admission webhook server
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	if s.appContext.Config().AdmissionWebhookCertDir != "" {
+		reloader := newCertReloader(s.appContext.Config().AdmissionWebhookCertDir)
+		// fail fast if the certificate can't be loaded at all
+		if _, err := reloader.GetCertificate(nil); err != nil {
+			return nil, err
+		}
+		return &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: reloader.GetCertificate}, nil
+	}
+
+	namespace := utils.GetCurrentNamespace()
+	service := s.appContext.Config().AdmissionWebhookServiceName
+	cert, caBundle, err := generateSelfSignedCertificate([]string{
+		service,
+		fmt.Sprintf("%s.%s", service, namespace),
+		fmt.Sprintf("%s.%s.svc", service, namespace),
+		fmt.Sprintf("%s.%s.svc.cluster.local", service, namespace),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = injectCABundle(s.appContext, caBundle)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}, nil
+}
+
+func (s *Server) handleValidatePods(res http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(req.Body, 1<<20))
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	review := admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(body, &review); err != nil || review.Request == nil {
+		http.Error(res, "invalid admission review", http.StatusBadRequest)
+		return
+	}
+
+	review.Response = s.review(review.Request)
+	review.Response.UID = review.Request.UID
+	review.Request = nil
+
+	out, err := json.Marshal(review)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	_, err = res.Write(out)
+	if err != nil {
+		s.logger.Errorln("Error while replying to admission request:", err)
+	}
+}
+
+// review decides whether a pod creation or binding targets a parked node
+func (s *Server) review(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	allowed := &admissionv1.AdmissionResponse{Allowed: true}
+
+	if req.Operation != admissionv1.Create || req.Resource.Resource != "pods" {
+		return allowed
+	}
+
+	var pod *v1.Pod
+	var nodeName string
+
+	switch req.SubResource {
+	case "":
+		pod = &v1.Pod{}
+		if err := json.Unmarshal(req.Object.Raw, pod); err != nil {
+			return s.errored(err)
+		}
+		nodeName = pod.Spec.NodeName
+	case "binding":
+		binding := &v1.Binding{}
+		if err := json.Unmarshal(req.Object.Raw, binding); err != nil {
+			return s.errored(err)
+		}
+		nodeName = binding.Target.Name
+	default:
+		return allowed
+	}
+
+	if nodeName == "" {
+		return allowed
+	}
+
+	node, err := s.appContext.K8sClient.CoreV1().Nodes().Get(s.appContext.Context, nodeName, metav1.GetOptions{})
+	if err != nil {
+		return s.errored(err)
+	}
+
+	if node.Labels[s.appContext.Config().UpgradeStatusLabel] != s.appContext.Config().UpgradeStatusParkedValue || utils.NodeIsProtected(*node, *s.appContext.Config()) {
+		metrics.ShredderAdmissionRequestsTotal.WithLabelValues("allowed").Inc()
+		return allowed
+	}
+
+	if pod == nil {
+		pod, err = s.appContext.K8sClient.CoreV1().Pods(req.Namespace).Get(s.appContext.Context, req.Name, metav1.GetOptions{})
+		if err != nil {
+			return s.errored(err)
+		}
+	}
+
+	// DaemonSet and static pods are expected to run on every node, including the parked ones
+	if utils.PodIsDaemonSetOrStatic(*pod) {
+		metrics.ShredderAdmissionRequestsTotal.WithLabelValues("allowed").Inc()
+		return allowed
+	}
+
+	message := fmt.Sprintf("node %s is parked by k8s-shredder, pods can't be scheduled onto it", nodeName)
+	logger := s.logger.WithFields(log.Fields{
+		"namespace": req.Namespace,
+		"pod":       req.Name,
+		"node":      nodeName,
+	})
+
+	if s.appContext.IsDryRun() {
+		logger.Info("Would have rejected pod scheduled onto parked node")
+		metrics.ShredderAdmissionRequestsTotal.WithLabelValues("allowed").Inc()
+		return &admissionv1.AdmissionResponse{Allowed: true, Warnings: []string{message}}
+	}
+
+	logger.Info("Rejecting pod scheduled onto parked node")
+	metrics.ShredderAdmissionRequestsTotal.WithLabelValues("denied").Inc()
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: message,
+			Reason:  metav1.StatusReasonForbidden,
+			Code:    http.StatusForbidden,
+		},
+	}
+}
+
+// errored allows the request when its target can't be determined, parking taints remain the primary protection
+func (s *Server) errored(err error) *admissionv1.AdmissionResponse {
+	s.logger.Warnf("Failed to review admission request, allowing it: %s", err.Error())
+	metrics.ShredderAdmissionRequestsTotal.WithLabelValues("error").Inc()
+	return &admissionv1.AdmissionResponse{Allowed: true}
+}